@@ -5,6 +5,17 @@ import type {
   UpdateSettingsResponse,
   RestoreBackupResponse,
   ConfigResponse,
+  ConfigBackupOptionGroup,
+  DiscoverResponse,
+  AcceptDiscoveredResponse,
+  BlueprintUsagesResponse,
+  ConfigBlueprintVersion,
+  ConfigSecretVersionsResponse,
+  QueueMetrics,
+  BackupRun,
+  CronScheduleInfo,
+  ServerEvent,
+  ServerEventType,
 } from "./types";
 
 const API_BASE = window.location.href.replace(/\/+$/, "") || "";
@@ -87,6 +98,32 @@ export class ApiClient {
     return response.json();
   }
 
+  async discoverConfigs(): Promise<DiscoverResponse> {
+    const response = await fetch(`${API_BASE}/discover`);
+    if (!response.ok) {
+      throw new Error(`Failed to discover configs: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async acceptDiscoveredConfigs(
+    groups: ConfigBackupOptionGroup[]
+  ): Promise<AcceptDiscoveredResponse> {
+    const response = await fetch(`${API_BASE}/discover/accept`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({ groups }),
+    });
+    if (!response.ok) {
+      throw new Error(
+        `Failed to accept discovered configs: ${response.statusText}`
+      );
+    }
+    return response.json();
+  }
+
   async restoreBackup(
     group: string,
     path: string,
@@ -101,13 +138,91 @@ export class ApiClient {
         method: "POST",
       }
     );
+    // A restore rolled back by the config check reports the check's errors
+    if (response.status === 422) {
+      return response.json();
+    }
     if (!response.ok) {
       throw new Error(`Failed to restore backup: ${response.statusText}`);
     }
     return response.json();
   }
 
-  async triggerBackup(): Promise<{ status: string }> {
+  async restoreDeletedConfig(
+    group: string,
+    path: string,
+    id: string
+  ): Promise<RestoreBackupResponse> {
+    const response = await fetch(
+      `${API_BASE}/configs/${group}/${path}/${id}/restore`,
+      {
+        method: "POST",
+      }
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to restore deleted config: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getBlueprintUsages(
+    group: string,
+    path: string,
+    id: string,
+    filename: string
+  ): Promise<BlueprintUsagesResponse> {
+    const response = await fetch(
+      `${API_BASE}/configs/${group}/${path}/${id}/backups/${encodeURIComponent(
+        filename
+      )}/blueprint-usages`
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to fetch blueprint usages: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getConfigBlueprintVersions(
+    group: string,
+    path: string,
+    id: string
+  ): Promise<ConfigBlueprintVersion[]> {
+    const response = await fetch(
+      `${API_BASE}/configs/${group}/${path}/${id}/blueprint-versions`
+    );
+    if (!response.ok) {
+      throw new Error(
+        `Failed to fetch blueprint versions: ${response.statusText}`
+      );
+    }
+    return response.json();
+  }
+
+  async getQueueMetrics(): Promise<QueueMetrics> {
+    const response = await fetch(`${API_BASE}/queue`);
+    if (!response.ok) {
+      throw new Error(`Failed to fetch queue metrics: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getConfigSecretVersions(
+    group: string,
+    path: string,
+    id: string
+  ): Promise<ConfigSecretVersionsResponse> {
+    const response = await fetch(
+      `${API_BASE}/configs/${group}/${path}/${id}/secret-versions`
+    );
+    if (!response.ok) {
+      throw new Error(
+        `Failed to fetch secret versions: ${response.statusText}`
+      );
+    }
+    return response.json();
+  }
+
+  async triggerBackup(): Promise<BackupRun> {
     const response = await fetch(`${API_BASE}/backup`, {
       method: "POST",
     });
@@ -117,6 +232,46 @@ export class ApiClient {
     return response.json();
   }
 
+  async getJobs(): Promise<BackupRun[]> {
+    const response = await fetch(`${API_BASE}/jobs`);
+    if (!response.ok) {
+      throw new Error(`Failed to fetch jobs: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getJob(id: string): Promise<BackupRun> {
+    const response = await fetch(`${API_BASE}/jobs/${id}`);
+    if (!response.ok) {
+      throw new Error(`Failed to fetch job: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getSchedules(): Promise<CronScheduleInfo[]> {
+    const response = await fetch(`${API_BASE}/schedules`);
+    if (!response.ok) {
+      throw new Error(`Failed to fetch schedules: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // subscribeEvents calls onEvent for each change pushed by the server. The browser
+  // reconnects on its own and picks up the events it missed. Returns a function that
+  // closes the connection.
+  subscribeEvents(
+    types: ServerEventType[],
+    onEvent: (event: ServerEvent) => void
+  ): () => void {
+    const source = new EventSource(`${API_BASE}/events`);
+    for (const type of types) {
+      source.addEventListener(type, (message) => {
+        onEvent(JSON.parse((message as MessageEvent).data));
+      });
+    }
+    return () => source.close();
+  }
+
   async deleteBackup(
     group: string,
     path: string,
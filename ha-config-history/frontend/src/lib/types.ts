@@ -5,16 +5,47 @@ export interface ConfigMetadata {
   lastHash?: string;
   backupCount: number;
   backupsSize: number;
+  missingId?: boolean;
+  mimeType?: string;
+  binary?: boolean;
+  previousIds?: string[];
+  renamedTo?: string;
+  deleted?: boolean;
+  deletedAt?: string;
 }
 
 export interface BackupInfo {
   filename: string;
   date: string;
   size: number;
+  id?: string;
+  attribution?: VersionAttribution;
+}
+
+export type ChangeSource = "user" | "integration" | "external";
+
+export interface VersionAttribution {
+  source: ChangeSource;
+  user?: string;
+  userId?: string;
+  detail?: string;
+  activityAt?: string;
+}
+
+export interface BinaryDiff {
+  changed: boolean;
+  oldSize: number;
+  newSize: number;
+  sizeDelta: number;
+  oldHash: string;
+  newHash: string;
+  oldMimeType: string;
+  newMimeType: string;
 }
 
 export interface BackupDiffResponse {
-  type: "diff" | "content";
+  type: "diff" | "content" | "binary";
+  binary?: BinaryDiff;
   unifiedDiff?: string;
   content?: string;
   oldContent?: string;
@@ -26,7 +57,14 @@ export interface BackupDiffResponse {
 
 export type ComparisonMode = "previous" | "current" | "two-backups";
 
-export type BackupType = "multiple" | "single" | "directory" | "keyed";
+export type BackupType =
+  | "multiple"
+  | "single"
+  | "directory"
+  | "keyed"
+  | "multidocument"
+  | "nodered"
+  | "blueprint";
 
 export interface ConfigBackupOptions {
   path: string;
@@ -37,11 +75,57 @@ export interface ConfigBackupOptions {
   friendlyNameNode?: string;
   includeFilePatterns?: string[];
   excludeFilePatterns?: string[];
+  missingIdStrategy?: MissingIdStrategy;
+  root?: string;
+  normalize?: NormalizeOptions;
+  cronSchedule?: string;
+  disableWatch?: boolean;
+  reloadAfterRestore?: boolean;
+  reloadService?: string;
+  checkConfigOnRestore?: boolean;
 }
 
+export interface NormalizeOptions {
+  ignorePaths?: string[];
+  sortPaths?: string[];
+  canonicalize?: boolean;
+}
+
+export type MissingIdStrategy = "fingerprint" | "slug" | "index";
+
 export interface ConfigBackupOptionGroup {
   groupName: string;
   configs: ConfigBackupOptions[];
+  cronSchedule?: string;
+  disableWatch?: boolean;
+}
+
+export type DiscoveryKind =
+  | "multiple"
+  | "keyed"
+  | "single"
+  | "storage"
+  | "esphome"
+  | "blueprint"
+  | "nodered";
+
+export interface DiscoveredConfig {
+  kind: DiscoveryKind;
+  group: ConfigBackupOptionGroup;
+  files: string[];
+  fileCount: number;
+  totalSize: number;
+  alreadyTracked: boolean;
+}
+
+export interface DiscoverResponse {
+  proposals: DiscoveredConfig[];
+}
+
+export interface AcceptDiscoveredResponse {
+  success: boolean;
+  added: number;
+  error?: string;
 }
 
 export interface AppSettings {
@@ -51,9 +135,37 @@ export interface AppSettings {
   cronSchedule?: string;
   defaultMaxBackups?: number;
   defaultMaxBackupAgeDays?: number;
+  sourceRoots?: SourceRoot[];
+  redaction?: RedactionSettings;
+  resolveSecrets?: boolean;
+  homeAssistant?: HomeAssistantSettings;
+  watchQuietPeriodMs?: number;
+  pollIntervalMs?: number;
+  pollHomeAssistantConfigDir?: boolean;
+  workers?: number;
+  queueSize?: number;
   configGroups: ConfigBackupOptionGroup[];
 }
 
+export interface HomeAssistantSettings {
+  url?: string;
+  token?: string;
+  attribution?: boolean;
+  attributionWindowSeconds?: number;
+}
+
+export interface RedactionSettings {
+  keys?: string[];
+  patterns?: string[];
+  redactStoredBackups?: boolean;
+}
+
+export interface SourceRoot {
+  name: string;
+  path: string;
+  poll?: boolean;
+}
+
 export interface UpdateSettingsResponse {
   success: boolean;
   warnings?: string[];
@@ -64,8 +176,170 @@ export interface RestoreBackupResponse {
   success: boolean;
   message?: string;
   error?: string;
+  reload?: ReloadResult;
+  check?: ConfigCheckResult;
+}
+
+export interface ConfigCheckResult {
+  valid: boolean;
+  errors?: string;
+  warnings?: string;
+  rolledBack?: boolean;
+  error?: string;
+}
+
+export interface ReloadResult {
+  service: string;
+  success: boolean;
+  error?: string;
+}
+
+export interface ConfigWarning {
+  group: string;
+  path: string;
+  id: string;
+  friendlyName: string;
+  message: string;
 }
 
 export interface ConfigResponse {
   groups: Record<string, ConfigMetadata[]>;
+  warnings?: ConfigWarning[];
+}
+
+export interface BlueprintUsage {
+  group: string;
+  path: string;
+  id: string;
+  friendlyName: string;
+  filenames: string[];
+}
+
+export interface BlueprintUsagesResponse {
+  blueprintPath: string;
+  from: string;
+  to?: string;
+  usages: BlueprintUsage[];
+}
+
+export interface BlueprintVersion {
+  group: string;
+  path: string;
+  id: string;
+  filename: string;
+  date: string;
+}
+
+export interface ConfigBlueprintVersion {
+  filename: string;
+  date: string;
+  blueprintPath?: string;
+  blueprint?: BlueprintVersion;
+}
+
+export interface SecretsFile {
+  group: string;
+  path: string;
+  id: string;
+}
+
+export interface SecretReference {
+  name: string;
+  found: boolean;
+  redacted?: boolean;
+  fingerprint?: string;
+  changed: boolean;
+}
+
+export interface ConfigSecretVersion {
+  filename: string;
+  date: string;
+  secretsFilename?: string;
+  secretsDate?: string;
+  secrets: SecretReference[];
+}
+
+export interface ConfigSecretVersionsResponse {
+  secretsFile?: SecretsFile;
+  versions: ConfigSecretVersion[];
+}
+
+export type RunTrigger = "startup" | "manual" | "scheduled" | "settings";
+
+export interface RunError {
+  group: string;
+  path: string;
+  id?: string;
+  error: string;
+}
+
+export interface BackupRun {
+  id: string;
+  trigger: RunTrigger;
+  status: "running" | "completed" | "cancelled";
+  startedAt: string;
+  finishedAt?: string;
+  configsScanned: number;
+  versionsSaved: number;
+  unchanged: number;
+  renamed: number;
+  deleted: number;
+  retentionDeletions: number;
+  errors: RunError[];
+}
+
+export type ServerEventType =
+  | "version.saved"
+  | "version.attributed"
+  | "versions.removed"
+  | "config.restored"
+  | "settings.updated"
+  | "job.started"
+  | "job.finished"
+  | "resync";
+
+export interface VersionEvent {
+  group: string;
+  path: string;
+  id: string;
+  filename?: string;
+}
+
+export interface VersionsRemovedEvent {
+  group: string;
+  path: string;
+  id: string;
+  removed: number;
+}
+
+export interface ServerEvent {
+  id: number;
+  type: ServerEventType;
+  time: string;
+  data: VersionEvent | VersionsRemovedEvent | BackupRun | Record<string, never>;
+}
+
+export interface QueueMetrics {
+  workers: number;
+  capacity: number;
+  queued: number;
+  maxQueued: number;
+  inProgress: number;
+  enqueued: number;
+  completed: number;
+  dropped: number;
+  blocked: number;
+  blockedMs: number;
+}
+
+export interface ScheduledConfig {
+  group: string;
+  path: string;
+  watched: boolean;
+}
+
+export interface CronScheduleInfo {
+  schedule: string;
+  nextRun?: string;
+  configs: ScheduledConfig[];
 }
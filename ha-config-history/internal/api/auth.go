@@ -0,0 +1,34 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// supervisorIngressIP is the address Home Assistant's Supervisor proxies ingress
+// requests from. Ingress only lets logged in users through.
+const supervisorIngressIP = "172.30.32.2"
+
+// RequireAuthenticatedUser only lets requests through that came from a user logged in to
+// Home Assistant via ingress, or that send token as a bearer token when token is set.
+func RequireAuthenticatedUser(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" {
+			bearer, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if found && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+				c.Next()
+				return
+			}
+		}
+
+		if c.RemoteIP() == supervisorIngressIP && c.GetHeader("X-Remote-User-Id") != "" {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"ha-config-history/internal/core"
 	"ha-config-history/internal/io"
 	"ha-config-history/internal/types"
+	"mime"
 	"net/http"
+	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,7 +17,10 @@ func ListConfigBackupsHandler(s *core.Server) func(c *gin.Context) {
 		configPath := c.Param("path")
 		id := c.Param("id")
 
-		backups, err := io.ListConfigBackups(s.AppSettings.BackupDir, groupSlug, configPath, id)
+		identifier := types.ConfigBackupIdentifier{Path: configPath, ID: id}
+		previousIds := s.HistoryIds(groupSlug, identifier)[1:]
+
+		backups, err := io.ListConfigBackupHistory(s.Settings().BackupDir, groupSlug, configPath, id, previousIds)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": err.Error(),
@@ -29,12 +34,15 @@ func ListConfigBackupsHandler(s *core.Server) func(c *gin.Context) {
 
 func GetConfigBackupHandler(s *core.Server) func(c *gin.Context) {
 	return func(c *gin.Context) {
+		settings := s.Settings()
 		groupSlug := types.GroupSlug(c.Param("group"))
 		configPath := c.Param("path")
 		id := c.Param("id")
 		filename := c.Param("filename")
 
-		content, err := io.GetConfigBackup(s.AppSettings.BackupDir, groupSlug, configPath, id, filename)
+		historyIds := s.HistoryIds(groupSlug, types.ConfigBackupIdentifier{Path: configPath, ID: id})
+
+		content, _, err := io.GetConfigBackupFromHistory(settings.BackupDir, groupSlug, configPath, historyIds, filename)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": err.Error(),
@@ -42,19 +50,36 @@ func GetConfigBackupHandler(s *core.Server) func(c *gin.Context) {
 			return
 		}
 
-		c.Header("Content-Type", "application/x-yaml")
-		c.String(http.StatusOK, string(content))
+		backupType := ""
+		if configOptions := findConfigOptions(s, groupSlug, configPath); configOptions != nil {
+			backupType = configOptions.BackupType
+		}
+
+		// Backups are served from the Home Assistant origin, so the browser must not guess an
+		// active type or render binary files inline
+		c.Header("X-Content-Type-Options", "nosniff")
+		if types.IsBinary(content) {
+			c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(id)}))
+		}
+		c.Data(http.StatusOK, types.BackupContentType(backupType, configPath, id, content), settings.Redaction.Redact(content))
 	}
 }
 
 func DeleteConfigBackupHandler(s *core.Server) func(c *gin.Context) {
 	return func(c *gin.Context) {
+		settings := s.Settings()
 		groupSlug := types.GroupSlug(c.Param("group"))
 		configPath := c.Param("path")
 		id := c.Param("id")
 		filename := c.Param("filename")
 
-		err := io.DeleteBackup(s.AppSettings.BackupDir, groupSlug, configPath, id, filename)
+		// The backup may have been taken before the config was renamed
+		historyIds := s.HistoryIds(groupSlug, types.ConfigBackupIdentifier{Path: configPath, ID: id})
+		if _, foundId, err := io.GetConfigBackupFromHistory(settings.BackupDir, groupSlug, configPath, historyIds, filename); err == nil {
+			id = foundId
+		}
+
+		err := io.DeleteBackup(settings.BackupDir, groupSlug, configPath, id, filename)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
@@ -62,7 +87,7 @@ func DeleteConfigBackupHandler(s *core.Server) func(c *gin.Context) {
 			return
 		}
 
-		metadata, err := io.UpdateMetadataAfterDeletion(s.AppSettings.BackupDir, groupSlug, configPath, id)
+		metadata, err := io.UpdateMetadataAfterDeletion(settings.BackupDir, groupSlug, configPath, id)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
@@ -92,7 +117,7 @@ func DeleteAllConfigBackupsHandler(s *core.Server) func(c *gin.Context) {
 		configPath := c.Param("path")
 		id := c.Param("id")
 
-		err := io.DeleteAllBackups(s.AppSettings.BackupDir, groupSlug, configPath, id)
+		err := io.DeleteAllBackups(s.Settings().BackupDir, groupSlug, configPath, id)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
@@ -0,0 +1,51 @@
+package api_test
+
+import (
+	"ha-config-history/internal/api"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetConfigBackupContentType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		path        string
+		content     []byte
+		expected    string
+		disposition string
+	}{
+		{"html file is served as plain text", "index.html", []byte("<script>alert(document.cookie)</script>"), "text/plain; charset=utf-8", ""},
+		{"javascript file is served as plain text", "card.js", []byte("alert(document.cookie)"), "text/plain; charset=utf-8", ""},
+		{"yaml file keeps its type", "configuration.yaml", []byte("homeassistant:\n"), "application/x-yaml", ""},
+		{"binary file is downloaded", "logo.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png", `attachment; filename=logo.png`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupSingleFileEnv(t, tt.path)
+			env.router.GET("/configs/:group/:path/:id/backups/:filename", api.GetConfigBackupHandler(env.server))
+			env.createBackup("test-configs", tt.path, tt.path, "20240101T120000.backup", tt.content)
+
+			w := httptest.NewRecorder()
+			env.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/test-configs/"+tt.path+"/"+tt.path+"/backups/20240101T120000.backup", nil))
+			env.assertStatusOK(w)
+
+			contentType := w.Header().Get("Content-Type")
+			if contentType != tt.expected || strings.HasPrefix(contentType, "text/html") {
+				t.Errorf("Expected Content-Type %q, got %q", tt.expected, contentType)
+			}
+			if nosniff := w.Header().Get("X-Content-Type-Options"); nosniff != "nosniff" {
+				t.Errorf("Expected X-Content-Type-Options nosniff, got %q", nosniff)
+			}
+			if disposition := w.Header().Get("Content-Disposition"); disposition != tt.disposition {
+				t.Errorf("Expected Content-Disposition %q, got %q", tt.disposition, disposition)
+			}
+		})
+	}
+}
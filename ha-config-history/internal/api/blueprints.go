@@ -0,0 +1,280 @@
+package api
+
+import (
+	"ha-config-history/internal/core"
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BlueprintUsage is an automation or script that used a blueprint version.
+type BlueprintUsage struct {
+	Group        types.GroupSlug `json:"group"`
+	Path         string          `json:"path"`
+	ID           string          `json:"id"`
+	FriendlyName string          `json:"friendlyName"`
+	// Filenames are the versions of the config that used the blueprint while the
+	// blueprint version was current, newest first
+	Filenames []string `json:"filenames"`
+}
+
+type BlueprintUsagesResponse struct {
+	BlueprintPath string           `json:"blueprintPath"`
+	From          time.Time        `json:"from"`
+	To            *time.Time       `json:"to,omitempty"`
+	Usages        []BlueprintUsage `json:"usages"`
+}
+
+// BlueprintVersion is the blueprint version that was current when a version of an
+// automation or script was saved.
+type BlueprintVersion struct {
+	Group    types.GroupSlug `json:"group"`
+	Path     string          `json:"path"`
+	ID       string          `json:"id"`
+	Filename string          `json:"filename"`
+	Date     time.Time       `json:"date"`
+}
+
+type ConfigBlueprintVersion struct {
+	Filename      string            `json:"filename"`
+	Date          time.Time         `json:"date"`
+	BlueprintPath string            `json:"blueprintPath,omitempty"`
+	Blueprint     *BlueprintVersion `json:"blueprint,omitempty"`
+}
+
+// GetBlueprintUsagesHandler lists the automations and scripts that used a blueprint
+// while the given version of it was current.
+func GetBlueprintUsagesHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		settings := s.Settings()
+		groupSlug := types.GroupSlug(c.Param("group"))
+		configPath := c.Param("path")
+		id := c.Param("id")
+		filename := c.Param("filename")
+
+		configOptions := findConfigOptions(s, groupSlug, configPath)
+		if configOptions == nil || configOptions.BackupType != "blueprint" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Blueprint config not found"})
+			return
+		}
+
+		blueprintPath, ok := usedBlueprintPathFor(configPath, id)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Blueprint is not in an automation or script blueprint folder"})
+			return
+		}
+
+		backups, err := io.ListConfigBackups(settings.BackupDir, groupSlug, configPath, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		index := slices.IndexFunc(backups, func(backup io.BackupInfo) bool {
+			return backup.Filename == filename
+		})
+		if index == -1 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+			return
+		}
+
+		response := BlueprintUsagesResponse{
+			BlueprintPath: blueprintPath,
+			From:          backups[index].Date,
+			Usages:        []BlueprintUsage{},
+		}
+		if index > 0 {
+			response.To = &backups[index-1].Date
+		}
+
+		for _, configGroup := range settings.ConfigGroups {
+			for _, summary := range blueprintUserSummaries(s, configGroup) {
+				usage := findBlueprintUsage(s, configGroup.Slug, summary, blueprintPath, response.From, response.To)
+				if usage != nil {
+					response.Usages = append(response.Usages, *usage)
+				}
+			}
+		}
+
+		slices.SortFunc(response.Usages, func(a, b BlueprintUsage) int {
+			return strings.Compare(string(a.Group)+a.Path+a.ID, string(b.Group)+b.Path+b.ID)
+		})
+
+		c.IndentedJSON(http.StatusOK, response)
+	}
+}
+
+// GetConfigBlueprintVersionsHandler lists the versions of an automation or script with
+// the blueprint version that was current when each was saved.
+func GetConfigBlueprintVersionsHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		settings := s.Settings()
+		groupSlug := types.GroupSlug(c.Param("group"))
+		configPath := c.Param("path")
+		id := c.Param("id")
+
+		historyIds := s.HistoryIds(groupSlug, types.ConfigBackupIdentifier{Path: configPath, ID: id})
+		backups, err := io.ListConfigBackupHistory(settings.BackupDir, groupSlug, configPath, id, historyIds[1:])
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		blueprintBackups := map[types.ConfigBackupIdentifier][]io.BackupInfo{}
+		versions := []ConfigBlueprintVersion{}
+
+		for _, backup := range backups {
+			version := ConfigBlueprintVersion{Filename: backup.Filename, Date: backup.Date}
+
+			content, err := io.GetConfigBackup(settings.BackupDir, groupSlug, configPath, backup.ID, backup.Filename)
+			if err != nil {
+				slog.Warn("Failed to read backup for blueprint lookup", "id", backup.ID, "filename", backup.Filename, "error", err)
+				versions = append(versions, version)
+				continue
+			}
+
+			blueprintPath, ok := types.UsedBlueprintPath(content)
+			if !ok {
+				versions = append(versions, version)
+				continue
+			}
+			version.BlueprintPath = blueprintPath
+
+			blueprintGroup, identifier, found := findTrackedBlueprint(s, blueprintPath)
+			if found {
+				if _, listed := blueprintBackups[identifier]; !listed {
+					blueprintBackups[identifier], _ = io.ListConfigBackups(settings.BackupDir, blueprintGroup, identifier.Path, identifier.ID)
+				}
+				if current, ok := io.BackupAt(blueprintBackups[identifier], backup.Date); ok {
+					version.Blueprint = &BlueprintVersion{
+						Group:    blueprintGroup,
+						Path:     identifier.Path,
+						ID:       identifier.ID,
+						Filename: current.Filename,
+						Date:     current.Date,
+					}
+				}
+			}
+
+			versions = append(versions, version)
+		}
+
+		c.IndentedJSON(http.StatusOK, versions)
+	}
+}
+
+// usedBlueprintPathFor returns the path automations and scripts use to reference a
+// tracked blueprint, which is relative to its blueprints/<domain> folder.
+func usedBlueprintPathFor(configPath, id string) (string, bool) {
+	fullPath := filepath.Join(configPath, types.BlueprintRelativePath(id))
+	for _, domain := range types.BlueprintDomains {
+		relativePath, err := filepath.Rel(filepath.Join("blueprints", domain), fullPath)
+		if err == nil && !strings.HasPrefix(relativePath, "..") {
+			return filepath.ToSlash(relativePath), true
+		}
+	}
+	return "", false
+}
+
+// findTrackedBlueprint finds the tracked blueprint that a use_blueprint path refers to.
+func findTrackedBlueprint(s *core.Server, blueprintPath string) (types.GroupSlug, types.ConfigBackupIdentifier, bool) {
+	s.State.Mu.RLock()
+	defer s.State.Mu.RUnlock()
+
+	for _, configGroup := range s.Settings().ConfigGroups {
+		for _, config := range configGroup.Configs {
+			if config.BackupType != "blueprint" {
+				continue
+			}
+
+			for _, domain := range types.BlueprintDomains {
+				relativePath, err := filepath.Rel(config.Path, filepath.Join("blueprints", domain, blueprintPath))
+				if err != nil || strings.HasPrefix(relativePath, "..") {
+					continue
+				}
+
+				identifier := types.ConfigBackupIdentifier{Path: config.Path, ID: types.BlueprintId(relativePath)}
+				if _, exists := s.State.CachedBackupSummaries[configGroup.Slug][identifier]; exists {
+					return configGroup.Slug, identifier, true
+				}
+			}
+		}
+	}
+
+	return "", types.ConfigBackupIdentifier{}, false
+}
+
+// blueprintUserSummaries returns the configs in a group that can use blueprints: entries of
+// multiple and keyed files such as automations.yaml and scripts.yaml.
+func blueprintUserSummaries(s *core.Server, configGroup *types.ConfigBackupOptionGroup) []*types.BackupConfigSummary {
+	s.State.Mu.RLock()
+	defer s.State.Mu.RUnlock()
+
+	summaries := []*types.BackupConfigSummary{}
+	for identifier, summary := range s.State.CachedBackupSummaries[configGroup.Slug] {
+		for _, config := range configGroup.Configs {
+			if config.Path == identifier.Path && (config.BackupType == "multiple" || config.BackupType == "keyed") {
+				summaries = append(summaries, summary)
+				break
+			}
+		}
+	}
+	return summaries
+}
+
+// findBlueprintUsage returns the versions of a config that used blueprintPath while they
+// were current at some point between from and to, or nil when none did.
+func findBlueprintUsage(
+	s *core.Server,
+	groupSlug types.GroupSlug,
+	summary *types.BackupConfigSummary,
+	blueprintPath string,
+	from time.Time,
+	to *time.Time,
+) *BlueprintUsage {
+	settings := s.Settings()
+	backups, err := io.ListConfigBackups(settings.BackupDir, groupSlug, summary.Path, summary.ID)
+	if err != nil {
+		return nil
+	}
+
+	usage := &BlueprintUsage{
+		Group:        groupSlug,
+		Path:         summary.Path,
+		ID:           summary.ID,
+		FriendlyName: summary.FriendlyName,
+		Filenames:    []string{},
+	}
+
+	for index, backup := range backups {
+		// A version is current from its date until the next (newer) version
+		var replacedAt *time.Time
+		if index > 0 {
+			replacedAt = &backups[index-1].Date
+		}
+		overlaps := (to == nil || backup.Date.Before(*to)) && (replacedAt == nil || replacedAt.After(from))
+		if !overlaps {
+			continue
+		}
+
+		content, err := io.GetConfigBackup(settings.BackupDir, groupSlug, summary.Path, summary.ID, backup.Filename)
+		if err != nil {
+			continue
+		}
+		if usedPath, ok := types.UsedBlueprintPath(content); ok && usedPath == blueprintPath {
+			usage.Filenames = append(usage.Filenames, backup.Filename)
+		}
+	}
+
+	if len(usage.Filenames) == 0 {
+		return nil
+	}
+	return usage
+}
@@ -0,0 +1,116 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ha-config-history/internal/api"
+	"ha-config-history/internal/core"
+	"ha-config-history/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBlueprintCrossReferences(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, backupDir, haConfigDir := setupTestDirs(t)
+
+	blueprintId := "automation~homeassistant~motion_light.yaml"
+	writeVersions := func(group, path, id string, versions map[string]string) {
+		configDir := filepath.Join(backupDir, group, path, id)
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			t.Fatalf("Failed to create backup directory: %v", err)
+		}
+		for filename, content := range versions {
+			if err := os.WriteFile(filepath.Join(configDir, filename), []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write backup: %v", err)
+			}
+		}
+		metadata, _ := json.Marshal(types.BackupConfigSummary{
+			ConfigBackupIdentifier: types.ConfigBackupIdentifier{Path: path, ID: id},
+			FriendlyName:           id,
+			BackupCount:            len(versions),
+		})
+		if err := os.WriteFile(filepath.Join(configDir, "metadata.json"), metadata, 0644); err != nil {
+			t.Fatalf("Failed to write metadata: %v", err)
+		}
+	}
+
+	usesBlueprint := "id: porch\nuse_blueprint:\n  path: homeassistant/motion_light.yaml\n"
+	writeVersions("blueprints", "blueprints", blueprintId, map[string]string{
+		"20250101T000000.backup": "blueprint:\n  name: Motion v1\n",
+		"20250201T000000.backup": "blueprint:\n  name: Motion v2\n",
+	})
+	writeVersions("automations", "automations.yaml", "porch", map[string]string{
+		"20250110T000000.backup": usesBlueprint,
+		"20250115T000000.backup": "id: porch\ntrigger: []\n",
+		"20250210T000000.backup": usesBlueprint,
+	})
+	writeVersions("automations", "automations.yaml", "hall", map[string]string{
+		"20250120T000000.backup": "id: hall\ntrigger: []\n",
+	})
+
+	server := core.NewServer(&types.AppSettings{
+		HomeAssistantConfigDir: haConfigDir,
+		BackupDir:              backupDir,
+		ConfigGroups: []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup("Blueprints", []*types.ConfigBackupOptions{
+				types.NewBlueprintConfigBackupOptions("blueprints"),
+			}),
+			types.NewConfigBackupOptionGroup("Automations", []*types.ConfigBackupOptions{
+				types.NewMultipleConfigBackupOptions("automations.yaml", "id", "alias"),
+			}),
+		},
+	}, "tmp/test-config.json")
+
+	router := gin.New()
+	router.GET("/configs/:group/:path/:id/backups/:filename/blueprint-usages", api.GetBlueprintUsagesHandler(server))
+	router.GET("/configs/:group/:path/:id/blueprint-versions", api.GetConfigBlueprintVersionsHandler(server))
+
+	get := func(t *testing.T, url string, response any) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+	}
+
+	t.Run("lists the automation versions that used a blueprint version", func(t *testing.T) {
+		var response api.BlueprintUsagesResponse
+		get(t, "/configs/blueprints/blueprints/"+blueprintId+"/backups/20250101T000000.backup/blueprint-usages", &response)
+
+		if response.BlueprintPath != "homeassistant/motion_light.yaml" {
+			t.Errorf("Unexpected blueprint path %s", response.BlueprintPath)
+		}
+		if len(response.Usages) != 1 || response.Usages[0].ID != "porch" {
+			t.Fatalf("Expected only porch to use the blueprint, got %+v", response.Usages)
+		}
+		if filenames := response.Usages[0].Filenames; len(filenames) != 1 || filenames[0] != "20250110T000000.backup" {
+			t.Errorf("Unexpected porch versions %v", filenames)
+		}
+	})
+
+	t.Run("shows the blueprint version current for each automation version", func(t *testing.T) {
+		var versions []api.ConfigBlueprintVersion
+		get(t, "/configs/automations/automations.yaml/porch/blueprint-versions", &versions)
+
+		if len(versions) != 3 {
+			t.Fatalf("Expected 3 versions, got %d", len(versions))
+		}
+		if versions[0].Blueprint == nil || versions[0].Blueprint.Filename != "20250201T000000.backup" {
+			t.Errorf("Expected newest version to use the second blueprint version, got %+v", versions[0].Blueprint)
+		}
+		if versions[1].Blueprint != nil || versions[1].BlueprintPath != "" {
+			t.Errorf("Expected middle version to not use a blueprint, got %+v", versions[1])
+		}
+		if versions[2].Blueprint == nil || versions[2].Blueprint.Filename != "20250101T000000.backup" {
+			t.Errorf("Expected oldest version to use the first blueprint version, got %+v", versions[2].Blueprint)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ha-config-history/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRestoreChecksConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	groupSlug, path, id := "test-configs", "configuration.yaml", "configuration.yaml"
+	valid := []byte("homeassistant:\n  name: Home\n")
+
+	// setup restores configuration.yaml with a stand-in for Home Assistant whose config
+	// check fails while the file contains "broken".
+	setup := func(t *testing.T, current []byte) *testEnvironment {
+		env := setupSingleFileEnv(t, path)
+		homeAssistant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/config/core/check_config" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			content, _ := os.ReadFile(env.targetFile)
+			if strings.Contains(string(content), "broken") {
+				json.NewEncoder(w).Encode(map[string]any{"result": "invalid", "errors": "Invalid config for 'homeassistant' at configuration.yaml, line 2"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"result": "valid"})
+		}))
+		t.Cleanup(homeAssistant.Close)
+
+		checkConfig := true
+		env.server.AppSettings.HomeAssistant = &types.HomeAssistantSettings{URL: homeAssistant.URL, Token: "test-token"}
+		env.server.AppSettings.ConfigGroups[0].Configs[0].CheckConfigOnRestore = &checkConfig
+
+		env.writeFile(env.targetFile, current, 0644)
+		env.createBackup(groupSlug, path, id, "20240101T120000.yaml", valid)
+		env.createBackup(groupSlug, path, id, "20240102T120000.yaml", []byte("homeassistant:\n  broken: [\n"))
+		return env
+	}
+
+	t.Run("keeps a restore that passes the check", func(t *testing.T) {
+		env := setup(t, []byte("homeassistant:\n  name: Old\n"))
+
+		w, response := env.makeRestoreRequest(groupSlug, path, id, "20240101T120000.yaml")
+		env.assertStatusOK(w)
+		env.assertRestoreSuccess(response)
+		env.assertContentEquals(valid, env.readFile(env.targetFile))
+
+		if response.Check == nil || !response.Check.Valid {
+			t.Errorf("Expected a valid check, got %+v", response.Check)
+		}
+	})
+
+	t.Run("rolls back a restore that fails the check", func(t *testing.T) {
+		env := setup(t, valid)
+
+		w, response := env.makeRestoreRequest(groupSlug, path, id, "20240102T120000.yaml")
+		if w.Code != http.StatusUnprocessableEntity || response.Success {
+			t.Fatalf("Expected the restore to be refused, got %d %+v", w.Code, response)
+		}
+		env.assertContentEquals(valid, env.readFile(env.targetFile))
+
+		if response.Check == nil || !response.Check.RolledBack || !strings.Contains(response.Check.Errors, "line 2") {
+			t.Errorf("Expected the rollback and validation errors to be reported, got %+v", response.Check)
+		}
+	})
+
+	t.Run("keeps a restore when the config was already broken", func(t *testing.T) {
+		broken := []byte("homeassistant:\n  broken: true\n")
+		env := setup(t, broken)
+
+		w, response := env.makeRestoreRequest(groupSlug, path, id, "20240102T120000.yaml")
+		env.assertStatusOK(w)
+		env.assertRestoreSuccess(response)
+
+		if response.Check == nil || response.Check.Valid || response.Check.RolledBack {
+			t.Errorf("Expected a failed check without a rollback, got %+v", response.Check)
+		}
+	})
+}
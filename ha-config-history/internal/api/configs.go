@@ -1,17 +1,30 @@
 package api
 
 import (
+	"fmt"
 	"ha-config-history/internal/core"
 	"ha-config-history/internal/types"
 	"maps"
 	"net/http"
 	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ConfigResponse struct {
-	Groups map[types.GroupSlug][]*types.BackupConfigSummary `json:"groups"`
+	Groups   map[types.GroupSlug][]*types.BackupConfigSummary `json:"groups"`
+	Warnings []ConfigWarning                                  `json:"warnings,omitempty"`
+}
+
+// ConfigWarning flags a tracked config that needs attention, such as an entry
+// without an id that is tracked under a fallback id.
+type ConfigWarning struct {
+	Group        types.GroupSlug `json:"group"`
+	Path         string          `json:"path"`
+	ID           string          `json:"id"`
+	FriendlyName string          `json:"friendlyName"`
+	Message      string          `json:"message"`
 }
 
 func GetConfigsHandler(s *core.Server) func(c *gin.Context) {
@@ -20,27 +33,46 @@ func GetConfigsHandler(s *core.Server) func(c *gin.Context) {
 		defer s.State.Mu.RUnlock()
 
 		groups := make(map[types.GroupSlug][]*types.BackupConfigSummary)
+		warnings := []ConfigWarning{}
 
-		for _, configGroup := range s.AppSettings.ConfigGroups {
+		for _, configGroup := range s.Settings().ConfigGroups {
 			groupConfigs := make([]*types.BackupConfigSummary, 0)
 			if groupSummaries, exists := s.State.CachedBackupSummaries[configGroup.Slug]; exists {
-				groupConfigs = slices.Collect(maps.Values(groupSummaries))
+				// Renamed configs are listed under their new id, which carries their history
+				groupConfigs = slices.DeleteFunc(slices.Collect(maps.Values(groupSummaries)), func(summary *types.BackupConfigSummary) bool {
+					return summary.RenamedTo != ""
+				})
+			}
+
+			for _, summary := range groupConfigs {
+				if summary.MissingId {
+					warnings = append(warnings, ConfigWarning{
+						Group:        configGroup.Slug,
+						Path:         summary.Path,
+						ID:           summary.ID,
+						FriendlyName: summary.FriendlyName,
+						Message:      fmt.Sprintf("Entry in %s has no id and is tracked as %s", summary.Path, summary.ID),
+					})
+				}
 			}
 
 			groups[configGroup.Slug] = groupConfigs
 		}
 
+		slices.SortFunc(warnings, func(a, b ConfigWarning) int {
+			return strings.Compare(string(a.Group)+a.Path+a.ID, string(b.Group)+b.Path+b.ID)
+		})
+
 		c.IndentedJSON(http.StatusOK, ConfigResponse{
-			Groups: groups,
+			Groups:   groups,
+			Warnings: warnings,
 		})
 	}
 }
 
 func ProcessConfigsHandler(s *core.Server) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		s.ProcessAllConfigOptions()
-		c.JSON(http.StatusOK, gin.H{
-			"status": "backup process completed",
-		})
+		run := s.StartRun(core.RunTriggerManual)
+		c.JSON(http.StatusAccepted, run.Snapshot())
 	}
 }
@@ -0,0 +1,108 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ha-config-history/internal/api"
+	"ha-config-history/internal/core"
+	"ha-config-history/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRestoreDeletedConfigHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	groupSlug, path := "test-automations", "automations.yaml"
+
+	setup := func(t *testing.T, deleted bool) (*core.Server, *gin.Engine, string) {
+		_, backupDir, haConfigDir := setupTestDirs(t)
+		targetFile := filepath.Join(haConfigDir, path)
+
+		if err := os.WriteFile(targetFile, []byte("- id: kept\n  alias: Kept\n"), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+
+		configDir := filepath.Join(backupDir, groupSlug, path, "removed")
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			t.Fatalf("Failed to create backup directory: %v", err)
+		}
+		backup := []byte("id: removed\nalias: Removed\n")
+		if err := os.WriteFile(filepath.Join(configDir, "20250101T120000.backup"), backup, 0644); err != nil {
+			t.Fatalf("Failed to write backup: %v", err)
+		}
+
+		deletedAt := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+		metadata := types.BackupConfigSummary{
+			ConfigBackupIdentifier: types.ConfigBackupIdentifier{Path: path, ID: "removed"},
+			FriendlyName:           "Removed",
+			BackupCount:            1,
+			BackupType:             "multiple",
+			Deleted:                deleted,
+		}
+		if deleted {
+			metadata.DeletedAt = &deletedAt
+		}
+		metadataBlob, _ := json.Marshal(metadata)
+		if err := os.WriteFile(filepath.Join(configDir, "metadata.json"), metadataBlob, 0644); err != nil {
+			t.Fatalf("Failed to write metadata: %v", err)
+		}
+
+		server := core.NewServer(&types.AppSettings{
+			HomeAssistantConfigDir: haConfigDir,
+			BackupDir:              backupDir,
+			ConfigGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup("Test Automations", []*types.ConfigBackupOptions{
+					types.NewMultipleConfigBackupOptions(path, "id", "alias"),
+				}),
+			},
+		}, "tmp/test-config.json")
+
+		router := gin.New()
+		router.POST("/configs/:group/:path/:id/restore", api.RestoreDeletedConfigHandler(server))
+		return server, router, targetFile
+	}
+
+	restore := func(router *gin.Engine) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/configs/"+groupSlug+"/"+path+"/removed/restore", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("puts the last version back into the file", func(t *testing.T) {
+		server, router, targetFile := setup(t, true)
+
+		w := restore(router)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+
+		content := readFile(t, targetFile)
+		expected := "- id: kept\n  alias: Kept\n- id: removed\n  alias: Removed\n"
+		if string(content) != expected {
+			t.Errorf("Unexpected file content:\n%s", content)
+		}
+
+		server.WaitForInactive()
+		server.State.Mu.RLock()
+		defer server.State.Mu.RUnlock()
+		summary := server.State.CachedBackupSummaries[types.GroupSlug(groupSlug)][types.ConfigBackupIdentifier{Path: path, ID: "removed"}]
+		if summary.Deleted || summary.DeletedAt != nil {
+			t.Errorf("Expected deletion to be cleared, got %+v", summary)
+		}
+	})
+
+	t.Run("rejects configs that are not deleted", func(t *testing.T) {
+		_, router, _ := setup(t, false)
+
+		if w := restore(router); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", w.Code)
+		}
+	})
+}
@@ -14,36 +14,70 @@ import (
 )
 
 type BackupDiffResponse struct {
-	Type          string `json:"type"`
-	UnifiedDiff   string `json:"unifiedDiff"`
-	Content       string `json:"content"`
-	OldContent    string `json:"oldContent"`
-	NewContent    string `json:"newContent"`
-	OldFilename   string `json:"oldFilename"`
-	NewFilename   string `json:"newFilename"`
-	IsFirstBackup bool   `json:"isFirstBackup"`
+	Type          string      `json:"type"` // "diff", or "binary" when either side is binary
+	UnifiedDiff   string      `json:"unifiedDiff"`
+	Content       string      `json:"content"`
+	OldContent    string      `json:"oldContent"`
+	NewContent    string      `json:"newContent"`
+	OldFilename   string      `json:"oldFilename"`
+	NewFilename   string      `json:"newFilename"`
+	IsFirstBackup bool        `json:"isFirstBackup"`
+	Binary        *BinaryDiff `json:"binary,omitempty"`
+}
+
+// BinaryDiff describes how a binary backup changed, in place of a text diff.
+type BinaryDiff struct {
+	Changed     bool   `json:"changed"`
+	OldSize     int    `json:"oldSize"`
+	NewSize     int    `json:"newSize"`
+	SizeDelta   int    `json:"sizeDelta"`
+	OldHash     string `json:"oldHash"`
+	NewHash     string `json:"newHash"`
+	OldMimeType string `json:"oldMimeType"`
+	NewMimeType string `json:"newMimeType"`
 }
 
 func GetBackupDiffHandler(s *core.Server) func(c *gin.Context) {
 	return func(c *gin.Context) {
+		settings := s.Settings()
 		groupSlug := types.GroupSlug(c.Param("group"))
 		configPath := c.Param("path")
 		id := c.Param("id")
 		leftFilename := c.Param("left")
 		rightFilename := c.Param("right")
 
-		leftContent, err := io.GetConfigBackup(s.AppSettings.BackupDir, groupSlug, configPath, id, leftFilename)
+		historyIds := s.HistoryIds(groupSlug, types.ConfigBackupIdentifier{Path: configPath, ID: id})
+
+		leftContent, _, err := io.GetConfigBackupFromHistory(settings.BackupDir, groupSlug, configPath, historyIds, leftFilename)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Error loading left backup file"})
 			return
 		}
 
-		rightContent, err := io.GetConfigBackup(s.AppSettings.BackupDir, groupSlug, configPath, id, rightFilename)
+		rightContent, _, err := io.GetConfigBackupFromHistory(settings.BackupDir, groupSlug, configPath, historyIds, rightFilename)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Error loading right backup file"})
 			return
 		}
 
+		if types.IsBinary(leftContent) || types.IsBinary(rightContent) {
+			backupType := ""
+			if configOptions := findConfigOptions(s, groupSlug, configPath); configOptions != nil {
+				backupType = configOptions.BackupType
+			}
+
+			c.JSON(http.StatusOK, BackupDiffResponse{
+				Type:        "binary",
+				OldFilename: leftFilename,
+				NewFilename: rightFilename,
+				Binary:      newBinaryDiff(backupType, configPath, id, leftContent, rightContent),
+			})
+			return
+		}
+
+		leftContent = settings.Redaction.Redact(leftContent)
+		rightContent = settings.Redaction.Redact(rightContent)
+
 		edits := myers.ComputeEdits(span.URIFromPath(leftFilename), string(leftContent), string(rightContent))
 		diff := fmt.Sprint(gotextdiff.ToUnified(leftFilename, rightFilename, string(leftContent), edits))
 
@@ -58,3 +92,19 @@ func GetBackupDiffHandler(s *core.Server) func(c *gin.Context) {
 		})
 	}
 }
+
+func newBinaryDiff(backupType, configPath, id string, oldContent, newContent []byte) *BinaryDiff {
+	oldHash := types.HashByteSlice(oldContent)
+	newHash := types.HashByteSlice(newContent)
+
+	return &BinaryDiff{
+		Changed:     oldHash != newHash,
+		OldSize:     len(oldContent),
+		NewSize:     len(newContent),
+		SizeDelta:   len(newContent) - len(oldContent),
+		OldHash:     oldHash,
+		NewHash:     newHash,
+		OldMimeType: types.BackupContentType(backupType, configPath, id, oldContent),
+		NewMimeType: types.BackupContentType(backupType, configPath, id, newContent),
+	}
+}
@@ -0,0 +1,57 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ha-config-history/internal/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBinaryBackups(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	oldImage := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR-old")
+	newImage := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR-newer")
+
+	env := setupSingleFileEnv(t, "logo.png")
+	env.router.GET("/configs/:group/:path/:id/backups/:filename", api.GetConfigBackupHandler(env.server))
+	env.router.GET("/configs/:group/:path/:id/compare/:left/diff/:right", api.GetBackupDiffHandler(env.server))
+	env.createBackup("test-configs", "logo.png", "logo.png", "20240101T120000.backup", oldImage)
+	env.createBackup("test-configs", "logo.png", "logo.png", "20240102T120000.backup", newImage)
+
+	t.Run("backups are served with their content type", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/test-configs/logo.png/logo.png/backups/20240101T120000.backup", nil))
+
+		env.assertStatusOK(w)
+		if contentType := w.Header().Get("Content-Type"); contentType != "image/png" {
+			t.Errorf("Expected image/png, got %s", contentType)
+		}
+		env.assertContentEquals(oldImage, w.Body.Bytes())
+	})
+
+	t.Run("diff describes the change instead of a text diff", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/test-configs/logo.png/logo.png/compare/20240101T120000.backup/diff/20240102T120000.backup", nil))
+		env.assertStatusOK(w)
+
+		var response api.BackupDiffResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+
+		if response.Type != "binary" || response.UnifiedDiff != "" || response.Binary == nil {
+			t.Fatalf("Expected a binary diff, got %+v", response)
+		}
+		binary := response.Binary
+		if !binary.Changed || binary.SizeDelta != 2 || binary.OldSize != len(oldImage) || binary.OldHash == binary.NewHash {
+			t.Errorf("Unexpected binary diff %+v", binary)
+		}
+		if binary.OldMimeType != "image/png" || binary.NewMimeType != "image/png" {
+			t.Errorf("Expected png MIME types, got %s and %s", binary.OldMimeType, binary.NewMimeType)
+		}
+	})
+}
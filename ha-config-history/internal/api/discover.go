@@ -0,0 +1,173 @@
+package api
+
+import (
+	"fmt"
+	"ha-config-history/internal/core"
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DiscoverResponse struct {
+	Proposals []*types.DiscoveredConfig `json:"proposals"`
+}
+
+type AcceptDiscoveredRequest struct {
+	Groups []*types.ConfigBackupOptionGroup `json:"groups"`
+}
+
+type AcceptDiscoveredResponse struct {
+	Success bool   `json:"success"`
+	Added   int    `json:"added"`
+	Error   string `json:"error,omitempty"`
+}
+
+func DiscoverConfigsHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		settings := s.Settings()
+		proposals, err := io.DiscoverConfigs(settings.HomeAssistantConfigDir)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		for _, proposal := range proposals {
+			proposal.AlreadyTracked = isTracked(settings.ConfigGroups, proposal.Group)
+		}
+
+		c.IndentedJSON(http.StatusOK, DiscoverResponse{
+			Proposals: proposals,
+		})
+	}
+}
+
+func AcceptDiscoveredConfigsHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request AcceptDiscoveredRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, AcceptDiscoveredResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid request format: %v", err),
+			})
+			return
+		}
+
+		settings := s.Settings()
+		mergedGroups, added := mergeConfigGroups(settings.ConfigGroups, request.Groups)
+		if err := validateConfigGroups(mergedGroups); err != nil {
+			c.JSON(http.StatusBadRequest, AcceptDiscoveredResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid config groups: %v", err),
+			})
+			return
+		}
+
+		newSettings := *settings
+		newSettings.ConfigGroups = mergedGroups
+
+		if err := validateSourceRoots(&newSettings); err != nil {
+			c.JSON(http.StatusBadRequest, AcceptDiscoveredResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid source roots: %v", err),
+			})
+			return
+		}
+
+		if err := types.SaveAppSettings(s.ConfigPath, &newSettings); err != nil {
+			c.JSON(http.StatusInternalServerError, AcceptDiscoveredResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Failed to save settings file: %v", err),
+			})
+			return
+		}
+
+		s.ApplySettings(&newSettings)
+
+		slog.Info("Accepted discovered configs", "added", added)
+
+		c.JSON(http.StatusOK, AcceptDiscoveredResponse{
+			Success: true,
+			Added:   added,
+		})
+	}
+}
+
+// isTracked reports whether every config in the proposed group is already
+// tracked by one of the existing groups.
+func isTracked(existing []*types.ConfigBackupOptionGroup, proposed *types.ConfigBackupOptionGroup) bool {
+	for _, config := range proposed.Configs {
+		if !containsConfig(existing, config) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsConfig(groups []*types.ConfigBackupOptionGroup, config *types.ConfigBackupOptions) bool {
+	for _, group := range groups {
+		for _, existing := range group.Configs {
+			if existing.Path == config.Path && existing.BackupType == config.BackupType && existing.RootName() == config.RootName() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mergeConfigGroups adds the proposed groups to a copy of the existing groups,
+// appending to groups with the same name and skipping configs that are already
+// tracked. It returns the merged groups and the number of configs added.
+func mergeConfigGroups(existing, proposed []*types.ConfigBackupOptionGroup) ([]*types.ConfigBackupOptionGroup, int) {
+	merged := make([]*types.ConfigBackupOptionGroup, 0, len(existing)+len(proposed))
+	for _, group := range existing {
+		copied := *group
+		copied.Configs = append([]*types.ConfigBackupOptions{}, group.Configs...)
+		merged = append(merged, &copied)
+	}
+
+	added := 0
+	created := map[*types.ConfigBackupOptionGroup]bool{}
+	for _, group := range proposed {
+		if group == nil {
+			continue
+		}
+
+		var target *types.ConfigBackupOptionGroup
+		for _, candidate := range merged {
+			if candidate.Name == group.Name {
+				target = candidate
+				break
+			}
+		}
+
+		if target == nil {
+			target = types.NewConfigBackupOptionGroup(group.Name, []*types.ConfigBackupOptions{})
+			merged = append(merged, target)
+			created[target] = true
+		}
+
+		for _, config := range group.Configs {
+			if config == nil || containsConfig(merged, config) {
+				continue
+			}
+			target.Configs = append(target.Configs, config)
+			added++
+		}
+	}
+
+	// Drop proposed groups that ended up with nothing new to track. Existing groups are
+	// kept even when they are empty.
+	result := merged[:0]
+	for _, group := range merged {
+		if !created[group] || len(group.Configs) > 0 {
+			result = append(result, group)
+		}
+	}
+
+	return result, added
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"ha-config-history/internal/types"
+	"testing"
+)
+
+func TestMergeConfigGroups(t *testing.T) {
+	existing := []*types.ConfigBackupOptionGroup{
+		types.NewConfigBackupOptionGroup("Automations", []*types.ConfigBackupOptions{
+			types.NewMultipleConfigBackupOptions("automations.yaml", "id", "alias"),
+		}),
+	}
+
+	t.Run("appends new configs to a group with the same name", func(t *testing.T) {
+		proposed := []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup("Automations", []*types.ConfigBackupOptions{
+				types.NewMultipleConfigBackupOptions("automations.yaml", "id", "alias"),
+				types.NewMultipleConfigBackupOptions("more_automations.yaml", "id", "alias"),
+			}),
+		}
+
+		merged, added := mergeConfigGroups(existing, proposed)
+		if added != 1 {
+			t.Errorf("expected 1 config added, got %d", added)
+		}
+		if len(merged) != 1 || len(merged[0].Configs) != 2 {
+			t.Fatalf("expected one group with two configs, got %+v", merged)
+		}
+		if len(existing[0].Configs) != 1 {
+			t.Error("existing groups were modified")
+		}
+	})
+
+	t.Run("adds new groups with a slug", func(t *testing.T) {
+		proposed := []*types.ConfigBackupOptionGroup{
+			{Name: "ESP Home", Configs: []*types.ConfigBackupOptions{
+				types.NewDirectoryConfigBackupOptions("esphome", []string{"*.yaml"}, nil),
+			}},
+		}
+
+		merged, added := mergeConfigGroups(existing, proposed)
+		if added != 1 || len(merged) != 2 {
+			t.Fatalf("expected a new group with one config, got %d groups and %d added", len(merged), added)
+		}
+		if merged[1].Slug != "esp-home" {
+			t.Errorf("expected slug esp-home, got %q", merged[1].Slug)
+		}
+	})
+
+	t.Run("skips proposals that are already tracked", func(t *testing.T) {
+		proposed := []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup("Other", []*types.ConfigBackupOptions{
+				types.NewMultipleConfigBackupOptions("automations.yaml", "id", "alias"),
+			}),
+		}
+
+		merged, added := mergeConfigGroups(existing, proposed)
+		if added != 0 || len(merged) != 1 {
+			t.Errorf("expected nothing to be added, got %d groups and %d added", len(merged), added)
+		}
+		if isTracked(existing, proposed[0]) != true {
+			t.Error("expected proposal to be reported as tracked")
+		}
+	})
+
+	t.Run("keeps existing groups without configs", func(t *testing.T) {
+		withEmpty := append(existing, types.NewConfigBackupOptionGroup("Later", []*types.ConfigBackupOptions{}))
+		proposed := []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup("Scripts", []*types.ConfigBackupOptions{
+				types.NewMultipleConfigBackupOptions("scripts.yaml", "id", "alias"),
+			}),
+		}
+
+		merged, added := mergeConfigGroups(withEmpty, proposed)
+		if added != 1 || len(merged) != 3 {
+			t.Fatalf("expected the empty group to be kept alongside the new one, got %d groups and %d added", len(merged), added)
+		}
+		if merged[1].Name != "Later" || len(merged[1].Configs) != 0 {
+			t.Errorf("expected the empty group to be kept as it was, got %+v", merged[1])
+		}
+	})
+}
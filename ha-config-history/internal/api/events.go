@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"ha-config-history/internal/core"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventKeepAliveInterval is how often a comment is sent on an idle event stream so
+// proxies do not close it.
+const eventKeepAliveInterval = 30 * time.Second
+
+// StreamEventsHandler sends changes to the client as Server-Sent Events. A client that
+// reconnects with the Last-Event-ID header, or the since query parameter, is first sent
+// the events it missed. When they are no longer kept it is sent a resync event instead.
+func StreamEventsHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		cursor := c.GetHeader("Last-Event-ID")
+		if cursor == "" {
+			cursor = c.Query("since")
+		}
+
+		var since *uint64
+		if cursor != "" {
+			id, err := strconv.ParseUint(cursor, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+				return
+			}
+			since = &id
+		}
+
+		replay, complete, events, unsubscribe := s.Events().Subscribe(since)
+		defer unsubscribe()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		if !complete {
+			writeEvent(c, core.Event{Type: core.EventResync, Time: time.Now().UTC(), Data: struct{}{}})
+		}
+		for _, event := range replay {
+			writeEvent(c, event)
+		}
+		c.Writer.Flush()
+
+		keepAlive := time.NewTicker(eventKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case event, open := <-events:
+				if !open {
+					return
+				}
+				writeEvent(c, event)
+			case <-keepAlive.C:
+				fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeEvent writes an event in the Server-Sent Events format. The resync event has no id
+// so it does not move the client's cursor.
+func writeEvent(c *gin.Context, event core.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if event.ID != 0 {
+		fmt.Fprintf(c.Writer, "id: %d\n", event.ID)
+	}
+	fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Type, data)
+}
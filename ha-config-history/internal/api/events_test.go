@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"ha-config-history/internal/api"
+	"ha-config-history/internal/core"
+	"ha-config-history/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readEvents reads Server-Sent Events from a stream until count events have arrived,
+// returning the event type of each.
+func readEvents(t *testing.T, reader *bufio.Reader, count int) []string {
+	t.Helper()
+
+	events := []string{}
+	for len(events) < count {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event stream after %v: %v", events, err)
+		}
+		if eventType, found := strings.CutPrefix(strings.TrimSpace(line), "event: "); found {
+			events = append(events, eventType)
+		}
+	}
+	return events
+}
+
+func TestStreamEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, backupDir, haConfigDir := setupTestDirs(t)
+
+	server := core.NewServer(&types.AppSettings{
+		HomeAssistantConfigDir: haConfigDir,
+		BackupDir:              backupDir,
+	}, "tmp/test-config.json")
+	defer server.Shutdown(context.Background())
+
+	router := gin.New()
+	router.GET("/events", api.StreamEventsHandler(server))
+	httpServer := httptest.NewServer(router)
+	defer httpServer.Close()
+
+	server.Events().Publish(core.EventSettingsUpdated, struct{}{})
+	server.Events().Publish(core.EventVersionSaved, core.VersionEvent{Group: "core", Path: "automations.yaml", ID: "porch"})
+	server.Events().Publish(core.EventConfigRestored, core.VersionEvent{Group: "core", Path: "automations.yaml", ID: "porch"})
+
+	openStream := func(t *testing.T, lastEventID string) *bufio.Reader {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+
+		request, _ := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/events", nil)
+		request.Header.Set("Last-Event-ID", lastEventID)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Failed to open event stream: %v", err)
+		}
+		t.Cleanup(func() { response.Body.Close() })
+
+		if response.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("Expected an event stream, got %s", response.Header.Get("Content-Type"))
+		}
+		return bufio.NewReader(response.Body)
+	}
+
+	t.Run("Replays events after the cursor then sends new ones", func(t *testing.T) {
+		reader := openStream(t, "1")
+
+		replayed := readEvents(t, reader, 2)
+		if replayed[0] != "version.saved" || replayed[1] != "config.restored" {
+			t.Errorf("Expected the events after the cursor, got %v", replayed)
+		}
+
+		server.Events().Publish(core.EventJobStarted, core.RunSummary{ID: "run"})
+		if live := readEvents(t, reader, 1); live[0] != "job.started" {
+			t.Errorf("Expected the new event, got %v", live)
+		}
+	})
+
+	t.Run("Asks the client to resync when the cursor is unknown", func(t *testing.T) {
+		reader := openStream(t, "999")
+
+		if events := readEvents(t, reader, 1); events[0] != "resync" {
+			t.Errorf("Expected a resync event, got %v", events)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"ha-config-history/internal/core"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListJobsHandler returns the backup runs in progress and the most recent finished runs,
+// newest first.
+func ListJobsHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Runs())
+	}
+}
+
+// GetJobHandler returns a single backup run.
+func GetJobHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		run, exists := s.GetRun(c.Param("id"))
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusOK, run)
+	}
+}
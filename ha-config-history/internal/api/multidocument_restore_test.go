@@ -0,0 +1,121 @@
+package api_test
+
+import (
+	"bytes"
+	"ha-config-history/internal/api"
+	"ha-config-history/internal/core"
+	"ha-config-history/internal/types"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// setupMultiDocumentFileEnv creates a test environment for multi-document restore tests.
+func setupMultiDocumentFileEnv(t *testing.T, configPath string, idNode *string) *testEnvironment {
+	tempDir, backupDir, haConfigDir := setupTestDirs(t)
+	targetFile := filepath.Join(haConfigDir, configPath)
+
+	config := &types.AppSettings{
+		HomeAssistantConfigDir: haConfigDir,
+		BackupDir:              backupDir,
+		Port:                   ":8080",
+		ConfigGroups: []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup(
+				"Test Documents",
+				[]*types.ConfigBackupOptions{
+					types.NewMultiDocumentConfigBackupOptions(configPath, idNode, nil),
+				},
+			),
+		},
+	}
+
+	server := core.NewServer(config, "tmp/test-config.json")
+	router := gin.New()
+	router.POST("/configs/:group/:path/:id/backups/:filename/restore", api.RestoreBackupHandler(server))
+
+	return &testEnvironment{
+		tempDir:     tempDir,
+		backupDir:   backupDir,
+		haConfigDir: haConfigDir,
+		targetFile:  targetFile,
+		server:      server,
+		router:      router,
+		t:           t,
+	}
+}
+
+func TestRestoreMultiDocumentBackupHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	groupSlug, path, filename := "test-documents", "documents.yaml", "20240101T120000.backup"
+
+	parseDocuments := func(env *testEnvironment, content []byte) []map[string]interface{} {
+		documents := []map[string]interface{}{}
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		for {
+			var document map[string]interface{}
+			if err := decoder.Decode(&document); err != nil {
+				break
+			}
+			documents = append(documents, document)
+		}
+		return documents
+	}
+
+	t.Run("replaces a document by position and keeps the others in order", func(t *testing.T) {
+		env := setupMultiDocumentFileEnv(t, path, nil)
+		env.writeFile(env.targetFile, readFile(t, "test-data/multidocument-original.yaml"), 0644)
+		env.createBackup(groupSlug, path, "1", filename, readFile(t, "test-data/multidocument-second-modified.yaml"))
+
+		w, response := env.makeRestoreRequest(groupSlug, path, "1", filename)
+		env.assertStatusOK(w)
+		env.assertRestoreSuccess(response)
+
+		documents := parseDocuments(env, env.readFile(env.targetFile))
+		if len(documents) != 3 {
+			t.Fatalf("expected 3 documents, got %d", len(documents))
+		}
+		for i, name := range []string{"first", "second", "third"} {
+			if documents[i]["name"] != name {
+				t.Errorf("document %d: expected %s, got %v", i, name, documents[i]["name"])
+			}
+		}
+		if documents[1]["value"] != 20 {
+			t.Errorf("second document was not restored, value=%v", documents[1]["value"])
+		}
+	})
+
+	t.Run("replaces a document by id node", func(t *testing.T) {
+		idNode := "name"
+		env := setupMultiDocumentFileEnv(t, path, &idNode)
+		env.writeFile(env.targetFile, readFile(t, "test-data/multidocument-original.yaml"), 0644)
+		env.createBackup(groupSlug, path, "second", filename, readFile(t, "test-data/multidocument-second-modified.yaml"))
+
+		w, response := env.makeRestoreRequest(groupSlug, path, "second", filename)
+		env.assertStatusOK(w)
+		env.assertRestoreSuccess(response)
+
+		documents := parseDocuments(env, env.readFile(env.targetFile))
+		if len(documents) != 3 || documents[1]["value"] != 20 {
+			t.Errorf("expected second document to be restored in place, got %v", documents)
+		}
+	})
+
+	t.Run("appends a document that no longer exists", func(t *testing.T) {
+		idNode := "name"
+		env := setupMultiDocumentFileEnv(t, path, &idNode)
+		env.writeFile(env.targetFile, []byte("name: first\nvalue: 1\n"), 0644)
+		env.createBackup(groupSlug, path, "second", filename, readFile(t, "test-data/multidocument-second-modified.yaml"))
+
+		w, response := env.makeRestoreRequest(groupSlug, path, "second", filename)
+		env.assertStatusOK(w)
+		env.assertRestoreSuccess(response)
+
+		documents := parseDocuments(env, env.readFile(env.targetFile))
+		if len(documents) != 2 || documents[1]["name"] != "second" {
+			t.Errorf("expected second document to be appended, got %v", documents)
+		}
+	})
+}
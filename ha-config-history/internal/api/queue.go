@@ -0,0 +1,15 @@
+package api
+
+import (
+	"ha-config-history/internal/core"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetQueueMetricsHandler returns the state of the backup job queue.
+func GetQueueMetricsHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.QueueMetrics())
+	}
+}
@@ -0,0 +1,90 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ha-config-history/internal/api"
+	"ha-config-history/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedaction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const path = "esphome.yaml"
+	oldBackup := []byte("wifi:\n  ssid: Home\n  password: hunter2\n")
+	newBackup := []byte("wifi:\n  ssid: Home\n  password: hunter3\nlogger:\n")
+
+	setup := func(t *testing.T, storeRedacted bool) *testEnvironment {
+		env := setupSingleFileEnv(t, path)
+		env.server.AppSettings.Redaction = &types.RedactionSettings{
+			Keys:                []string{"password"},
+			RedactStoredBackups: storeRedacted,
+		}
+		env.router.GET("/configs/:group/:path/:id/backups/:filename", api.GetConfigBackupHandler(env.server))
+		env.router.GET("/configs/:group/:path/:id/compare/:left/diff/:right", api.GetBackupDiffHandler(env.server))
+		return env
+	}
+
+	t.Run("backups and diffs are served redacted", func(t *testing.T) {
+		env := setup(t, false)
+		env.createBackup("test-configs", path, path, "20240101T120000.backup", oldBackup)
+		env.createBackup("test-configs", path, path, "20240102T120000.backup", newBackup)
+
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/test-configs/"+path+"/"+path+"/backups/20240101T120000.backup", nil))
+		env.assertStatusOK(w)
+		env.assertContentEquals([]byte("wifi:\n  ssid: Home\n  password: **REDACTED**\n"), w.Body.Bytes())
+
+		w = httptest.NewRecorder()
+		env.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/test-configs/"+path+"/"+path+"/compare/20240101T120000.backup/diff/20240102T120000.backup", nil))
+		env.assertStatusOK(w)
+
+		var response api.BackupDiffResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if strings.Contains(response.UnifiedDiff, "hunter") || strings.Contains(response.OldContent, "hunter") {
+			t.Errorf("Expected secrets to be redacted from the diff, got %q", response.UnifiedDiff)
+		}
+	})
+
+	t.Run("restoring an unredacted backup keeps its values", func(t *testing.T) {
+		env := setup(t, false)
+		env.writeFile(env.targetFile, []byte("wifi:\n  password: changed\n"), 0644)
+		env.createBackup("test-configs", path, path, "20240101T120000.backup", oldBackup)
+
+		w, response := env.makeRestoreRequest("test-configs", path, path, "20240101T120000.backup")
+		env.assertStatusOK(w)
+		env.assertRestoreSuccess(response)
+		env.assertContentEquals(oldBackup, env.readFile(env.targetFile))
+	})
+
+	t.Run("restoring a redacted backup recovers values from the live file", func(t *testing.T) {
+		env := setup(t, true)
+		env.writeFile(env.targetFile, []byte("wifi:\n  ssid: Other\n  password: hunter2\n"), 0644)
+		env.createBackup("test-configs", path, path, "20240101T120000.backup", []byte("wifi:\n  ssid: Home\n  password: **REDACTED**\n"))
+
+		w, response := env.makeRestoreRequest("test-configs", path, path, "20240101T120000.backup")
+		env.assertStatusOK(w)
+		env.assertRestoreSuccess(response)
+		env.assertContentEquals(oldBackup, env.readFile(env.targetFile))
+	})
+
+	t.Run("restoring a redacted backup is refused when values are missing", func(t *testing.T) {
+		env := setup(t, true)
+		live := []byte("wifi:\n  ssid: Other\n")
+		env.writeFile(env.targetFile, live, 0644)
+		env.createBackup("test-configs", path, path, "20240101T120000.backup", []byte("wifi:\n  ssid: Home\n  password: **REDACTED**\n"))
+
+		w, response := env.makeRestoreRequest("test-configs", path, path, "20240101T120000.backup")
+		if w.Code != http.StatusConflict || response.Success {
+			t.Fatalf("Expected 409, got %d: %+v", w.Code, response)
+		}
+		env.assertContentEquals(live, env.readFile(env.targetFile))
+	})
+}
@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"ha-config-history/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRestoreReloadsHomeAssistant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	groupSlug, path, id, filename := "test-configs", "automations.yaml", "automations.yaml", "20240101T120000.yaml"
+
+	// setup restores a backup of automations.yaml with a stand-in for Home Assistant that
+	// answers service calls with status, and returns the services called.
+	setup := func(t *testing.T, status int, reloadAfterRestore bool) (*testEnvironment, func() []string) {
+		var mu sync.Mutex
+		var called []string
+		homeAssistant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer test-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			mu.Lock()
+			called = append(called, r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(status)
+			w.Write([]byte("[]"))
+		}))
+		t.Cleanup(homeAssistant.Close)
+
+		env := setupSingleFileEnv(t, path)
+		env.server.AppSettings.HomeAssistant = &types.HomeAssistantSettings{URL: homeAssistant.URL, Token: "test-token"}
+		env.server.AppSettings.ConfigGroups[0].Configs[0].ReloadAfterRestore = &reloadAfterRestore
+
+		env.writeFile(env.targetFile, []byte("- id: current\n"), 0644)
+		env.createBackup(groupSlug, path, id, filename, []byte("- id: restored\n"))
+
+		return env, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return called
+		}
+	}
+
+	t.Run("calls the reload service for the file", func(t *testing.T) {
+		env, called := setup(t, http.StatusOK, true)
+
+		w, response := env.makeRestoreRequest(groupSlug, path, id, filename)
+		env.assertStatusOK(w)
+		env.assertRestoreSuccess(response)
+
+		if response.Reload == nil || !response.Reload.Success || response.Reload.Service != "automation.reload" {
+			t.Errorf("Expected a successful automation.reload, got %+v", response.Reload)
+		}
+		if calls := called(); len(calls) != 1 || calls[0] != "/api/services/automation/reload" {
+			t.Errorf("Expected automation.reload to be called, got %v", calls)
+		}
+	})
+
+	t.Run("a failed reload keeps the restore", func(t *testing.T) {
+		env, _ := setup(t, http.StatusInternalServerError, true)
+
+		w, response := env.makeRestoreRequest(groupSlug, path, id, filename)
+		env.assertStatusOK(w)
+		env.assertRestoreSuccess(response)
+		env.assertContentEquals([]byte("- id: restored\n"), env.readFile(env.targetFile))
+
+		if response.Reload == nil || response.Reload.Success || response.Reload.Error == "" {
+			t.Errorf("Expected the failed reload to be reported, got %+v", response.Reload)
+		}
+	})
+
+	t.Run("does not reload unless turned on", func(t *testing.T) {
+		env, called := setup(t, http.StatusOK, false)
+
+		_, response := env.makeRestoreRequest(groupSlug, path, id, filename)
+		env.assertRestoreSuccess(response)
+
+		if response.Reload != nil || len(called()) != 0 {
+			t.Errorf("Expected no reload, got %+v and calls %v", response.Reload, called())
+		}
+	})
+}
@@ -1,21 +1,25 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"ha-config-history/internal/core"
 	"ha-config-history/internal/io"
 	"ha-config-history/internal/types"
 	"log/slog"
 	"net/http"
+	"os"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 )
 
 type RestoreBackupResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success bool                    `json:"success"`
+	Message string                  `json:"message,omitempty"`
+	Error   string                  `json:"error,omitempty"`
+	Reload  *core.ReloadResult      `json:"reload,omitempty"` // The Home Assistant reload called after the restore
+	Check   *core.ConfigCheckResult `json:"check,omitempty"`  // Home Assistant's config check after the restore
 }
 
 func RestoreBackupHandler(s *core.Server) func(c *gin.Context) {
@@ -25,21 +29,7 @@ func RestoreBackupHandler(s *core.Server) func(c *gin.Context) {
 		id := c.Param("id")
 		filename := c.Param("filename")
 
-		var configOptions *types.ConfigBackupOptions
-		// Search through all config groups to find the config with matching path
-		for _, configGroup := range s.AppSettings.ConfigGroups {
-			if configGroup.Slug != groupSlug {
-				continue
-			}
-
-			for _, config := range configGroup.Configs {
-				if config.Path == configPath {
-					configOptions = config
-					break
-				}
-			}
-		}
-
+		configOptions := findConfigOptions(s, groupSlug, configPath)
 		if configOptions == nil {
 			c.JSON(http.StatusNotFound, RestoreBackupResponse{
 				Success: false,
@@ -48,7 +38,9 @@ func RestoreBackupHandler(s *core.Server) func(c *gin.Context) {
 			return
 		}
 
-		backupContent, err := io.GetConfigBackup(s.AppSettings.BackupDir, groupSlug, configPath, id, filename)
+		historyIds := s.HistoryIds(groupSlug, types.ConfigBackupIdentifier{Path: configPath, ID: id})
+
+		backupContent, _, err := io.GetConfigBackupFromHistory(s.Settings().BackupDir, groupSlug, configPath, historyIds, filename)
 		if err != nil {
 			c.JSON(http.StatusNotFound, RestoreBackupResponse{
 				Success: false,
@@ -57,67 +49,244 @@ func RestoreBackupHandler(s *core.Server) func(c *gin.Context) {
 			return
 		}
 
-		fullPath := filepath.Join(s.AppSettings.HomeAssistantConfigDir, configOptions.Path)
-
-		if configOptions.BackupType == "single" {
-			if err := io.RestoreEntireFile(fullPath, backupContent); err != nil {
-				c.JSON(http.StatusInternalServerError, RestoreBackupResponse{
-					Success: false,
-					Error:   fmt.Sprintf("Failed to restore backup: %v", err),
-				})
-				return
-			}
+		fullPath, check, err := restoreAndCheckConfig(s, configOptions, id, backupContent)
+		if err != nil {
+			c.JSON(restoreErrorStatus(err), RestoreBackupResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Failed to restore backup: %v", err),
+				Check:   check,
+			})
+			return
 		}
 
-		if configOptions.BackupType == "multiple" {
-			if err := io.RestorePartialFile(fullPath, backupContent, *configOptions); err != nil {
-				c.JSON(http.StatusInternalServerError, RestoreBackupResponse{
-					Success: false,
-					Error:   fmt.Sprintf("Failed to restore backup: %v", err),
-				})
-				return
-			}
+		slog.Info("Backup restored successfully", "group", groupSlug, "path", configPath, "id", id, "filename", filename, "fullPath", fullPath)
+		s.Events().Publish(core.EventConfigRestored, core.VersionEvent{Group: groupSlug, Path: configPath, ID: id, Filename: filename})
+
+		c.JSON(http.StatusOK, RestoreBackupResponse{
+			Success: true,
+			Message: fmt.Sprintf("Successfully restored backup to %s", fullPath),
+			Reload:  reloadAfterRestore(s, configOptions, check),
+			Check:   check,
+		})
+	}
+}
+
+// RestoreDeletedConfigHandler puts a config that was removed from its file back, using
+// its last backup.
+func RestoreDeletedConfigHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		groupSlug := types.GroupSlug(c.Param("group"))
+		configPath := c.Param("path")
+		id := c.Param("id")
+		identifier := types.ConfigBackupIdentifier{Path: configPath, ID: id}
+
+		configOptions := findConfigOptions(s, groupSlug, configPath)
+		if configOptions == nil {
+			c.JSON(http.StatusNotFound, RestoreBackupResponse{
+				Success: false,
+				Error:   "Config not found",
+			})
+			return
 		}
 
-		if configOptions.BackupType == "keyed" {
-			// For keyed configs the id path param is the YAML map key.
-			if err := io.RestoreKeyedPartialFile(fullPath, id, backupContent, *configOptions); err != nil {
-				c.JSON(http.StatusInternalServerError, RestoreBackupResponse{
-					Success: false,
-					Error:   fmt.Sprintf("Failed to restore backup: %v", err),
-				})
-				return
-			}
+		s.State.Mu.RLock()
+		summary, exists := s.State.CachedBackupSummaries[groupSlug][identifier]
+		s.State.Mu.RUnlock()
+
+		if !exists || !summary.Deleted {
+			c.JSON(http.StatusBadRequest, RestoreBackupResponse{
+				Success: false,
+				Error:   "Config has not been deleted",
+			})
+			return
 		}
 
-		if configOptions.BackupType == "directory" {
-			fullPath := filepath.Join(s.AppSettings.HomeAssistantConfigDir, configOptions.Path, id)
-			if err := io.RestoreEntireFile(fullPath, backupContent); err != nil {
-				c.JSON(http.StatusInternalServerError, RestoreBackupResponse{
-					Success: false,
-					Error:   fmt.Sprintf("Failed to restore backup: %v", err),
-				})
-				return
-			}
+		backupContent, err := io.GetLatestConfigBackup(s.Settings().BackupDir, groupSlug, configPath, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, RestoreBackupResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Failed to load last backup: %v", err),
+			})
+			return
 		}
 
-		knownType := configOptions.BackupType == "single" ||
-			configOptions.BackupType == "multiple" ||
-			configOptions.BackupType == "keyed" ||
-			configOptions.BackupType == "directory"
-		if !knownType {
-			c.JSON(http.StatusInternalServerError, RestoreBackupResponse{
+		fullPath, check, err := restoreAndCheckConfig(s, configOptions, id, backupContent)
+		if err != nil {
+			c.JSON(restoreErrorStatus(err), RestoreBackupResponse{
 				Success: false,
-				Error:   fmt.Sprintf("unhandled backup type: %s", configOptions.BackupType),
+				Error:   fmt.Sprintf("Failed to restore backup: %v", err),
+				Check:   check,
 			})
 			return
 		}
 
-		slog.Info("Backup restored successfully", "group", groupSlug, "path", configPath, "id", id, "filename", filename, "fullPath", fullPath)
+		s.QueueClearDeletion(groupSlug, configOptions, identifier)
+
+		slog.Info("Deleted config restored successfully", "group", groupSlug, "path", configPath, "id", id, "fullPath", fullPath)
+		s.Events().Publish(core.EventConfigRestored, core.VersionEvent{Group: groupSlug, Path: configPath, ID: id})
 
 		c.JSON(http.StatusOK, RestoreBackupResponse{
 			Success: true,
-			Message: fmt.Sprintf("Successfully restored backup to %s", fullPath),
+			Message: fmt.Sprintf("Successfully restored %s to %s", summary.FriendlyName, fullPath),
+			Reload:  reloadAfterRestore(s, configOptions, check),
+			Check:   check,
 		})
 	}
 }
+
+// findConfigOptions searches the config groups for the config with a matching path.
+func findConfigOptions(s *core.Server, groupSlug types.GroupSlug, configPath string) *types.ConfigBackupOptions {
+	for _, configGroup := range s.Settings().ConfigGroups {
+		if configGroup.Slug != groupSlug {
+			continue
+		}
+
+		for _, config := range configGroup.Configs {
+			if config.Path == configPath {
+				return config
+			}
+		}
+	}
+	return nil
+}
+
+// restoreErrorStatus returns the HTTP status for an error from restoreConfigContent.
+func restoreErrorStatus(err error) int {
+	if errors.Is(err, types.ErrRedactionNotRecoverable) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, core.ErrConfigCheckFailed) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// recoverRedactedContent puts the values that were redacted when a backup was stored
+// back from the config's live content, refusing the restore if any are missing.
+func recoverRedactedContent(s *core.Server, configOptions *types.ConfigBackupOptions, id string, backupContent []byte) ([]byte, error) {
+	if !types.IsRedacted(backupContent) {
+		return backupContent, nil
+	}
+
+	liveContent, err := s.CurrentConfigContent(configOptions, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrRedactionNotRecoverable, err)
+	}
+
+	recovered, err := s.Settings().Redaction.RecoverRedacted(backupContent, liveContent)
+	if err != nil {
+		return nil, err
+	}
+	return recovered, nil
+}
+
+// restoreAndCheckConfig restores backupContent like restoreConfigContent. When the config
+// asks for it, Home Assistant checks its configuration afterwards and a restore that breaks
+// a configuration that passed the check beforehand is rolled back. A configuration that was
+// already broken is not rolled back, since the restore may be what fixes it.
+func restoreAndCheckConfig(s *core.Server, configOptions *types.ConfigBackupOptions, id string, backupContent []byte) (string, *core.ConfigCheckResult, error) {
+	if !core.ChecksConfigOnRestore(configOptions) {
+		fullPath, err := restoreConfigContent(s, configOptions, id, backupContent)
+		return fullPath, nil, err
+	}
+
+	fullPath, err := restorePath(s, configOptions, id)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve the restore path: %w", err)
+	}
+	before := s.CheckConfig()
+	original, readErr := os.ReadFile(fullPath)
+	if readErr != nil && !os.IsNotExist(readErr) {
+		return "", nil, fmt.Errorf("failed to read the current file before restoring: %w", readErr)
+	}
+
+	if _, err := restoreConfigContent(s, configOptions, id, backupContent); err != nil {
+		return "", nil, err
+	}
+
+	check := s.CheckConfig()
+	if check.Valid || check.Error != "" || !before.Valid {
+		return fullPath, check, nil
+	}
+
+	if readErr != nil {
+		err = os.Remove(fullPath)
+	} else {
+		err = io.RestoreEntireFile(fullPath, original)
+	}
+	if err != nil {
+		slog.Error("Error rolling back restore that failed the config check", "path", fullPath, "error", err)
+		return fullPath, check, fmt.Errorf("%w, and rolling it back failed: %w", core.ErrConfigCheckFailed, err)
+	}
+
+	slog.Warn("Rolled back restore that failed the config check", "path", fullPath, "errors", check.Errors)
+	check.RolledBack = true
+	return fullPath, check, fmt.Errorf("%w and was rolled back", core.ErrConfigCheckFailed)
+}
+
+// reloadAfterRestore reloads Home Assistant after a restore, unless its config check
+// failed.
+func reloadAfterRestore(s *core.Server, configOptions *types.ConfigBackupOptions, check *core.ConfigCheckResult) *core.ReloadResult {
+	if check != nil && !check.Valid && check.Error == "" {
+		return nil
+	}
+	return s.ReloadAfterRestore(configOptions)
+}
+
+// restorePath returns the file a restore of the config with the given id writes to.
+func restorePath(s *core.Server, configOptions *types.ConfigBackupOptions, id string) (string, error) {
+	rootDir, err := s.Settings().RootDir(configOptions)
+	if err != nil {
+		return "", err
+	}
+
+	// Entries of directories are files within the config path
+	pathElements := []string{configOptions.Path}
+	switch configOptions.BackupType {
+	case "directory":
+		pathElements = append(pathElements, id)
+	case "blueprint":
+		pathElements = append(pathElements, types.BlueprintRelativePath(id))
+	}
+
+	return types.SandboxedPath(rootDir, pathElements...)
+}
+
+// restoreConfigContent writes backupContent for the config with the given id back to
+// the Home Assistant config directory and returns the path that was written.
+func restoreConfigContent(s *core.Server, configOptions *types.ConfigBackupOptions, id string, backupContent []byte) (string, error) {
+	backupContent, err := recoverRedactedContent(s, configOptions, id, backupContent)
+	if err != nil {
+		return "", err
+	}
+
+	fullPath, err := restorePath(s, configOptions, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the restore path: %w", err)
+	}
+
+	switch configOptions.BackupType {
+	case "single", "directory":
+		err = io.RestoreEntireFile(fullPath, backupContent)
+	case "multiple":
+		err = io.RestorePartialFile(fullPath, id, backupContent, *configOptions)
+	case "keyed":
+		// For keyed configs the id path param is the YAML map key.
+		err = io.RestoreKeyedPartialFile(fullPath, id, backupContent, *configOptions)
+	case "multidocument":
+		err = io.RestoreDocumentPartialFile(fullPath, id, backupContent, *configOptions)
+	case "nodered":
+		err = io.RestoreNodeREDFlowPartialFile(fullPath, id, backupContent)
+	case "blueprint":
+		if err = os.MkdirAll(filepath.Dir(fullPath), 0755); err == nil {
+			err = io.RestoreEntireFile(fullPath, backupContent)
+		}
+	default:
+		return "", fmt.Errorf("unhandled backup type: %s", configOptions.BackupType)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", fullPath, err)
+	}
+	return fullPath, nil
+}
@@ -392,6 +392,33 @@ func TestRestoreBackupHandler(t *testing.T) {
 	})
 }
 
+func TestRestoreBackupHandlerSourceRoots(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	data := loadTestData(t)
+
+	env := setupSingleFileEnv(t, "configuration.yaml")
+	addonConfigsDir := filepath.Join(env.tempDir, "addon_configs")
+	targetFile := filepath.Join(addonConfigsDir, "configuration.yaml")
+	if err := os.MkdirAll(addonConfigsDir, 0755); err != nil {
+		t.Fatalf("Failed to create source root: %v", err)
+	}
+
+	env.server.AppSettings.SourceRoots = []*types.SourceRoot{{Name: "addon_configs", Path: addonConfigsDir}}
+	env.server.AppSettings.ConfigGroups[0].Configs[0].Root = "addon_configs"
+
+	env.writeFile(targetFile, data.original, 0644)
+	env.createBackup("test-configs", "configuration.yaml", "configuration.yaml", "20240101T120000.backup", data.backup)
+
+	w, response := env.makeRestoreRequest("test-configs", "configuration.yaml", "configuration.yaml", "20240101T120000.backup")
+	env.assertStatusOK(w)
+	env.assertRestoreSuccess(response)
+
+	env.assertContentEquals(data.backup, env.readFile(targetFile))
+	if _, err := os.Stat(env.targetFile); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be written to the Home Assistant config directory")
+	}
+}
+
 func setupTestDirs(t *testing.T) (tempDir, backupDir, haConfigDir string) {
 	tempDir = t.TempDir()
 	backupDir = filepath.Join(tempDir, "backups")
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"ha-config-history/internal/core"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListSchedulesHandler returns the cron schedules configs are scanned on, with when each
+// next runs and its configs, soonest first.
+func ListSchedulesHandler(s *core.Server) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Schedules())
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"crypto/rand"
+	"ha-config-history/internal/core"
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecretsFile is the tracked secrets file that !secret references are resolved from.
+type SecretsFile struct {
+	Group types.GroupSlug `json:"group"`
+	Path  string          `json:"path"`
+	ID    string          `json:"id"`
+}
+
+// SecretReference is a !secret reference in a version of a config. Fingerprint identifies
+// the value without revealing it and is only comparable within one response.
+type SecretReference struct {
+	Name        string `json:"name"`
+	Found       bool   `json:"found"`
+	Redacted    bool   `json:"redacted,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Changed is set when the value differs from the one the previous version resolved to
+	Changed bool `json:"changed"`
+}
+
+type ConfigSecretVersion struct {
+	Filename        string            `json:"filename"`
+	Date            time.Time         `json:"date"`
+	SecretsFilename string            `json:"secretsFilename,omitempty"`
+	SecretsDate     *time.Time        `json:"secretsDate,omitempty"`
+	Secrets         []SecretReference `json:"secrets"`
+}
+
+type ConfigSecretVersionsResponse struct {
+	SecretsFile *SecretsFile          `json:"secretsFile,omitempty"`
+	Versions    []ConfigSecretVersion `json:"versions"`
+}
+
+// GetConfigSecretVersionsHandler lists the versions of a config with the !secret
+// references in each, resolved against the version of the tracked secrets file that was
+// current when it was saved.
+func GetConfigSecretVersionsHandler(s *core.Server) func(c *gin.Context) {
+	fingerprinter, err := types.NewSecretFingerprinter(rand.Reader)
+	if err != nil {
+		slog.Error("Resolving secrets is unavailable", "error", err)
+	}
+
+	return func(c *gin.Context) {
+		settings := s.Settings()
+		if !settings.ResolveSecrets {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Resolving secrets is disabled"})
+			return
+		}
+		if fingerprinter == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Resolving secrets is unavailable"})
+			return
+		}
+
+		groupSlug := types.GroupSlug(c.Param("group"))
+		configPath := c.Param("path")
+		id := c.Param("id")
+
+		configOptions := findConfigOptions(s, groupSlug, configPath)
+		if configOptions == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Config not found"})
+			return
+		}
+
+		historyIds := s.HistoryIds(groupSlug, types.ConfigBackupIdentifier{Path: configPath, ID: id})
+		backups, err := io.ListConfigBackupHistory(settings.BackupDir, groupSlug, configPath, id, historyIds[1:])
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		response := ConfigSecretVersionsResponse{
+			SecretsFile: findTrackedSecrets(s, configOptions, id),
+			Versions:    []ConfigSecretVersion{},
+		}
+
+		var secretsBackups []io.BackupInfo
+		if response.SecretsFile != nil {
+			secretsBackups, _ = io.ListConfigBackups(settings.BackupDir, response.SecretsFile.Group, response.SecretsFile.Path, response.SecretsFile.ID)
+		}
+		secretsVersions := map[string]map[string]string{}
+
+		for _, backup := range backups {
+			version := ConfigSecretVersion{Filename: backup.Filename, Date: backup.Date, Secrets: []SecretReference{}}
+
+			content, err := io.GetConfigBackup(settings.BackupDir, groupSlug, configPath, backup.ID, backup.Filename)
+			if err != nil {
+				slog.Warn("Failed to read backup for secret lookup", "id", backup.ID, "filename", backup.Filename, "error", err)
+				response.Versions = append(response.Versions, version)
+				continue
+			}
+
+			var secrets map[string]string
+			if secretsBackup, ok := io.BackupAt(secretsBackups, backup.Date); ok {
+				version.SecretsFilename = secretsBackup.Filename
+				version.SecretsDate = &secretsBackup.Date
+
+				if _, loaded := secretsVersions[secretsBackup.Filename]; !loaded {
+					secretsVersions[secretsBackup.Filename] = readSecretsBackup(s, response.SecretsFile, secretsBackup.Filename)
+				}
+				secrets = secretsVersions[secretsBackup.Filename]
+			}
+
+			for _, name := range types.SecretReferences(content) {
+				reference := SecretReference{Name: name}
+				if value, found := secrets[name]; found {
+					reference.Found = true
+					if types.IsRedacted([]byte(value)) {
+						reference.Redacted = true
+					} else {
+						reference.Fingerprint = fingerprinter.Fingerprint(value)
+					}
+				}
+				version.Secrets = append(version.Secrets, reference)
+			}
+
+			response.Versions = append(response.Versions, version)
+		}
+
+		markChangedSecrets(response.Versions)
+
+		c.IndentedJSON(http.StatusOK, response)
+	}
+}
+
+// markChangedSecrets flags references whose value differs from the one the previous
+// version resolved to. Versions are newest first. Redacted values cannot be compared.
+func markChangedSecrets(versions []ConfigSecretVersion) {
+	previous := map[string]SecretReference{}
+	for i := len(versions) - 1; i >= 0; i-- {
+		for j, reference := range versions[i].Secrets {
+			if reference.Redacted {
+				continue
+			}
+			if last, seen := previous[reference.Name]; seen {
+				versions[i].Secrets[j].Changed = last.Found != reference.Found || last.Fingerprint != reference.Fingerprint
+			}
+			previous[reference.Name] = reference
+		}
+	}
+}
+
+func readSecretsBackup(s *core.Server, secretsFile *SecretsFile, filename string) map[string]string {
+	content, err := io.GetConfigBackup(s.Settings().BackupDir, secretsFile.Group, secretsFile.Path, secretsFile.ID, filename)
+	if err != nil {
+		slog.Warn("Failed to read secrets backup", "filename", filename, "error", err)
+		return nil
+	}
+
+	secrets, err := types.ParseSecrets(content)
+	if err != nil {
+		slog.Warn("Failed to parse secrets backup", "filename", filename, "error", err)
+		return nil
+	}
+	return secrets
+}
+
+// findTrackedSecrets finds the tracked secrets file a config's !secret references are
+// resolved from: secrets.yaml in the config file's folder or the nearest parent folder
+// within the same source root.
+func findTrackedSecrets(s *core.Server, configOptions *types.ConfigBackupOptions, id string) *SecretsFile {
+	configFile := configOptions.Path
+	switch configOptions.BackupType {
+	case "directory":
+		configFile = filepath.Join(configOptions.Path, id)
+	case "blueprint":
+		configFile = filepath.Join(configOptions.Path, types.BlueprintRelativePath(id))
+	}
+
+	s.State.Mu.RLock()
+	defer s.State.Mu.RUnlock()
+
+	for dir := filepath.Dir(configFile); ; dir = filepath.Dir(dir) {
+		candidate := filepath.Join(dir, types.SecretsFilename)
+		if secretsFile := trackedSecretsFile(s, configOptions.RootName(), candidate); secretsFile != nil {
+			return secretsFile
+		}
+		if dir == "." || dir == filepath.Dir(dir) {
+			return nil
+		}
+	}
+}
+
+// trackedSecretsFile returns the tracked config for the secrets file at path, either as a
+// single file or as a file of a tracked directory. The caller must hold the state lock.
+func trackedSecretsFile(s *core.Server, rootName, path string) *SecretsFile {
+	for _, configGroup := range s.Settings().ConfigGroups {
+		for _, config := range configGroup.Configs {
+			if config.RootName() != rootName {
+				continue
+			}
+
+			var identifier types.ConfigBackupIdentifier
+			switch {
+			case config.BackupType == "single" && filepath.Clean(config.Path) == path:
+				identifier = types.ConfigBackupIdentifier{Path: config.Path, ID: config.Path}
+			case config.BackupType == "directory" && filepath.Clean(config.Path) == filepath.Dir(path):
+				identifier = types.ConfigBackupIdentifier{Path: config.Path, ID: filepath.Base(path)}
+			default:
+				continue
+			}
+
+			if _, exists := s.State.CachedBackupSummaries[configGroup.Slug][identifier]; exists {
+				return &SecretsFile{Group: configGroup.Slug, Path: identifier.Path, ID: identifier.ID}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ha-config-history/internal/api"
+	"ha-config-history/internal/core"
+	"ha-config-history/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConfigSecretVersions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, backupDir, haConfigDir := setupTestDirs(t)
+
+	writeVersions := func(group, path, id string, versions map[string]string) {
+		configDir := filepath.Join(backupDir, group, path, id)
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			t.Fatalf("Failed to create backup directory: %v", err)
+		}
+		for filename, content := range versions {
+			if err := os.WriteFile(filepath.Join(configDir, filename), []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write backup: %v", err)
+			}
+		}
+		metadata, _ := json.Marshal(types.BackupConfigSummary{
+			ConfigBackupIdentifier: types.ConfigBackupIdentifier{Path: path, ID: id},
+			FriendlyName:           id,
+			BackupCount:            len(versions),
+		})
+		if err := os.WriteFile(filepath.Join(configDir, "metadata.json"), metadata, 0644); err != nil {
+			t.Fatalf("Failed to write metadata: %v", err)
+		}
+	}
+
+	writeVersions("core", "secrets.yaml", "secrets.yaml", map[string]string{
+		"20250101T000000.backup": "mqtt_password: first\nlatitude: 1\n",
+		"20250201T000000.backup": "mqtt_password: second\nlatitude: 1\n",
+	})
+	writeVersions("core", "configuration.yaml", "configuration.yaml", map[string]string{
+		"20250110T000000.backup": "mqtt:\n  password: !secret mqtt_password\nzone:\n  latitude: !secret latitude\n",
+		"20250120T000000.backup": "mqtt:\n  password: !secret mqtt_password\nzone:\n  latitude: !secret latitude\n  name: Home\n",
+		"20250210T000000.backup": "mqtt:\n  password: !secret mqtt_password\nzone:\n  latitude: !secret latitude\n  name: House\n",
+	})
+	writeVersions("esphome", "esphome", "secrets.yaml", map[string]string{
+		"20250101T000000.backup": "wifi_password: device\n",
+	})
+	writeVersions("esphome", "esphome", "porch.yaml", map[string]string{
+		"20250110T000000.backup": "wifi:\n  password: !secret wifi_password\n  ap:\n    password: !secret ap_password\n",
+	})
+
+	server := core.NewServer(&types.AppSettings{
+		HomeAssistantConfigDir: haConfigDir,
+		BackupDir:              backupDir,
+		ResolveSecrets:         true,
+		ConfigGroups: []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup("Core", []*types.ConfigBackupOptions{
+				types.NewSingleConfigBackupOptions("configuration.yaml"),
+				types.NewSingleConfigBackupOptions("secrets.yaml"),
+			}),
+			types.NewConfigBackupOptionGroup("ESPHome", []*types.ConfigBackupOptions{
+				types.NewDirectoryConfigBackupOptions("esphome", []string{"*.yaml"}, nil),
+			}),
+		},
+	}, "tmp/test-config.json")
+
+	router := gin.New()
+	router.GET("/configs/:group/:path/:id/secret-versions", api.GetConfigSecretVersionsHandler(server))
+
+	get := func(t *testing.T, url string) api.ConfigSecretVersionsResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+		var response api.ConfigSecretVersionsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return response
+	}
+
+	t.Run("reports when a secret's value changed between versions", func(t *testing.T) {
+		response := get(t, "/configs/core/configuration.yaml/configuration.yaml/secret-versions")
+
+		if response.SecretsFile == nil || response.SecretsFile.ID != "secrets.yaml" {
+			t.Fatalf("Expected secrets.yaml to be resolved, got %+v", response.SecretsFile)
+		}
+		if len(response.Versions) != 3 {
+			t.Fatalf("Expected 3 versions, got %d", len(response.Versions))
+		}
+
+		newest, middle, oldest := response.Versions[0], response.Versions[1], response.Versions[2]
+		if newest.SecretsFilename != "20250201T000000.backup" || oldest.SecretsFilename != "20250101T000000.backup" {
+			t.Errorf("Unexpected secrets versions %s and %s", newest.SecretsFilename, oldest.SecretsFilename)
+		}
+		if !newest.Secrets[0].Changed || newest.Secrets[1].Changed {
+			t.Errorf("Expected only mqtt_password to change in the newest version, got %+v", newest.Secrets)
+		}
+		if middle.Secrets[0].Changed || oldest.Secrets[0].Changed {
+			t.Errorf("Expected mqtt_password to be unchanged in older versions")
+		}
+		if newest.Secrets[0].Fingerprint == middle.Secrets[0].Fingerprint || middle.Secrets[0].Fingerprint != oldest.Secrets[0].Fingerprint {
+			t.Errorf("Unexpected fingerprints %+v", response.Versions)
+		}
+		for _, version := range response.Versions {
+			for _, secret := range version.Secrets {
+				if secret.Fingerprint == "first" || secret.Fingerprint == "second" {
+					t.Errorf("Secret value revealed for %s", secret.Name)
+				}
+			}
+		}
+	})
+
+	t.Run("resolves from the secrets file next to the config", func(t *testing.T) {
+		response := get(t, "/configs/esphome/esphome/porch.yaml/secret-versions")
+
+		if response.SecretsFile == nil || response.SecretsFile.Group != "esphome" {
+			t.Fatalf("Expected esphome/secrets.yaml to be resolved, got %+v", response.SecretsFile)
+		}
+		secrets := response.Versions[0].Secrets
+		if len(secrets) != 2 || !secrets[0].Found || secrets[1].Found {
+			t.Errorf("Expected wifi_password to be found and ap_password missing, got %+v", secrets)
+		}
+	})
+
+	t.Run("is forbidden unless enabled", func(t *testing.T) {
+		server.AppSettings.ResolveSecrets = false
+		defer func() { server.AppSettings.ResolveSecrets = true }()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/core/configuration.yaml/configuration.yaml/secret-versions", nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", w.Code)
+		}
+	})
+}
+
+func TestRequireAuthenticatedUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/private", api.RequireAuthenticatedUser("token"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   int
+	}{
+		{"no credentials", "192.168.1.10:5000", nil, http.StatusUnauthorized},
+		{"wrong token", "192.168.1.10:5000", map[string]string{"Authorization": "Bearer nope"}, http.StatusUnauthorized},
+		{"bearer token", "192.168.1.10:5000", map[string]string{"Authorization": "Bearer token"}, http.StatusOK},
+		{"ingress user", "172.30.32.2:5000", map[string]string{"X-Remote-User-Id": "abc"}, http.StatusOK},
+		{"ingress headers from elsewhere", "192.168.1.10:5000", map[string]string{"X-Remote-User-Id": "abc"}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/private", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
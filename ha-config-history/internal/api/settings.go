@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"ha-config-history/internal/core"
+	"ha-config-history/internal/homeassistant"
 	"ha-config-history/internal/io"
 	"ha-config-history/internal/types"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -60,6 +63,52 @@ func validateConfigPath(path string) error {
 	return nil
 }
 
+var sourceRootNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// validateSourceRoots checks the named source roots and that every config selects a root
+// that exists, without tracking the same path from two roots in one group.
+func validateSourceRoots(appSettings *types.AppSettings) error {
+	rootNames := map[string]bool{types.HomeAssistantSourceRoot: true}
+	for i, root := range appSettings.SourceRoots {
+		if root == nil {
+			return fmt.Errorf("source root at index %d is nil", i)
+		}
+		if !sourceRootNamePattern.MatchString(root.Name) {
+			return fmt.Errorf("source root name '%s' must only contain lowercase letters, numbers and underscores", root.Name)
+		}
+		if rootNames[root.Name] {
+			return fmt.Errorf("duplicate source root name: '%s'", root.Name)
+		}
+		if !filepath.IsAbs(root.Path) {
+			return fmt.Errorf("source root '%s' must have an absolute path", root.Name)
+		}
+		rootNames[root.Name] = true
+	}
+
+	for _, group := range appSettings.ConfigGroups {
+		if group == nil {
+			continue
+		}
+
+		pathRoots := map[string]string{}
+		for _, config := range group.Configs {
+			if config == nil {
+				continue
+			}
+			rootName := config.RootName()
+			if !rootNames[rootName] {
+				return fmt.Errorf("config '%s' in group '%s' uses unknown source root '%s'", config.Path, group.Name, rootName)
+			}
+			if existing, exists := pathRoots[config.Path]; exists && existing != rootName {
+				return fmt.Errorf("config '%s' in group '%s' is tracked from more than one source root", config.Path, group.Name)
+			}
+			pathRoots[config.Path] = rootName
+		}
+	}
+
+	return nil
+}
+
 // validateConfigGroups validates the entire config groups structure
 func validateConfigGroups(configGroups []*types.ConfigBackupOptionGroup) error {
 	if len(configGroups) == 0 {
@@ -84,6 +133,12 @@ func validateConfigGroups(configGroups []*types.ConfigBackupOptionGroup) error {
 		}
 		groupNames[group.Name] = true
 
+		if group.CronSchedule != nil && *group.CronSchedule != "" {
+			if err := core.ValidateCronSchedule(*group.CronSchedule); err != nil {
+				return fmt.Errorf("group '%s' has invalid cron schedule: %v", group.Name, err)
+			}
+		}
+
 		// Validate configs within group
 		if len(group.Configs) == 0 {
 			return fmt.Errorf("group '%s' must contain at least one config", group.Name)
@@ -109,8 +164,24 @@ func validateConfig(config *types.ConfigBackupOptions, configIndex int, groupNam
 		return fmt.Errorf("config '%s' in group '%s': %v", config.Path, groupName, err)
 	}
 
+	if config.CronSchedule != nil && *config.CronSchedule != "" {
+		if err := core.ValidateCronSchedule(*config.CronSchedule); err != nil {
+			return fmt.Errorf("config '%s' in group '%s' has invalid cron schedule: %v", config.Path, groupName, err)
+		}
+	}
+
+	if config.ReloadService != nil && *config.ReloadService != "" {
+		if _, _, ok := homeassistant.SplitService(*config.ReloadService); !ok {
+			return fmt.Errorf("config '%s' in group '%s' has invalid reload service: '%s', expected domain.service", config.Path, groupName, *config.ReloadService)
+		}
+	}
+	if config.ReloadAfterRestore != nil && *config.ReloadAfterRestore && core.ReloadService(config) == "" {
+		return fmt.Errorf("config '%s' in group '%s' reloads after restore but has no reload service", config.Path, groupName)
+	}
+
 	// Validate backup type
-	if config.BackupType != "single" && config.BackupType != "multiple" && config.BackupType != "directory" && config.BackupType != "keyed" {
+	validBackupTypes := []string{"single", "multiple", "directory", "keyed", "multidocument", "nodered", "blueprint"}
+	if !slices.Contains(validBackupTypes, config.BackupType) {
 		return fmt.Errorf("config '%s' in group '%s' has invalid backup type: '%s'",
 			config.Path, groupName, config.BackupType)
 	}
@@ -123,6 +194,10 @@ func validateConfig(config *types.ConfigBackupOptions, configIndex int, groupNam
 		if config.FriendlyNameNode == nil || strings.TrimSpace(*config.FriendlyNameNode) == "" {
 			return fmt.Errorf("config '%s' with backup type 'multiple' must have a valid friendlyNameNode", config.Path)
 		}
+		if config.MissingIdStrategy != nil && *config.MissingIdStrategy != "" &&
+			!slices.Contains(types.MissingIdStrategies, *config.MissingIdStrategy) {
+			return fmt.Errorf("config '%s' has invalid missingIdStrategy: '%s'", config.Path, *config.MissingIdStrategy)
+		}
 	}
 
 	// Validate keyed backup type fields: the id is the map key (no idNode
@@ -133,6 +208,26 @@ func validateConfig(config *types.ConfigBackupOptions, configIndex int, groupNam
 		}
 	}
 
+	// Validate multidocument backup type fields: documents are identified by
+	// position unless an idNode is provided.
+	if config.BackupType == "multidocument" {
+		if config.IdNode != nil && strings.TrimSpace(*config.IdNode) == "" {
+			return fmt.Errorf("config '%s' with backup type 'multidocument' must have a non-empty idNode when provided", config.Path)
+		}
+		if config.FriendlyNameNode != nil && strings.TrimSpace(*config.FriendlyNameNode) == "" {
+			return fmt.Errorf("config '%s' with backup type 'multidocument' must have a non-empty friendlyNameNode when provided", config.Path)
+		}
+	}
+
+	// Validate normalization key paths
+	if config.Normalize != nil {
+		for _, path := range append(slices.Clone(config.Normalize.IgnorePaths), config.Normalize.SortPaths...) {
+			if err := types.ValidateKeyPath(path); err != nil {
+				return fmt.Errorf("config '%s' has invalid normalize rule: %v", config.Path, err)
+			}
+		}
+	}
+
 	// Validate max backups and age constraints
 	if config.MaxBackups != nil && *config.MaxBackups < 1 {
 		return fmt.Errorf("config '%s' maxBackups must be at least 1", config.Path)
@@ -147,7 +242,10 @@ func validateConfig(config *types.ConfigBackupOptions, configIndex int, groupNam
 
 func GetSettingsHandler(s *core.Server) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		c.IndentedJSON(http.StatusOK, s.AppSettings)
+		// The Home Assistant access token has full admin rights, so it is never returned
+		settings := *s.Settings()
+		settings.HomeAssistant = settings.HomeAssistant.Masked()
+		c.IndentedJSON(http.StatusOK, &settings)
 	}
 }
 
@@ -168,6 +266,9 @@ func UpdateSettingsHandler(s *core.Server) func(c *gin.Context) {
 			return
 		}
 
+		settings := s.Settings()
+		newSettings.HomeAssistant.KeepToken(settings.HomeAssistant)
+
 		var warnings []string
 
 		if !io.DirectoryExists(newSettings.HomeAssistantConfigDir) {
@@ -187,6 +288,38 @@ func UpdateSettingsHandler(s *core.Server) func(c *gin.Context) {
 			}
 		}
 
+		if newSettings.WatchQuietPeriodMs != nil && (*newSettings.WatchQuietPeriodMs < 0 || *newSettings.WatchQuietPeriodMs > 60000) {
+			c.JSON(http.StatusBadRequest, UpdateSettingsResponse{
+				Success: false,
+				Error:   "Watch quiet period must be between 0 and 60000 milliseconds",
+			})
+			return
+		}
+
+		if newSettings.PollIntervalMs != nil && (*newSettings.PollIntervalMs < 1000 || *newSettings.PollIntervalMs > 3600000) {
+			c.JSON(http.StatusBadRequest, UpdateSettingsResponse{
+				Success: false,
+				Error:   "Poll interval must be between 1000 and 3600000 milliseconds",
+			})
+			return
+		}
+
+		if newSettings.Workers != nil && (*newSettings.Workers < 1 || *newSettings.Workers > 16) {
+			c.JSON(http.StatusBadRequest, UpdateSettingsResponse{
+				Success: false,
+				Error:   "Workers must be between 1 and 16",
+			})
+			return
+		}
+
+		if newSettings.QueueSize != nil && (*newSettings.QueueSize < 1 || *newSettings.QueueSize > 10000) {
+			c.JSON(http.StatusBadRequest, UpdateSettingsResponse{
+				Success: false,
+				Error:   "Queue size must be between 1 and 10000",
+			})
+			return
+		}
+
 		if err := validateConfigGroups(newSettings.ConfigGroups); err != nil {
 			c.JSON(http.StatusBadRequest, UpdateSettingsResponse{
 				Success: false,
@@ -195,6 +328,43 @@ func UpdateSettingsHandler(s *core.Server) func(c *gin.Context) {
 			return
 		}
 
+		if err := validateSourceRoots(&newSettings); err != nil {
+			c.JSON(http.StatusBadRequest, UpdateSettingsResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid source roots: %v", err),
+			})
+			return
+		}
+
+		if err := newSettings.HomeAssistant.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, UpdateSettingsResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid Home Assistant settings: %v", err),
+			})
+			return
+		}
+
+		if err := newSettings.Redaction.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, UpdateSettingsResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid redaction settings: %v", err),
+			})
+			return
+		}
+		if newSettings.Redaction.StoresRedacted() && !settings.Redaction.StoresRedacted() {
+			warnings = append(warnings, "New backups will be stored redacted and can only be restored while the live file still has the redacted values.")
+		}
+
+		for _, root := range newSettings.SourceRoots {
+			if !io.DirectoryExists(root.Path) {
+				warnings = append(warnings, fmt.Sprintf("Source root '%s' directory does not exist: %s", root.Name, root.Path))
+			}
+		}
+
+		if newSettings.QueueWorkers() != settings.QueueWorkers() || newSettings.QueueCapacity() != settings.QueueCapacity() {
+			warnings = append(warnings, "Workers and queue size changes apply after a restart.")
+		}
+
 		if len(newSettings.Configs) > 0 && len(newSettings.ConfigGroups) > 0 {
 			warnings = append(warnings, "Both old configs format and new config groups detected. Using config groups and ignoring old configs.")
 			newSettings.Configs = nil // Clear old format
@@ -217,25 +387,7 @@ func UpdateSettingsHandler(s *core.Server) func(c *gin.Context) {
 			return
 		}
 
-		cronChanged := false
-		oldSchedule := ""
-		newSchedule := ""
-		if s.AppSettings.CronSchedule != nil {
-			oldSchedule = *s.AppSettings.CronSchedule
-		}
-		if newSettings.CronSchedule != nil {
-			newSchedule = *newSettings.CronSchedule
-		}
-		if oldSchedule != newSchedule {
-			cronChanged = true
-		}
-
-		s.AppSettings = &newSettings
-
-		if cronChanged {
-			_ = s.RestartCronJob()
-			slog.Info("Cron schedule updated", "schedule", newSchedule)
-		}
+		s.ApplySettings(&newSettings)
 
 		slog.Info("Settings updated successfully")
 
@@ -1,8 +1,19 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"ha-config-history/internal/core"
 	"ha-config-history/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/gin-gonic/gin"
 )
 
 func TestValidateGroupName(t *testing.T) {
@@ -225,6 +236,78 @@ func TestValidateConfigGroups(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "multiple backup type with invalid missingIdStrategy",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup(
+					"Automations",
+					[]*types.ConfigBackupOptions{
+						{
+							Path:              "automations.yaml",
+							BackupType:        "multiple",
+							IdNode:            stringPtr("id"),
+							FriendlyNameNode:  stringPtr("alias"),
+							MissingIdStrategy: stringPtr("random"),
+						},
+					},
+				),
+			},
+			expectErr: true,
+		},
+		{
+			name: "multidocument backup type without idNode",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup(
+					"Packages",
+					[]*types.ConfigBackupOptions{
+						{Path: "packages.yaml", BackupType: "multidocument"},
+					},
+				),
+			},
+			expectErr: false,
+		},
+		{
+			name: "multidocument backup type with empty idNode",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup(
+					"Packages",
+					[]*types.ConfigBackupOptions{
+						{Path: "packages.yaml", BackupType: "multidocument", IdNode: stringPtr(" ")},
+					},
+				),
+			},
+			expectErr: true,
+		},
+		{
+			name: "nodered backup type",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup(
+					"Node-RED",
+					[]*types.ConfigBackupOptions{
+						types.NewNodeREDConfigBackupOptions("node-red/flows.json"),
+					},
+				),
+			},
+			expectErr: false,
+		},
+		{
+			name: "normalize rule with an empty segment",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup(
+					"Storage",
+					[]*types.ConfigBackupOptions{
+						{
+							Path:       ".storage/core.entity_registry",
+							BackupType: "single",
+							Normalize: &types.NormalizeOptions{
+								IgnorePaths: []string{"data..modified_at"},
+							},
+						},
+					},
+				),
+			},
+			expectErr: true,
+		},
 		{
 			name: "invalid maxBackups",
 			configGroups: []*types.ConfigBackupOptionGroup{
@@ -257,6 +340,75 @@ func TestValidateConfigGroups(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "invalid config cron schedule",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup(
+					"Core Home Assistant",
+					[]*types.ConfigBackupOptions{
+						{
+							Path:         "configuration.yaml",
+							BackupType:   "single",
+							CronSchedule: stringPtr("every night"),
+						},
+					},
+				),
+			},
+			expectErr: true,
+		},
+		{
+			name: "invalid group cron schedule",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				{
+					Name:         "Storage",
+					Slug:         "storage",
+					CronSchedule: stringPtr("0 3 * *"),
+					Configs:      []*types.ConfigBackupOptions{{Path: ".storage/core.entity_registry", BackupType: "single"}},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "reload after restore without a reload service",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup("ESPHome", []*types.ConfigBackupOptions{
+					{Path: "esphome/porch.yaml", BackupType: "single", ReloadAfterRestore: boolPtr(true)},
+				}),
+			},
+			expectErr: true,
+		},
+		{
+			name: "invalid reload service",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup("ESPHome", []*types.ConfigBackupOptions{
+					{Path: "esphome/porch.yaml", BackupType: "single", ReloadAfterRestore: boolPtr(true), ReloadService: stringPtr("reload")},
+				}),
+			},
+			expectErr: true,
+		},
+		{
+			name: "reload after restore with the file's default service",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup("Core Home Assistant", []*types.ConfigBackupOptions{
+					{Path: "scripts.yaml", BackupType: "single", ReloadAfterRestore: boolPtr(true)},
+					{Path: "template.yaml", BackupType: "single", ReloadAfterRestore: boolPtr(true), ReloadService: stringPtr("template.reload")},
+				}),
+			},
+			expectErr: false,
+		},
+		{
+			name: "valid group cron schedule",
+			configGroups: []*types.ConfigBackupOptionGroup{
+				{
+					Name:         "Storage",
+					Slug:         "storage",
+					CronSchedule: stringPtr("0 3 * * *"),
+					DisableWatch: true,
+					Configs:      []*types.ConfigBackupOptions{{Path: ".storage/core.entity_registry", BackupType: "single"}},
+				},
+			},
+			expectErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -269,6 +421,181 @@ func TestValidateConfigGroups(t *testing.T) {
 	}
 }
 
+func TestValidateSourceRoots(t *testing.T) {
+	groupWithRoot := func(root string) []*types.ConfigBackupOptionGroup {
+		return []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup("Zigbee", []*types.ConfigBackupOptions{
+				{Path: "zigbee2mqtt/configuration.yaml", BackupType: "single", Root: root},
+			}),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		sourceRoots []*types.SourceRoot
+		groups      []*types.ConfigBackupOptionGroup
+		expectErr   bool
+	}{
+		{
+			name:        "config in a defined root",
+			sourceRoots: []*types.SourceRoot{{Name: "addon_configs", Path: "/addon_configs"}},
+			groups:      groupWithRoot("addon_configs"),
+		},
+		{
+			name:   "config without a root uses homeassistant",
+			groups: groupWithRoot(""),
+		},
+		{
+			name:      "config in an unknown root",
+			groups:    groupWithRoot("share"),
+			expectErr: true,
+		},
+		{
+			name:        "root with a relative path",
+			sourceRoots: []*types.SourceRoot{{Name: "share", Path: "share"}},
+			expectErr:   true,
+		},
+		{
+			name:        "root replacing homeassistant",
+			sourceRoots: []*types.SourceRoot{{Name: "homeassistant", Path: "/config"}},
+			expectErr:   true,
+		},
+		{
+			name:        "root with an invalid name",
+			sourceRoots: []*types.SourceRoot{{Name: "Add-on Configs", Path: "/addon_configs"}},
+			expectErr:   true,
+		},
+		{
+			name:        "same path from two roots in a group",
+			sourceRoots: []*types.SourceRoot{{Name: "share", Path: "/share"}},
+			groups: []*types.ConfigBackupOptionGroup{
+				types.NewConfigBackupOptionGroup("Files", []*types.ConfigBackupOptions{
+					{Path: "notes.yaml", BackupType: "single"},
+					{Path: "notes.yaml", BackupType: "single", Root: "share"},
+				}),
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSourceRoots(&types.AppSettings{SourceRoots: tt.sourceRoots, ConfigGroups: tt.groups})
+			if (err != nil) != tt.expectErr {
+				t.Errorf("validateSourceRoots() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestSettingsNeverReturnHomeAssistantToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const token = "long-lived-admin-token"
+
+	dir := t.TempDir()
+	server := core.NewServer(&types.AppSettings{
+		HomeAssistantConfigDir: dir,
+		BackupDir:              dir,
+		HomeAssistant:          &types.HomeAssistantSettings{URL: "http://homeassistant.local:8123", Token: token},
+	}, filepath.Join(dir, "config.json"))
+
+	router := gin.New()
+	router.GET("/settings", GetSettingsHandler(server))
+	router.POST("/settings", UpdateSettingsHandler(server))
+
+	getSettings := func() string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/settings", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		return w.Body.String()
+	}
+
+	body := getSettings()
+	if strings.Contains(body, token) {
+		t.Fatalf("Expected the token to be masked, got %s", body)
+	}
+
+	// Saving the settings as they were read keeps the stored token
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/settings", bytes.NewBufferString(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if server.Settings().HomeAssistant.Token != token {
+		t.Errorf("Expected the stored token to be kept, got %q", server.Settings().HomeAssistant.Token)
+	}
+	if body := getSettings(); strings.Contains(body, token) {
+		t.Errorf("Expected the token to be masked after saving, got %s", body)
+	}
+
+	var returned types.AppSettings
+	if err := json.Unmarshal([]byte(getSettings()), &returned); err != nil || returned.HomeAssistant.Token != types.MaskedToken {
+		t.Errorf("Expected a masked token to show one is set, got %+v (%v)", returned.HomeAssistant, err)
+	}
+}
+
+func TestSettingsCanBeSavedWhileBackupsAreRead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "backups")
+	configDir := filepath.Join(backupDir, "test", "configuration.yaml", "configuration.yaml")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "20240101T120000.backup"), []byte("homeassistant:\n"), 0644); err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
+	}
+
+	settings := &types.AppSettings{
+		HomeAssistantConfigDir: dir,
+		BackupDir:              backupDir,
+		ConfigGroups: []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup("Test", []*types.ConfigBackupOptions{
+				types.NewSingleConfigBackupOptions("configuration.yaml"),
+			}),
+		},
+	}
+	body, _ := json.Marshal(settings)
+	server := core.NewServer(settings, filepath.Join(dir, "config.json"))
+
+	router := gin.New()
+	router.POST("/settings", UpdateSettingsHandler(server))
+	router.GET("/configs/:group/:path/:id/backups", ListConfigBackupsHandler(server))
+	router.GET("/configs/:group/:path/:id/backups/:filename", GetConfigBackupHandler(server))
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/settings", bytes.NewReader(body)))
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status 200 saving settings, got %d: %s", w.Code, w.Body.String())
+			}
+		}
+	}()
+	for _, url := range []string{
+		"/configs/test/configuration.yaml/configuration.yaml/backups",
+		"/configs/test/configuration.yaml/configuration.yaml/backups/20240101T120000.backup",
+	} {
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("Expected status 200 reading %s, got %d: %s", url, w.Code, w.Body.String())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 // Helper functions for tests
 func stringPtr(s string) *string {
 	return &s
@@ -277,3 +604,7 @@ func stringPtr(s string) *string {
 func intPtr(i int) *int {
 	return &i
 }
+
+func boolPtr(b bool) *bool {
+	return &b
+}
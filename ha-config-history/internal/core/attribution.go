@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"ha-config-history/internal/homeassistant"
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"time"
+)
+
+// attributionDelay gives Home Assistant time to write the activity behind a change to its
+// logbook before it is looked up.
+const attributionDelay = 10 * time.Second
+
+// scheduleAttribution looks up who made the change a new version captured, once Home
+// Assistant has had time to record it. It does nothing unless attribution is turned on,
+// and lookups still waiting when the server stops taking in work are dropped. The caller
+// must hold settingsMu.
+func (s *Server) scheduleAttribution(groupSlug types.GroupSlug, backup *types.ConfigBackup) {
+	if !s.AppSettings.HomeAssistant.AttributesChanges() {
+		return
+	}
+
+	identifier, changedAt := backup.ConfigBackupIdentifier, backup.ModifiedDate
+	go func() {
+		timer := time.NewTimer(attributionDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			return
+		}
+		_ = s.attributeVersion(groupSlug, identifier, changedAt)
+	}()
+}
+
+// attributeVersion records who made the change captured by the version saved at
+// changedAt, from the Home Assistant activity around that time.
+func (s *Server) attributeVersion(groupSlug types.GroupSlug, identifier types.ConfigBackupIdentifier, changedAt time.Time) error {
+	s.settingsMu.RLock()
+	settings := s.AppSettings.HomeAssistant
+	backupDir := s.AppSettings.BackupDir
+	s.settingsMu.RUnlock()
+
+	client := homeassistant.FromSettings(settings)
+	if client == nil {
+		return errors.New("Home Assistant API is not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+	attribution, err := client.Attribute(ctx, changedAt, settings.AttributionWindow(), homeassistant.DomainForPath(identifier.Path))
+	if err != nil {
+		slog.Warn("Could not look up who changed config", "path", identifier.Path, "id", identifier.ID, "error", err)
+		return err
+	}
+
+	filename := io.BackupFilename(changedAt)
+	if err := io.SaveVersionAttribution(backupDir, groupSlug, identifier.Path, identifier.ID, filename, attribution); err != nil {
+		slog.Warn("Error saving attribution", "path", identifier.Path, "id", identifier.ID, "error", err)
+		return err
+	}
+
+	slog.Info("Attributed config change",
+		"path", identifier.Path,
+		"id", identifier.ID,
+		"source", attribution.Source,
+		"user", attribution.User,
+	)
+	s.events.Publish(EventVersionAttributed, VersionEvent{
+		Group:    groupSlug,
+		Path:     identifier.Path,
+		ID:       identifier.ID,
+		Filename: filename,
+	})
+	return nil
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+)
+
+func TestAttributeVersion(t *testing.T) {
+	options := types.NewSingleConfigBackupOptions("automations.yaml")
+	server, haConfigDir := newRunTestServer(t, options)
+	writeTestFile(t, filepath.Join(haConfigDir, "automations.yaml"), "- id: porch\n")
+
+	server.StartRun(RunTriggerManual).Wait()
+	backups, err := io.ListConfigBackups(server.AppSettings.BackupDir, "test", "automations.yaml", "automations.yaml")
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected a version to be saved, got %v (%v)", backups, err)
+	}
+	changedAt := backups[0].Date
+
+	// A stand-in for Home Assistant where a user reloaded automations right after the save
+	homeAssistant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/logbook/"):
+			json.NewEncoder(w).Encode([]map[string]any{{
+				"when":               changedAt.Add(time.Second).Format(time.RFC3339),
+				"domain":             "automation",
+				"context_user_id":    "user-alex",
+				"context_event_type": "call_service",
+				"context_domain":     "automation",
+				"context_service":    "reload",
+			}})
+		case r.URL.Path == "/api/states":
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"entity_id": "person.alex", "attributes": map[string]any{"friendly_name": "Alex", "user_id": "user-alex"}},
+			})
+		}
+	}))
+	defer homeAssistant.Close()
+	server.AppSettings.HomeAssistant = &types.HomeAssistantSettings{URL: homeAssistant.URL, Token: "token"}
+
+	_, _, events, unsubscribe := server.Events().Subscribe(nil)
+	defer unsubscribe()
+
+	identifier := types.ConfigBackupIdentifier{Path: "automations.yaml", ID: "automations.yaml"}
+	if err := server.attributeVersion("test", identifier, changedAt); err != nil {
+		t.Fatalf("Expected the version to be attributed, got %v", err)
+	}
+
+	backups, _ = io.ListConfigBackups(server.AppSettings.BackupDir, "test", "automations.yaml", "automations.yaml")
+	if attribution := backups[0].Attribution; attribution == nil || attribution.User != "Alex" || attribution.Source != types.ChangeSourceUser {
+		t.Errorf("Expected the version to be attributed to Alex, got %+v", attribution)
+	}
+	// The run's own events may still be arriving
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type != EventVersionAttributed {
+				continue
+			}
+		case <-timeout:
+			t.Fatal("Expected a version.attributed event")
+		}
+		break
+	}
+}
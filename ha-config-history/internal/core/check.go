@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"ha-config-history/internal/homeassistant"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"time"
+)
+
+// ErrConfigCheckFailed is returned for a restore that was rolled back because Home
+// Assistant's config check failed after it.
+var ErrConfigCheckFailed = errors.New("restored version failed Home Assistant's config check")
+
+// ConfigCheckResult reports Home Assistant's config check after a restore.
+type ConfigCheckResult struct {
+	Valid      bool   `json:"valid"`
+	Errors     string `json:"errors,omitempty"`
+	Warnings   string `json:"warnings,omitempty"`
+	RolledBack bool   `json:"rolledBack,omitempty"` // The restore was undone because the check failed
+	Error      string `json:"error,omitempty"`      // The check could not be run
+}
+
+// ChecksConfigOnRestore reports whether restores of a config are checked by Home Assistant.
+func ChecksConfigOnRestore(configOptions *types.ConfigBackupOptions) bool {
+	return configOptions.CheckConfigOnRestore != nil && *configOptions.CheckConfigOnRestore
+}
+
+// CheckConfig asks Home Assistant to check its configuration. A check that could not be
+// run is reported in the result's Error and is not valid.
+func (s *Server) CheckConfig() *ConfigCheckResult {
+	s.settingsMu.RLock()
+	client := homeassistant.FromSettings(s.AppSettings.HomeAssistant)
+	s.settingsMu.RUnlock()
+
+	if client == nil {
+		return &ConfigCheckResult{Error: "Home Assistant API is not configured"}
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+	check, err := client.CheckConfig(ctx)
+	if err != nil {
+		slog.Warn("Error checking Home Assistant config", "error", err)
+		return &ConfigCheckResult{Error: err.Error()}
+	}
+
+	return &ConfigCheckResult{
+		Valid:    check.Valid(),
+		Errors:   check.Errors,
+		Warnings: check.Warnings,
+	}
+}
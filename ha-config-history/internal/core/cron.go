@@ -1,39 +1,135 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"ha-config-history/internal/types"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
+// ScheduledConfig is a config that is scanned on a cron schedule.
+type ScheduledConfig struct {
+	Group   types.GroupSlug `json:"group"`
+	Path    string          `json:"path"`
+	Watched bool            `json:"watched"` // Whether changes are also backed up as they happen
+}
+
+// Schedule is a cron schedule, when it next runs and the configs it scans.
+type Schedule struct {
+	Schedule string            `json:"schedule"`
+	NextRun  *time.Time        `json:"nextRun,omitempty"`
+	Configs  []ScheduledConfig `json:"configs"`
+}
+
+// RestartCronJob replaces the cron entries with one for each schedule that configs are
+// scanned on. An entry looks up its configs when it runs, so it only needs restarting when
+// the set of schedules changes.
 func (s *Server) RestartCronJob() error {
+	s.settingsMu.RLock()
+	schedules := s.AppSettings.Schedules()
+	s.settingsMu.RUnlock()
+
+	s.State.Mu.Lock()
+	defer s.State.Mu.Unlock()
+
 	if s.State.CronJob != nil {
 		s.State.CronJob.Stop()
 	}
+	s.State.CronJob = nil
+	s.State.CronEntries = nil
 
-	if s.AppSettings.CronSchedule == nil || *s.AppSettings.CronSchedule == "" {
+	if len(schedules) == 0 {
 		slog.Info("No cron schedule configured, cron job disabled")
-		s.State.CronJob = nil
 		return nil
 	}
 
-	schedule := *s.AppSettings.CronSchedule
-	slog.Info("Setting up cron job", "schedule", schedule)
+	cronJob := cron.New()
+	entries := map[cron.EntryID]string{}
+	var errs []error
+	for _, schedule := range schedules {
+		slog.Info("Setting up cron job", "schedule", schedule)
+		id, err := cronJob.AddFunc(schedule, func() { s.runCronJobOnce(schedule) })
+		if err != nil {
+			slog.Error("Failed to add cron job", "schedule", schedule, "error", err)
+			errs = append(errs, fmt.Errorf("failed to add cron job %q: %w", schedule, err))
+			continue
+		}
+		entries[id] = schedule
+	}
+	cronJob.Start()
+
+	s.State.CronJob = cronJob
+	s.State.CronEntries = entries
+	return errors.Join(errs...)
+}
 
-	s.State.CronJob = cron.New()
-	_, err := s.State.CronJob.AddFunc(*s.AppSettings.CronSchedule, s.runCronJobOnce)
-	if err != nil {
-		slog.Error("Failed to add cron job", "error", err)
-		return fmt.Errorf("failed to add cron job: %w", err)
+// runCronJobOnce scans the configs on a schedule.
+func (s *Server) runCronJobOnce(schedule string) {
+	s.settingsMu.RLock()
+	configs := []GroupedConfigBackupOptions{}
+	for _, config := range trackedConfigs(s.AppSettings) {
+		if s.AppSettings.Schedule(config.GroupSlug, config.Options) == schedule {
+			configs = append(configs, config)
+		}
 	}
-	s.State.CronJob.Start()
-	return nil
+	s.settingsMu.RUnlock()
+
+	if len(configs) == 0 {
+		return
+	}
+	slog.Info("Running scheduled backup", "schedule", schedule, "configs", len(configs))
+	s.startRun(RunTriggerScheduled, configs)
 }
 
-func (s *Server) runCronJobOnce() {
-	slog.Info("Running scheduled backup")
-	s.ProcessAllConfigOptions()
+// Schedules returns the cron schedules with the configs on each, soonest first.
+func (s *Server) Schedules() []Schedule {
+	nextRuns := map[string]time.Time{}
+	s.State.Mu.RLock()
+	if s.State.CronJob != nil {
+		for _, entry := range s.State.CronJob.Entries() {
+			nextRuns[s.State.CronEntries[entry.ID]] = entry.Next
+		}
+	}
+	s.State.Mu.RUnlock()
+
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+
+	schedules := []Schedule{}
+	for _, schedule := range s.AppSettings.Schedules() {
+		entry := Schedule{Schedule: schedule, Configs: []ScheduledConfig{}}
+		// A schedule that could not be added has no next run
+		if next, exists := nextRuns[schedule]; exists && !next.IsZero() {
+			entry.NextRun = &next
+		}
+		for _, config := range trackedConfigs(s.AppSettings) {
+			if s.AppSettings.Schedule(config.GroupSlug, config.Options) == schedule {
+				entry.Configs = append(entry.Configs, ScheduledConfig{
+					Group:   config.GroupSlug,
+					Path:    config.Options.Path,
+					Watched: s.AppSettings.Watches(config.GroupSlug, config.Options),
+				})
+			}
+		}
+		schedules = append(schedules, entry)
+	}
+
+	slices.SortStableFunc(schedules, func(a, b Schedule) int {
+		switch {
+		case a.NextRun == nil && b.NextRun == nil:
+			return 0
+		case a.NextRun == nil:
+			return 1
+		case b.NextRun == nil:
+			return -1
+		}
+		return a.NextRun.Compare(*b.NextRun)
+	})
+	return schedules
 }
 
 func ValidateCronSchedule(schedule string) error {
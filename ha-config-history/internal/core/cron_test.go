@@ -0,0 +1,65 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ha-config-history/internal/types"
+)
+
+func TestCronSchedulesPerGroup(t *testing.T) {
+	hourly := "0 * * * *"
+	nightly := "0 3 * * *"
+
+	automations := types.NewSingleConfigBackupOptions("automations.yaml")
+	registry := types.NewSingleConfigBackupOptions("core.entity_registry")
+	server, haConfigDir := newRunTestServer(t, automations)
+	storage := types.NewConfigBackupOptionGroup("Storage", []*types.ConfigBackupOptions{registry})
+	storage.CronSchedule = &nightly
+	storage.DisableWatch = true
+	server.AppSettings.CronSchedule = &hourly
+	server.AppSettings.ConfigGroups = append(server.AppSettings.ConfigGroups, storage)
+
+	writeTestFile(t, filepath.Join(haConfigDir, "automations.yaml"), "- id: a\n")
+	writeTestFile(t, filepath.Join(haConfigDir, "core.entity_registry"), "{}\n")
+
+	if err := server.RestartCronJob(); err != nil {
+		t.Fatalf("Failed to start cron: %v", err)
+	}
+	t.Cleanup(func() { server.State.CronJob.Stop() })
+
+	schedules := server.Schedules()
+	if len(schedules) != 2 {
+		t.Fatalf("Expected a schedule per group, got %+v", schedules)
+	}
+	for _, schedule := range schedules {
+		if schedule.NextRun == nil || len(schedule.Configs) != 1 {
+			t.Errorf("Expected a next run and one config, got %+v", schedule)
+		}
+	}
+	// The hourly schedule runs before the nightly one
+	if schedules[0].Schedule != hourly || !schedules[0].Configs[0].Watched {
+		t.Errorf("Expected the watched hourly schedule first, got %+v", schedules[0])
+	}
+	if schedules[1].Configs[0] != (ScheduledConfig{Group: "storage", Path: "core.entity_registry", Watched: false}) {
+		t.Errorf("Expected the unwatched registry on the nightly schedule, got %+v", schedules[1].Configs)
+	}
+
+	// A run on the nightly schedule only scans the registry, without watching it
+	server.runCronJobOnce(nightly)
+	server.scans.Wait()
+	server.WaitForInactive()
+
+	if _, err := os.Stat(filepath.Join(server.AppSettings.BackupDir, "storage", "core.entity_registry")); err != nil {
+		t.Errorf("Expected the registry to be backed up: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(server.AppSettings.BackupDir, "test")); !os.IsNotExist(err) {
+		t.Errorf("Expected automations not to be scanned on the nightly schedule, got %v", err)
+	}
+	server.State.Mu.RLock()
+	defer server.State.Mu.RUnlock()
+	if _, watched := server.State.FileLookup[filepath.Join(haConfigDir, "core.entity_registry")]; watched {
+		t.Error("Expected the registry not to be watched")
+	}
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"fmt"
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"os"
+)
+
+// CurrentConfigContent reads the live content of the config with the given id from its
+// file, as it would be backed up now.
+func (s *Server) CurrentConfigContent(options *types.ConfigBackupOptions, id string) ([]byte, error) {
+	s.settingsMu.RLock()
+	settings := s.AppSettings
+	s.settingsMu.RUnlock()
+
+	rootDir, err := configRootDir(settings, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var current []*types.ConfigBackup
+	switch options.BackupType {
+	case "single":
+		var configBackup *types.ConfigBackup
+		configBackup, err = io.ReadSingleConfigFromSingleFile(rootDir, options)
+		current = []*types.ConfigBackup{configBackup}
+	case "multiple":
+		current, err = io.ReadMultipleConfigsFromSingleFile(rootDir, options)
+	case "directory":
+		current, err = io.ReadMultipleConfigsFromDirectory(rootDir, options)
+	case "keyed":
+		current, err = io.ReadKeyedConfigsFromSingleFile(rootDir, options)
+	case "multidocument":
+		current, err = io.ReadMultipleDocumentsFromSingleFile(rootDir, options)
+	case "blueprint":
+		current, err = io.ReadBlueprintsFromDirectory(rootDir, options)
+	case "nodered":
+		current, err = io.ReadNodeREDFlowsFromSingleFile(rootDir, options)
+	default:
+		return nil, fmt.Errorf("unhandled backup type: %s", options.BackupType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, configBackup := range current {
+		if configBackup.ID == id {
+			return configBackup.Blob, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not in %s: %w", id, options.Path, os.ErrNotExist)
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// debouncer coalesces bursts of triggers for the same key into one call of fire, made once
+// no trigger for the key has arrived for the quiet period.
+type debouncer struct {
+	mu          sync.Mutex
+	quietPeriod func() time.Duration
+	timers      map[string]*time.Timer
+	fire        func(key string)
+	stopped     bool
+	// firing counts the calls of fire in progress
+	firing sync.WaitGroup
+}
+
+// newDebouncer creates a debouncer. quietPeriod is called on each trigger, so changes to
+// it apply to the next trigger.
+func newDebouncer(quietPeriod func() time.Duration, fire func(key string)) *debouncer {
+	return &debouncer{
+		quietPeriod: quietPeriod,
+		timers:      map[string]*time.Timer{},
+		fire:        fire,
+	}
+}
+
+// Trigger starts or restarts the quiet period for key.
+func (d *debouncer) Trigger(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	if timer, exists := d.timers[key]; exists {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d.quietPeriod(), func() {
+		d.mu.Lock()
+		// A newer trigger replaced this timer after it had already fired
+		if d.stopped || d.timers[key] != timer {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.timers, key)
+		d.firing.Add(1)
+		d.mu.Unlock()
+
+		defer d.firing.Done()
+		d.fire(key)
+	})
+	d.timers[key] = timer
+}
+
+// Drain ignores triggers from now on, calls fire straight away for every key still in its
+// quiet period and waits for all calls of fire to return.
+func (d *debouncer) Drain() {
+	d.mu.Lock()
+	d.stopped = true
+	// A timer that already expired but has not taken the lock yet will not fire now
+	pending := []string{}
+	for key, timer := range d.timers {
+		timer.Stop()
+		pending = append(pending, key)
+		delete(d.timers, key)
+	}
+	d.mu.Unlock()
+
+	for _, key := range pending {
+		d.fire(key)
+	}
+	d.firing.Wait()
+}
+
+// Stop cancels all pending calls. Triggers after Stop are ignored.
+func (d *debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stopped = true
+	for key, timer := range d.timers {
+		timer.Stop()
+		delete(d.timers, key)
+	}
+}
@@ -0,0 +1,35 @@
+package core
+
+import "github.com/fsnotify/fsnotify"
+
+// eventSource delivers file system events for watched directories. It is implemented by
+// fsnotify and replaced in tests.
+type eventSource interface {
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+	Add(path string) error
+	Remove(path string) error
+	WatchList() []string
+	Close() error
+}
+
+// fsnotifySource adapts an fsnotify watcher to eventSource.
+type fsnotifySource struct {
+	*fsnotify.Watcher
+}
+
+func newFsnotifySource() (*fsnotifySource, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &fsnotifySource{Watcher: watcher}, nil
+}
+
+func (f *fsnotifySource) Events() <-chan fsnotify.Event {
+	return f.Watcher.Events
+}
+
+func (f *fsnotifySource) Errors() <-chan error {
+	return f.Watcher.Errors
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"ha-config-history/internal/types"
+	"sync"
+	"time"
+)
+
+const (
+	// eventHistorySize is the number of recent events kept for clients to replay when
+	// they reconnect
+	eventHistorySize = 500
+	// subscriberBufferSize is the number of events a subscriber can fall behind by before
+	// it is disconnected
+	subscriberBufferSize = 64
+)
+
+type EventType string
+
+const (
+	EventVersionSaved      EventType = "version.saved"
+	EventVersionAttributed EventType = "version.attributed"
+	EventVersionsRemoved   EventType = "versions.removed"
+	EventConfigRestored    EventType = "config.restored"
+	EventSettingsUpdated   EventType = "settings.updated"
+	EventJobStarted        EventType = "job.started"
+	EventJobFinished       EventType = "job.finished"
+	// EventResync tells a reconnecting client that events were missed and it should
+	// reload everything
+	EventResync EventType = "resync"
+)
+
+// Event is a change published to clients of the event feed. IDs increase by one for each
+// event and start again from 1 when the add-on restarts.
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// VersionEvent is the data of version.saved and config.restored events.
+type VersionEvent struct {
+	Group    types.GroupSlug `json:"group"`
+	Path     string          `json:"path"`
+	ID       string          `json:"id"`
+	Filename string          `json:"filename,omitempty"`
+}
+
+// VersionsRemovedEvent is the data of versions.removed events, published when retention
+// limits remove old backups of a config.
+type VersionsRemovedEvent struct {
+	Group   types.GroupSlug `json:"group"`
+	Path    string          `json:"path"`
+	ID      string          `json:"id"`
+	Removed int             `json:"removed"`
+}
+
+// EventBus publishes events to subscribers and keeps the most recent ones so clients can
+// catch up on what they missed while disconnected.
+type EventBus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[chan Event]struct{}{}}
+}
+
+// Publish sends an event to every subscriber. Subscribers that have fallen too far behind
+// are disconnected so a slow client never holds up the server.
+func (b *EventBus) Publish(eventType EventType, data any) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: b.lastID, Type: eventType, Time: time.Now().UTC(), Data: data}
+
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			delete(b.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+
+	return event
+}
+
+// Subscribe returns the events published after the one with id since, followed by a
+// channel of new events. When since is nil no events are replayed. complete is false when
+// some of the events after since are no longer kept. The channel is closed when the
+// subscriber is disconnected or the bus is closed, and unsubscribe must be called once
+// the subscriber is done.
+func (b *EventBus) Subscribe(since *uint64) (replay []Event, complete bool, events <-chan Event, unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if since != nil {
+		for _, event := range b.history {
+			if event.ID > *since {
+				replay = append(replay, event)
+			}
+		}
+
+		// The cursor is from before the add-on restarted or older than the kept events
+		oldestKept := b.lastID + 1
+		if len(b.history) > 0 {
+			oldestKept = b.history[0].ID
+		}
+		complete = *since <= b.lastID && *since+1 >= oldestKept
+	}
+
+	subscriber := make(chan Event, subscriberBufferSize)
+	if b.closed {
+		close(subscriber)
+		return replay, complete, subscriber, func() {}
+	}
+	b.subscribers[subscriber] = struct{}{}
+
+	return replay, complete, subscriber, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, exists := b.subscribers[subscriber]; exists {
+			delete(b.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// Close disconnects every subscriber.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for subscriber := range b.subscribers {
+		delete(b.subscribers, subscriber)
+		close(subscriber)
+	}
+}
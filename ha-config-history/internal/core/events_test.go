@@ -0,0 +1,48 @@
+package core
+
+import "testing"
+
+func TestEventBusReplay(t *testing.T) {
+	bus := NewEventBus()
+	for range eventHistorySize + 10 {
+		bus.Publish(EventSettingsUpdated, struct{}{})
+	}
+
+	recent := uint64(eventHistorySize)
+	replay, complete, _, unsubscribe := bus.Subscribe(&recent)
+	unsubscribe()
+	if !complete || len(replay) != 10 || replay[0].ID != recent+1 {
+		t.Errorf("Expected the 10 events after the cursor, got %d (complete %v)", len(replay), complete)
+	}
+
+	old := uint64(1)
+	if _, complete, _, unsubscribe := bus.Subscribe(&old); complete {
+		t.Error("Expected a cursor older than the kept events to be incomplete")
+	} else {
+		unsubscribe()
+	}
+
+	replay, complete, _, unsubscribe = bus.Subscribe(nil)
+	unsubscribe()
+	if !complete || len(replay) != 0 {
+		t.Errorf("Expected no replay without a cursor, got %d events", len(replay))
+	}
+}
+
+func TestEventBusDisconnectsSlowSubscribers(t *testing.T) {
+	bus := NewEventBus()
+	_, _, events, unsubscribe := bus.Subscribe(nil)
+	defer unsubscribe()
+
+	for range subscriberBufferSize + 1 {
+		bus.Publish(EventSettingsUpdated, struct{}{})
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	if received != subscriberBufferSize {
+		t.Errorf("Expected %d buffered events before the subscriber was disconnected, got %d", subscriberBufferSize, received)
+	}
+}
@@ -7,83 +7,45 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 func (s *Server) startFileWatcher() {
+	s.changes = newDebouncer(s.watchQuietPeriod, s.handleChangedFile)
+
 	go func() {
 		for {
 			select {
-			case event, ok := <-s.fileWatcher.Events:
+			case <-s.ctx.Done():
+				return
+			case event, ok := <-s.fileWatcher.Events():
 				if !ok {
 					return
 				}
 				slog.Debug("File watcher event", "file", event.Name, "event", event.Op)
 
+				// Permission changes alone do not change content
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+
 				s.State.Mu.RLock()
-				optionsList, exists := s.State.FileLookup[event.Name]
+				_, exists := s.State.FileLookup[event.Name]
 				s.State.Mu.RUnlock()
 
-				if !exists {
+				// Files created in a directory config's folder are new configs
+				if !exists && !(event.Has(fsnotify.Create) && s.trackNewDirectoryFile(event.Name)) {
 					slog.Debug("No backup options found for changed file", "file", event.Name)
 					continue
 				}
 
-				for _, groupAndOptions := range optionsList {
-					options := groupAndOptions.Options
-					groupSlug := groupAndOptions.GroupSlug
-
-					if options.BackupType == "single" {
-						backup, err := io.ReadSingleConfigFromSingleFile(s.AppSettings.HomeAssistantConfigDir, options)
-						if err != nil {
-							slog.Error("Error reading updated config from file", "file", event.Name, "error", err)
-							continue
-						}
-
-						s.queue <- NewBackupJob(groupSlug, options, backup)
-					}
-
-					if options.BackupType == "directory" {
-						filename := filepath.Base(event.Name)
-						fullDirectory := filepath.Dir(event.Name)
-						backup, err := io.ReadSingleConfigFromSingleFilename(fullDirectory, filename, options)
-						if err != nil {
-							slog.Error("Error reading updated config from file", "file", event.Name, "error", err)
-							continue
-						}
-
-						s.queue <- NewBackupJob(groupSlug, options, backup)
-					}
-
-					if options.BackupType == "multiple" {
-						current, err := io.ReadMultipleConfigsFromSingleFile(s.AppSettings.HomeAssistantConfigDir, options)
-						if err != nil {
-							slog.Error("Error reading updated multiple configs from file", "file", event.Name, "error", err)
-							continue
-						}
-
-						for _, configBackup := range current {
-							s.queue <- NewBackupJob(groupSlug, options, configBackup)
-						}
-					}
-
-					if options.BackupType == "keyed" {
-						current, err := io.ReadKeyedConfigsFromSingleFile(s.AppSettings.HomeAssistantConfigDir, options)
-						if err != nil {
-							if errors.Is(err, os.ErrNotExist) {
-								slog.Debug("Keyed config file not found, skipping", "file", event.Name)
-								continue
-							}
-							slog.Error("Error reading updated keyed configs from file", "file", event.Name, "error", err)
-							continue
-						}
-
-						for _, configBackup := range current {
-							s.queue <- NewBackupJob(groupSlug, options, configBackup)
-						}
-					}
-				}
+				// Editors save with several events, and atomic saves remove or rename the
+				// file before creating it again. The file is read once events stop.
+				s.changes.Trigger(event.Name)
 
-			case err, ok := <-s.fileWatcher.Errors:
+			case err, ok := <-s.fileWatcher.Errors():
 				if !ok {
 					return
 				}
@@ -93,27 +55,216 @@ func (s *Server) startFileWatcher() {
 	}()
 }
 
-func (s *Server) watchDirectoryForFile(groupSlug types.GroupSlug, path string, options *types.ConfigBackupOptions) error {
-	directory := filepath.Dir(path)
-	slog.Info("Adding directory to watcher for file", "directory", directory, "file", options.Path)
-
-	for _, existing := range s.fileWatcher.WatchList() {
-		if existing == directory {
-			slog.Info("Directory already being watched", "directory", directory)
-			s.State.Mu.Lock()
-			s.State.FileLookup.AddOrUpdate(path, groupSlug, options)
-			s.State.Mu.Unlock()
-			return nil
+// handleChangedFile reads a watched file once its events have settled and queues backups
+// for the configs that track it. A file that is gone by then was deleted.
+func (s *Server) handleChangedFile(filePath string) {
+	s.settingsMu.RLock()
+	settings := s.AppSettings
+	s.settingsMu.RUnlock()
+
+	s.State.Mu.RLock()
+	optionsList := s.State.FileLookup[filePath]
+	s.State.Mu.RUnlock()
+
+	slog.Debug("Reading changed file", "file", filePath)
+
+	for _, groupAndOptions := range optionsList {
+		options := groupAndOptions.Options
+		groupSlug := groupAndOptions.GroupSlug
+
+		rootDir, err := configRootDir(settings, options)
+		if err != nil {
+			slog.Error("Skipping config that cannot be read from its source root", "path", options.Path, "error", err)
+			continue
+		}
+
+		if options.BackupType == "single" {
+			backup, err := io.ReadSingleConfigFromSingleFile(rootDir, options)
+			if errors.Is(err, os.ErrNotExist) {
+				s.queueDeletionIfTracked(groupSlug, options, types.ConfigBackupIdentifier{Path: options.Path, ID: options.Path}, nil)
+				continue
+			}
+			if err != nil {
+				slog.Error("Error reading updated config from file", "file", filePath, "error", err)
+				continue
+			}
+
+			s.enqueue(NewBackupJob(groupSlug, options, backup), nil)
+		}
+
+		if options.BackupType == "directory" {
+			filename := filepath.Base(filePath)
+			fullDirectory := filepath.Dir(filePath)
+			backup, err := io.ReadSingleConfigFromSingleFilename(fullDirectory, filename, options)
+			if errors.Is(err, os.ErrNotExist) {
+				s.queueDeletionIfTracked(groupSlug, options, types.ConfigBackupIdentifier{Path: options.Path, ID: filename}, nil)
+				continue
+			}
+			if err != nil {
+				slog.Error("Error reading updated config from file", "file", filePath, "error", err)
+				continue
+			}
+
+			s.enqueue(NewBackupJob(groupSlug, options, backup), nil)
+		}
+
+		if options.BackupType == "blueprint" {
+			blueprintsDir := filepath.Join(rootDir, options.Path)
+			relativePath, err := filepath.Rel(blueprintsDir, filePath)
+			if err != nil {
+				slog.Error("Error resolving blueprint path", "file", filePath, "error", err)
+				continue
+			}
+
+			backup, err := io.ReadBlueprintFromFile(rootDir, relativePath, options)
+			if errors.Is(err, os.ErrNotExist) {
+				s.queueDeletionIfTracked(groupSlug, options, types.ConfigBackupIdentifier{Path: options.Path, ID: types.BlueprintId(relativePath)}, nil)
+				continue
+			}
+			if err != nil {
+				slog.Error("Error reading updated blueprint from file", "file", filePath, "error", err)
+				continue
+			}
+
+			s.enqueue(NewBackupJob(groupSlug, options, backup), nil)
+		}
+
+		if options.BackupType == "multiple" {
+			current, err := io.ReadMultipleConfigsFromSingleFile(rootDir, options)
+			if errors.Is(err, os.ErrNotExist) {
+				s.queueRemovedEntries(settings, groupSlug, options, nil, nil)
+				continue
+			}
+			if err != nil {
+				slog.Error("Error reading updated multiple configs from file", "file", filePath, "error", err)
+				continue
+			}
+
+			s.queueRemovedEntries(settings, groupSlug, options, current, nil)
+			for _, configBackup := range current {
+				s.enqueue(NewBackupJob(groupSlug, options, configBackup), nil)
+			}
+		}
+
+		if options.BackupType == "keyed" {
+			current, err := io.ReadKeyedConfigsFromSingleFile(rootDir, options)
+			if errors.Is(err, os.ErrNotExist) {
+				s.queueRemovedEntries(settings, groupSlug, options, nil, nil)
+				continue
+			}
+			if err != nil {
+				slog.Error("Error reading updated keyed configs from file", "file", filePath, "error", err)
+				continue
+			}
+
+			s.queueRemovedEntries(settings, groupSlug, options, current, nil)
+			for _, configBackup := range current {
+				s.enqueue(NewBackupJob(groupSlug, options, configBackup), nil)
+			}
 		}
+
+		if options.BackupType == "multidocument" {
+			current, err := io.ReadMultipleDocumentsFromSingleFile(rootDir, options)
+			if errors.Is(err, os.ErrNotExist) {
+				s.queueRemovedEntries(settings, groupSlug, options, nil, nil)
+				continue
+			}
+			if err != nil {
+				slog.Error("Error reading updated multi-document configs from file", "file", filePath, "error", err)
+				continue
+			}
+
+			s.queueRemovedEntries(settings, groupSlug, options, current, nil)
+			for _, configBackup := range current {
+				s.enqueue(NewBackupJob(groupSlug, options, configBackup), nil)
+			}
+		}
+
+		if options.BackupType == "nodered" {
+			current, err := io.ReadNodeREDFlowsFromSingleFile(rootDir, options)
+			if errors.Is(err, os.ErrNotExist) {
+				s.queueRemovedEntries(settings, groupSlug, options, nil, nil)
+				continue
+			}
+			if err != nil {
+				slog.Error("Error reading updated Node-RED flows from file", "file", filePath, "error", err)
+				continue
+			}
+
+			s.queueRemovedEntries(settings, groupSlug, options, current, nil)
+			for _, configBackup := range current {
+				s.enqueue(NewBackupJob(groupSlug, options, configBackup), nil)
+			}
+		}
+	}
+}
+
+func (s *Server) watchDirectoryForFile(groupSlug types.GroupSlug, path string, options *types.ConfigBackupOptions) error {
+	slog.Info("Adding directory to watcher for file", "directory", filepath.Dir(path), "file", options.Path)
+	err := s.watchDirectory(filepath.Dir(path))
+
+	s.State.Mu.Lock()
+	s.State.FileLookup.AddOrUpdate(path, groupSlug, options)
+	s.State.Mu.Unlock()
+	return err
+}
+
+// watchDirectory adds a directory to the watcher unless it is already watched.
+func (s *Server) watchDirectory(directory string) error {
+	if slices.Contains(s.fileWatcher.WatchList(), directory) {
+		slog.Info("Directory already being watched", "directory", directory)
+		return nil
 	}
 
 	err := s.fileWatcher.Add(directory)
 	if err != nil {
 		slog.Error("Error adding directory watcher", "error", err)
 	}
+	return err
+}
+
+// trackNewDirectoryFile adds a file created in the folder of a directory config to the
+// watched files when it matches the config's patterns, so its first version is backed up
+// without waiting for a scan. It reports whether any config tracks the file.
+func (s *Server) trackNewDirectoryFile(filePath string) bool {
+	s.settingsMu.RLock()
+	settings := s.AppSettings
+	s.settingsMu.RUnlock()
+
+	matches := []GroupedConfigBackupOptions{}
+	for _, config := range trackedConfigs(settings) {
+		if config.Options.BackupType != "directory" || !settings.Watches(config.GroupSlug, config.Options) {
+			continue
+		}
+
+		rootDir, err := settings.RootDir(config.Options)
+		if err != nil || filepath.Join(rootDir, config.Options.Path) != filepath.Dir(filePath) {
+			continue
+		}
+
+		included, err := io.DirectoryIncludesFile(config.Options, filepath.Base(filePath))
+		if err != nil {
+			slog.Error("Error matching new file against directory config", "file", filePath, "path", config.Options.Path, "error", err)
+			continue
+		}
+		if included {
+			matches = append(matches, config)
+		}
+	}
+	if len(matches) == 0 {
+		return false
+	}
 
+	// Folders created inside the directory are not configs
+	if info, err := os.Stat(filePath); err != nil || info.IsDir() {
+		return false
+	}
+
+	slog.Info("New file in watched directory", "file", filePath)
 	s.State.Mu.Lock()
-	s.State.FileLookup.AddOrUpdate(path, groupSlug, options)
+	for _, config := range matches {
+		s.State.FileLookup.AddOrUpdate(filePath, config.GroupSlug, config.Options)
+	}
 	s.State.Mu.Unlock()
-	return err
+	return true
 }
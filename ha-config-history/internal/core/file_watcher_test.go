@@ -0,0 +1,258 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"ha-config-history/internal/types"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fakeEventSource is an eventSource whose events are sent by the test.
+type fakeEventSource struct {
+	mu      sync.Mutex
+	events  chan fsnotify.Event
+	errors  chan error
+	watched []string
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newFakeEventSource() *fakeEventSource {
+	return &fakeEventSource{
+		events: make(chan fsnotify.Event),
+		errors: make(chan error),
+		closed: make(chan struct{}),
+	}
+}
+
+func (f *fakeEventSource) Events() <-chan fsnotify.Event { return f.events }
+func (f *fakeEventSource) Errors() <-chan error          { return f.errors }
+
+func (f *fakeEventSource) Add(path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watched = append(f.watched, path)
+	return nil
+}
+
+func (f *fakeEventSource) Remove(path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watched = slices.DeleteFunc(f.watched, func(watched string) bool { return watched == path })
+	return nil
+}
+
+func (f *fakeEventSource) WatchList() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.watched)
+}
+
+// Close stops delivering events. Sends after Close are dropped.
+func (f *fakeEventSource) Close() error {
+	f.once.Do(func() { close(f.closed) })
+	return nil
+}
+
+func (f *fakeEventSource) send(name string, ops ...fsnotify.Op) {
+	for _, op := range ops {
+		select {
+		case f.events <- fsnotify.Event{Name: name, Op: op}:
+		case <-f.closed:
+			return
+		}
+	}
+}
+
+const testQuietPeriod = 50 * time.Millisecond
+
+func newWatcherTestServer(t *testing.T, configs ...*types.ConfigBackupOptions) (chan backupJob, *Server, *fakeEventSource, string) {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	haConfigDir := filepath.Join(tempDir, "ha-config")
+	if err := os.MkdirAll(haConfigDir, 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+
+	quietPeriodMs := int(testQuietPeriod / time.Millisecond)
+	source := newFakeEventSource()
+	server := newServer(&types.AppSettings{
+		HomeAssistantConfigDir: haConfigDir,
+		BackupDir:              filepath.Join(tempDir, "backups"),
+		WatchQuietPeriodMs:     &quietPeriodMs,
+		ConfigGroups: []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup("Test", configs),
+		},
+	}, filepath.Join(tempDir, "settings.json"), source)
+
+	// Jobs are collected by the test instead of being processed
+	jobs := make(chan backupJob, 100)
+	server.queue.Close()
+	server.queue = newJobQueue(1, 100, func(job backupJob) { jobs <- job })
+
+	for _, options := range configs {
+		if err := server.watchDirectoryForFile("test", filepath.Join(haConfigDir, options.Path), options); err != nil {
+			t.Fatalf("Failed to watch %s: %v", options.Path, err)
+		}
+	}
+
+	server.startFileWatcher()
+	t.Cleanup(server.changes.Stop)
+
+	return jobs, server, source, haConfigDir
+}
+
+// collectJobs returns the jobs queued until nothing has been queued for a while.
+func collectJobs(queued chan backupJob) []backupJob {
+	jobs := []backupJob{}
+	for {
+		select {
+		case job := <-queued:
+			jobs = append(jobs, job)
+		case <-time.After(4 * testQuietPeriod):
+			return jobs
+		}
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestFileWatcherCoalescesBursts(t *testing.T) {
+	queued, _, source, haConfigDir := newWatcherTestServer(t, types.NewMultipleConfigBackupOptions("automations.yaml", "id", "alias"))
+	automations := filepath.Join(haConfigDir, "automations.yaml")
+	writeTestFile(t, automations, "- id: one\n  alias: One\n- id: two\n  alias: Two\n")
+
+	go func() {
+		source.send(automations, fsnotify.Write, fsnotify.Chmod)
+		time.Sleep(testQuietPeriod / 2)
+		source.send(automations, fsnotify.Write, fsnotify.Write)
+	}()
+
+	jobs := collectJobs(queued)
+	if len(jobs) != 2 {
+		t.Fatalf("Expected the file to be read once with a job per entry, got %d jobs", len(jobs))
+	}
+	if jobs[0].Backup.ID != "one" || jobs[1].Backup.ID != "two" {
+		t.Errorf("Unexpected jobs %s and %s", jobs[0].Backup.ID, jobs[1].Backup.ID)
+	}
+}
+
+func TestFileWatcherIgnoresPermissionChanges(t *testing.T) {
+	queued, _, source, haConfigDir := newWatcherTestServer(t, types.NewSingleConfigBackupOptions("configuration.yaml"))
+	configuration := filepath.Join(haConfigDir, "configuration.yaml")
+	writeTestFile(t, configuration, "homeassistant:\n")
+
+	go source.send(configuration, fsnotify.Chmod)
+
+	if jobs := collectJobs(queued); len(jobs) != 0 {
+		t.Errorf("Expected no jobs for a permission change, got %d", len(jobs))
+	}
+}
+
+func TestFileWatcherHandlesAtomicSaves(t *testing.T) {
+	configuration := types.NewSingleConfigBackupOptions("configuration.yaml")
+	queued, server, source, haConfigDir := newWatcherTestServer(t, configuration)
+	configurationPath := filepath.Join(haConfigDir, "configuration.yaml")
+
+	identifier := types.ConfigBackupIdentifier{Path: "configuration.yaml", ID: "configuration.yaml"}
+	server.State.CachedBackupSummaries["test"] = types.BackupConfigSummaryMap{
+		identifier: {ConfigBackupIdentifier: identifier, LastHash: "old"},
+	}
+
+	t.Run("a replaced file is backed up, not deleted", func(t *testing.T) {
+		writeTestFile(t, configurationPath, "homeassistant:\n  name: Home\n")
+
+		// The editor moves the old file aside and renames the new content into place
+		go source.send(configurationPath, fsnotify.Rename, fsnotify.Create)
+
+		jobs := collectJobs(queued)
+		if len(jobs) != 1 || jobs[0].Action != jobActionSave {
+			t.Fatalf("Expected one save job, got %+v", jobs)
+		}
+		if string(jobs[0].Backup.Blob) != "homeassistant:\n  name: Home\n" {
+			t.Errorf("Expected the new content, got %q", jobs[0].Backup.Blob)
+		}
+	})
+
+	t.Run("a file that stays removed is deleted", func(t *testing.T) {
+		if err := os.Remove(configurationPath); err != nil {
+			t.Fatalf("Failed to remove file: %v", err)
+		}
+
+		go source.send(configurationPath, fsnotify.Remove)
+
+		jobs := collectJobs(queued)
+		if len(jobs) != 1 || jobs[0].Action != jobActionDelete {
+			t.Fatalf("Expected one delete job, got %+v", jobs)
+		}
+	})
+}
+
+func TestFileWatcherDebouncesPathsIndependently(t *testing.T) {
+	queued, _, source, haConfigDir := newWatcherTestServer(t,
+		types.NewSingleConfigBackupOptions("configuration.yaml"),
+		types.NewSingleConfigBackupOptions("customize.yaml"),
+	)
+	configuration := filepath.Join(haConfigDir, "configuration.yaml")
+	customize := filepath.Join(haConfigDir, "customize.yaml")
+	writeTestFile(t, configuration, "homeassistant:\n")
+	writeTestFile(t, customize, "light.porch:\n  friendly_name: Porch\n")
+
+	go func() {
+		source.send(configuration, fsnotify.Write)
+		source.send(customize, fsnotify.Write)
+		source.send(configuration, fsnotify.Write)
+	}()
+
+	jobs := collectJobs(queued)
+	paths := []string{}
+	for _, job := range jobs {
+		paths = append(paths, job.Backup.Path)
+	}
+	slices.Sort(paths)
+	if !slices.Equal(paths, []string{"configuration.yaml", "customize.yaml"}) {
+		t.Errorf("Expected one job per file, got %v", paths)
+	}
+}
+
+func TestFileWatcherPicksUpNewDirectoryFiles(t *testing.T) {
+	queued, _, source, haConfigDir := newWatcherTestServer(t, types.NewDirectoryConfigBackupOptions("esphome", []string{"*.yaml"}, []string{"secrets.yaml"}))
+	esphomeDir := filepath.Join(haConfigDir, "esphome")
+	if err := os.MkdirAll(filepath.Join(esphomeDir, ".esphome.yaml"), 0755); err != nil {
+		t.Fatalf("Failed to create esphome directory: %v", err)
+	}
+	garage := filepath.Join(esphomeDir, "garage.yaml")
+	writeTestFile(t, garage, "esphome:\n  name: garage\n")
+	writeTestFile(t, filepath.Join(esphomeDir, "secrets.yaml"), "wifi_password: secret\n")
+	writeTestFile(t, filepath.Join(esphomeDir, "notes.txt"), "todo\n")
+
+	go func() {
+		source.send(garage, fsnotify.Create, fsnotify.Write)
+		source.send(filepath.Join(esphomeDir, "secrets.yaml"), fsnotify.Create)
+		source.send(filepath.Join(esphomeDir, "notes.txt"), fsnotify.Create)
+		source.send(filepath.Join(esphomeDir, ".esphome.yaml"), fsnotify.Create)
+	}()
+
+	jobs := collectJobs(queued)
+	if len(jobs) != 1 || jobs[0].Action != jobActionSave || jobs[0].Backup.ID != "garage.yaml" {
+		t.Fatalf("Expected one save job for the new file, got %+v", jobs)
+	}
+
+	// Later changes to the new file are tracked like any other
+	go source.send(garage, fsnotify.Write)
+	if jobs := collectJobs(queued); len(jobs) != 1 {
+		t.Errorf("Expected the new file to be watched, got %d jobs", len(jobs))
+	}
+}
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// journalFolderName is the folder in the backup directory that holds the job journal. It
+// starts with a dot so it is never read as a group.
+const journalFolderName = ".journal"
+
+// journalEntry is a queued backup job as written to the journal, with the captured content
+// the backup leaves out of its JSON.
+type journalEntry struct {
+	id uint64
+
+	Action      jobAction           `json:"action"`
+	GroupSlug   types.GroupSlug     `json:"group"`
+	ConfigPath  string              `json:"configPath"`
+	Backup      *types.ConfigBackup `json:"backup"`
+	FilePath    string              `json:"filePath,omitempty"`
+	Blob        []byte              `json:"blob,omitempty"`
+	PreviousIds []string            `json:"previousIds,omitempty"`
+	RenamedTo   string              `json:"renamedTo,omitempty"`
+}
+
+// jobJournal is a write-ahead journal of queued backup jobs. Each job is written to its own
+// file before it is queued and removed once it has been processed, so jobs that were still
+// queued when the add-on stopped are found again on the next start.
+type jobJournal struct {
+	dir string
+
+	mu   sync.Mutex
+	next uint64
+}
+
+// openJobJournal opens the journal in dir, which is created on the first write.
+func openJobJournal(dir string) *jobJournal {
+	j := &jobJournal{dir: dir, next: 1}
+	if ids, err := j.ids(); err == nil && len(ids) > 0 {
+		j.next = ids[len(ids)-1] + 1
+	}
+	return j
+}
+
+// record writes a job to the journal and returns the id it is stored under. When backups
+// are stored redacted the captured content is redacted too, so inline secrets never reach
+// the disk. Replaying it saves the same backup, as redacting it again changes nothing.
+func (j *jobJournal) record(job backupJob, redaction *types.RedactionSettings) (uint64, error) {
+	entry := journalEntry{
+		Action:      job.Action,
+		GroupSlug:   job.GroupSlug,
+		ConfigPath:  job.Options.Path,
+		Backup:      job.Backup,
+		FilePath:    job.Backup.FilePath,
+		Blob:        job.Backup.Blob,
+		PreviousIds: job.Backup.PreviousIds,
+		RenamedTo:   job.RenamedTo,
+	}
+	if redaction.StoresRedacted() {
+		entry.Blob = redaction.Redact(entry.Blob)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+
+	j.mu.Lock()
+	id := j.next
+	j.next++
+	j.mu.Unlock()
+
+	if err := os.MkdirAll(j.dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create journal folder: %w", err)
+	}
+
+	// The entry is synced under a temporary name so a crash never leaves half of it behind
+	path := j.path(id)
+	tmp, err := os.CreateTemp(j.dir, ".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create journal entry: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	return id, nil
+}
+
+// complete removes a processed job from the journal.
+func (j *jobJournal) complete(id uint64) {
+	if err := os.Remove(j.path(id)); err != nil && !os.IsNotExist(err) {
+		slog.Error("Error removing job from journal", "id", id, "error", err)
+	}
+}
+
+// completeJournaled removes a job from the journal once it no longer needs replaying.
+func (s *Server) completeJournaled(job backupJob) {
+	if job.journalID != 0 {
+		s.journal.complete(job.journalID)
+	}
+}
+
+// pending returns the jobs in the journal in the order they were queued. Entries that
+// cannot be read are logged and removed.
+func (j *jobJournal) pending() ([]journalEntry, error) {
+	ids, err := j.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []journalEntry{}
+	for _, id := range ids {
+		data, err := os.ReadFile(j.path(id))
+		entry := journalEntry{id: id}
+		if err == nil {
+			err = json.Unmarshal(data, &entry)
+		}
+		if err == nil && entry.Backup == nil {
+			err = fmt.Errorf("entry has no backup")
+		}
+		if err != nil {
+			slog.Error("Discarding unreadable journal entry", "id", id, "error", err)
+			j.complete(id)
+			continue
+		}
+
+		entry.Backup.FilePath = entry.FilePath
+		entry.Backup.Blob = entry.Blob
+		entry.Backup.PreviousIds = entry.PreviousIds
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ids returns the ids of the entries in the journal in ascending order.
+func (j *jobJournal) ids() ([]uint64, error) {
+	files, err := os.ReadDir(j.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal folder %s: %w", j.dir, err)
+	}
+
+	ids := []uint64{}
+	for _, file := range files {
+		name, isEntry := strings.CutSuffix(file.Name(), ".json")
+		if file.IsDir() || !isEntry {
+			continue
+		}
+		if id, err := strconv.ParseUint(name, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (j *jobJournal) path(id uint64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%020d.json", id))
+}
+
+// replayJournal queues the jobs left in the journal by the last run of the add-on, before
+// anything else is queued so they are processed first. A save whose content has already
+// been backed up is skipped by its hash like any other, so replay never duplicates a
+// version.
+func (s *Server) replayJournal() {
+	if s.journal == nil {
+		return
+	}
+
+	entries, err := s.journal.pending()
+	if err != nil {
+		slog.Error("Error reading job journal", "error", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	slog.Info("Replaying backup jobs from journal", "jobs", len(entries))
+
+	// Jobs are queued after settingsMu is released, as a full queue waits on workers that
+	// need it
+	jobs := []backupJob{}
+	s.settingsMu.RLock()
+	for _, entry := range entries {
+		options := s.trackedOptions(entry.GroupSlug, entry.ConfigPath)
+		if options == nil {
+			slog.Debug("Discarding journal entry for config that is no longer tracked", "group", entry.GroupSlug, "path", entry.ConfigPath)
+			s.journal.complete(entry.id)
+			continue
+		}
+
+		jobs = append(jobs, backupJob{
+			Action:    entry.Action,
+			GroupSlug: entry.GroupSlug,
+			Options:   options,
+			Backup:    entry.Backup,
+			RenamedTo: entry.RenamedTo,
+			journalID: entry.id,
+		})
+	}
+	s.settingsMu.RUnlock()
+
+	for _, job := range jobs {
+		s.queue.Enqueue(job)
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+)
+
+func countBackupVersions(t *testing.T, configDir string) int {
+	t.Helper()
+
+	files, err := os.ReadDir(configDir)
+	if err != nil {
+		t.Fatalf("Failed to read backups: %v", err)
+	}
+	count := 0
+	for _, file := range files {
+		if file.Name() != "metadata.json" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestJournalReplaysJobsLostOnRestart(t *testing.T) {
+	options := types.NewSingleConfigBackupOptions("configuration.yaml")
+	server, haConfigDir := newRunTestServer(t, options)
+	writeTestFile(t, filepath.Join(haConfigDir, "configuration.yaml"), "homeassistant:\n  name: Home\n")
+
+	backup, err := io.ReadSingleConfigFromSingleFile(haConfigDir, options)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+
+	// The add-on stops before the job is processed
+	server.queue.Close()
+	server.enqueue(NewBackupJob("test", options, backup), nil)
+
+	// The file changes again before the next start, so only the journal has this version
+	writeTestFile(t, filepath.Join(haConfigDir, "configuration.yaml"), "homeassistant:\n  name: House\n")
+
+	restarted := newServer(server.AppSettings, server.ConfigPath, newFakeEventSource())
+	t.Cleanup(restarted.queue.Close)
+	if _, exists := restarted.State.CachedBackupSummaries[journalFolderName]; exists {
+		t.Error("Expected the journal folder not to be loaded as a group")
+	}
+	restarted.replayJournal()
+	restarted.WaitForInactive()
+
+	configDir := filepath.Join(server.AppSettings.BackupDir, "test", "configuration.yaml", "configuration.yaml")
+	if count := countBackupVersions(t, configDir); count != 1 {
+		t.Errorf("Expected the journaled version to be saved, got %d versions", count)
+	}
+	if entries, _ := restarted.journal.pending(); len(entries) != 0 {
+		t.Errorf("Expected the journal to be empty after replay, got %d entries", len(entries))
+	}
+
+	// A job that was processed but not removed from the journal before a crash is replayed
+	// without saving the version again
+	if _, err := restarted.journal.record(NewBackupJob("test", options, backup), nil); err != nil {
+		t.Fatalf("Failed to write journal entry: %v", err)
+	}
+	again := newServer(server.AppSettings, server.ConfigPath, newFakeEventSource())
+	t.Cleanup(again.queue.Close)
+	again.replayJournal()
+	again.WaitForInactive()
+
+	if count := countBackupVersions(t, configDir); count != 1 {
+		t.Errorf("Expected replay not to duplicate the version, got %d versions", count)
+	}
+	if entries, _ := again.journal.pending(); len(entries) != 0 {
+		t.Errorf("Expected the journal to be empty after replay, got %d entries", len(entries))
+	}
+}
+
+func TestJournalDiscardsJobsForUntrackedConfigs(t *testing.T) {
+	options := types.NewSingleConfigBackupOptions("configuration.yaml")
+	server, _ := newRunTestServer(t)
+
+	backup := &types.ConfigBackup{
+		ConfigBackupIdentifier: types.ConfigBackupIdentifier{Path: options.Path, ID: options.Path},
+		Blob:                   []byte("homeassistant:\n"),
+	}
+	if _, err := server.journal.record(NewBackupJob("test", options, backup), nil); err != nil {
+		t.Fatalf("Failed to write journal entry: %v", err)
+	}
+
+	server.replayJournal()
+	server.WaitForInactive()
+
+	if entries, _ := server.journal.pending(); len(entries) != 0 {
+		t.Errorf("Expected the entry to be discarded, got %d entries", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(server.AppSettings.BackupDir, "test")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be backed up, got %v", err)
+	}
+}
+
+func TestJournalRedactsStoredBackups(t *testing.T) {
+	options := types.NewSingleConfigBackupOptions("configuration.yaml")
+	server, haConfigDir := newRunTestServer(t, options)
+	server.AppSettings.Redaction = &types.RedactionSettings{Keys: []string{"*password*"}, RedactStoredBackups: true}
+	writeTestFile(t, filepath.Join(haConfigDir, "configuration.yaml"), "mqtt:\n  password: hunter2\n")
+
+	backup, err := io.ReadSingleConfigFromSingleFile(haConfigDir, options)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	server.queue.Close()
+	server.enqueue(NewBackupJob("test", options, backup), nil)
+
+	// The content is stored base64 encoded, so it is checked once decoded
+	entries, err := server.journal.pending()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected one journal entry, got %d (%v)", len(entries), err)
+	}
+	if strings.Contains(string(entries[0].Blob), "hunter2") {
+		t.Errorf("Expected the password to be redacted in the journal, got %s", entries[0].Blob)
+	}
+	if !strings.Contains(string(entries[0].Blob), types.RedactedPlaceholder) {
+		t.Errorf("Expected the journaled content to be redacted, got %s", entries[0].Blob)
+	}
+}
+
+func TestJournalKeepsJobsThatFail(t *testing.T) {
+	options := types.NewSingleConfigBackupOptions("configuration.yaml")
+	server, haConfigDir := newRunTestServer(t, options)
+	writeTestFile(t, filepath.Join(haConfigDir, "configuration.yaml"), "homeassistant:\n  name: Home\n")
+
+	// A file in place of the group's folder makes saving the backup fail
+	if err := os.MkdirAll(server.AppSettings.BackupDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup directory: %v", err)
+	}
+	writeTestFile(t, filepath.Join(server.AppSettings.BackupDir, "test"), "")
+
+	server.StartRun(RunTriggerManual).Wait()
+	server.WaitForInactive()
+
+	if entries, _ := server.journal.pending(); len(entries) != 1 {
+		t.Errorf("Expected the failed job to stay in the journal, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,276 @@
+package core
+
+import (
+	"ha-config-history/internal/types"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileSnapshot is what a polled directory knew about a file at the last check.
+type fileSnapshot struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// pollingSource is an eventSource that checks watched directories on an interval, for
+// file systems such as network shares that never deliver fsnotify events. A file whose
+// modification time or size changed is only reported when its content changed too.
+type pollingSource struct {
+	interval func() time.Duration
+
+	mu          sync.Mutex
+	directories map[string]map[string]fileSnapshot
+
+	events    chan fsnotify.Event
+	errors    chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPollingSource(interval func() time.Duration) *pollingSource {
+	p := &pollingSource{
+		interval:    interval,
+		directories: map[string]map[string]fileSnapshot{},
+		events:      make(chan fsnotify.Event),
+		errors:      make(chan error),
+		done:        make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *pollingSource) Events() <-chan fsnotify.Event { return p.events }
+func (p *pollingSource) Errors() <-chan error          { return p.errors }
+
+// Add starts polling a directory. The files already in it are not reported.
+func (p *pollingSource) Add(path string) error {
+	snapshot, err := snapshotDirectory(path, nil)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.directories[path] = snapshot
+	return nil
+}
+
+func (p *pollingSource) Remove(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.directories[path]; !exists {
+		return fsnotify.ErrNonExistentWatch
+	}
+	delete(p.directories, path)
+	return nil
+}
+
+func (p *pollingSource) WatchList() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Sorted(maps.Keys(p.directories))
+}
+
+// Close stops polling. The event channels are closed once the current check has finished.
+func (p *pollingSource) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return nil
+}
+
+func (p *pollingSource) run() {
+	defer close(p.events)
+	defer close(p.errors)
+
+	timer := time.NewTimer(p.interval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-timer.C:
+			for _, event := range p.poll() {
+				select {
+				case p.events <- event:
+				case <-p.done:
+					return
+				}
+			}
+			timer.Reset(p.interval())
+		}
+	}
+}
+
+// poll checks every watched directory and returns events for the files that were created,
+// changed or removed since the last check.
+func (p *pollingSource) poll() []fsnotify.Event {
+	p.mu.Lock()
+	directories := maps.Clone(p.directories)
+	p.mu.Unlock()
+
+	events := []fsnotify.Event{}
+	for directory, previous := range directories {
+		current, err := snapshotDirectory(directory, previous)
+		if err != nil {
+			// A directory that is gone, or a share that is unavailable, has no files
+			slog.Debug("Error polling directory", "directory", directory, "error", err)
+			current = map[string]fileSnapshot{}
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(current)) {
+			before, existed := previous[name]
+			switch {
+			case !existed:
+				events = append(events, fsnotify.Event{Name: filepath.Join(directory, name), Op: fsnotify.Create})
+			case before.hash != current[name].hash:
+				events = append(events, fsnotify.Event{Name: filepath.Join(directory, name), Op: fsnotify.Write})
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(previous)) {
+			if _, exists := current[name]; !exists {
+				events = append(events, fsnotify.Event{Name: filepath.Join(directory, name), Op: fsnotify.Remove})
+			}
+		}
+
+		p.mu.Lock()
+		if _, watched := p.directories[directory]; watched {
+			p.directories[directory] = current
+		}
+		p.mu.Unlock()
+	}
+
+	return events
+}
+
+// snapshotDirectory records the files in a directory. Files are only hashed when their
+// modification time or size differs from the previous snapshot.
+func snapshotDirectory(directory string, previous map[string]fileSnapshot) (map[string]fileSnapshot, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := map[string]fileSnapshot{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		file := fileSnapshot{modTime: info.ModTime(), size: info.Size()}
+		if before, existed := previous[entry.Name()]; existed && before.modTime.Equal(file.modTime) && before.size == file.size {
+			file.hash = before.hash
+		} else if content, err := os.ReadFile(filepath.Join(directory, entry.Name())); err == nil {
+			file.hash = types.HashByteSlice(content)
+		}
+		snapshot[entry.Name()] = file
+	}
+	return snapshot, nil
+}
+
+// fallbackSource watches directories with file system events, and polls the directories
+// in polled source roots or that cannot be watched with events.
+type fallbackSource struct {
+	notify     eventSource
+	polling    eventSource
+	shouldPoll func(path string) bool
+
+	mu     sync.Mutex
+	polled map[string]bool
+
+	events chan fsnotify.Event
+	errors chan error
+}
+
+func newFallbackSource(notify, polling eventSource, shouldPoll func(path string) bool) *fallbackSource {
+	f := &fallbackSource{
+		notify:     notify,
+		polling:    polling,
+		shouldPoll: shouldPoll,
+		polled:     map[string]bool{},
+		events:     make(chan fsnotify.Event),
+		errors:     make(chan error),
+	}
+
+	// Both sources are merged into one pair of channels, closed once both are closed
+	var forwarding sync.WaitGroup
+	for _, source := range []eventSource{notify, polling} {
+		forwarding.Add(2)
+		go func() {
+			defer forwarding.Done()
+			for event := range source.Events() {
+				f.events <- event
+			}
+		}()
+		go func() {
+			defer forwarding.Done()
+			for err := range source.Errors() {
+				f.errors <- err
+			}
+		}()
+	}
+	go func() {
+		forwarding.Wait()
+		close(f.events)
+		close(f.errors)
+	}()
+
+	return f
+}
+
+func (f *fallbackSource) Events() <-chan fsnotify.Event { return f.events }
+func (f *fallbackSource) Errors() <-chan error          { return f.errors }
+
+func (f *fallbackSource) Add(path string) error {
+	if !f.shouldPoll(path) {
+		err := f.notify.Add(path)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("File system events are not available for directory, polling it instead", "directory", path, "error", err)
+	}
+
+	if err := f.polling.Add(path); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.polled[path] = true
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fallbackSource) Remove(path string) error {
+	f.mu.Lock()
+	polled := f.polled[path]
+	delete(f.polled, path)
+	f.mu.Unlock()
+
+	if polled {
+		return f.polling.Remove(path)
+	}
+	return f.notify.Remove(path)
+}
+
+func (f *fallbackSource) WatchList() []string {
+	return append(f.notify.WatchList(), f.polling.WatchList()...)
+}
+
+func (f *fallbackSource) Close() error {
+	err := f.notify.Close()
+	if pollErr := f.polling.Close(); err == nil {
+		err = pollErr
+	}
+	return err
+}
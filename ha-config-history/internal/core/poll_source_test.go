@@ -0,0 +1,119 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestPollingSourceReportsChanges(t *testing.T) {
+	dir := t.TempDir()
+	configuration := filepath.Join(dir, "configuration.yaml")
+	scripts := filepath.Join(dir, "scripts.yaml")
+	writeTestFile(t, configuration, "homeassistant:\n")
+	writeTestFile(t, scripts, "porch:\n")
+
+	source := newPollingSource(func() time.Duration { return time.Hour })
+	defer source.Close()
+	if err := source.Add(dir); err != nil {
+		t.Fatalf("Failed to poll directory: %v", err)
+	}
+
+	if events := source.poll(); len(events) != 0 {
+		t.Errorf("Expected no events for existing files, got %v", events)
+	}
+
+	writeTestFile(t, configuration, "homeassistant:\n  name: Home\n")
+	if err := os.Remove(scripts); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	automations := filepath.Join(dir, "automations.yaml")
+	writeTestFile(t, automations, "[]\n")
+
+	expected := []fsnotify.Event{
+		{Name: automations, Op: fsnotify.Create},
+		{Name: configuration, Op: fsnotify.Write},
+		{Name: scripts, Op: fsnotify.Remove},
+	}
+	if events := source.poll(); !slices.Equal(events, expected) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+
+	// A file that is touched without changing its content is not reported
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(configuration, later, later); err != nil {
+		t.Fatalf("Failed to touch file: %v", err)
+	}
+	if events := source.poll(); len(events) != 0 {
+		t.Errorf("Expected no events for unchanged content, got %v", events)
+	}
+}
+
+func TestPollingSourceDeliversEvents(t *testing.T) {
+	dir := t.TempDir()
+	source := newPollingSource(func() time.Duration { return 10 * time.Millisecond })
+	if err := source.Add(dir); err != nil {
+		t.Fatalf("Failed to poll directory: %v", err)
+	}
+
+	configuration := filepath.Join(dir, "configuration.yaml")
+	writeTestFile(t, configuration, "homeassistant:\n")
+
+	select {
+	case event := <-source.Events():
+		if event.Name != configuration || event.Op != fsnotify.Create {
+			t.Errorf("Expected the new file to be reported, got %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an event from polling")
+	}
+
+	source.Close()
+	for range source.Events() {
+	}
+}
+
+// failingSource is an eventSource that cannot watch any directory.
+type failingSource struct {
+	*fakeEventSource
+}
+
+func (f failingSource) Add(path string) error {
+	return errors.New("no space left on device")
+}
+
+func TestFallbackSourcePollsWhenEventsAreUnavailable(t *testing.T) {
+	polled := t.TempDir()
+	unsupported := t.TempDir()
+	supported := t.TempDir()
+
+	notify := newFakeEventSource()
+	polling := newPollingSource(func() time.Duration { return time.Hour })
+	source := newFallbackSource(notify, polling, func(path string) bool { return path == polled })
+
+	for _, dir := range []string{polled, supported} {
+		if err := source.Add(dir); err != nil {
+			t.Fatalf("Failed to watch %s: %v", dir, err)
+		}
+	}
+	if !slices.Equal(polling.WatchList(), []string{polled}) || !slices.Equal(notify.WatchList(), []string{supported}) {
+		t.Errorf("Expected only the polled root to be polled, got %v and %v", polling.WatchList(), notify.WatchList())
+	}
+
+	failing := newFallbackSource(failingSource{newFakeEventSource()}, polling, func(string) bool { return false })
+	if err := failing.Add(unsupported); err != nil {
+		t.Fatalf("Expected the directory to be polled instead, got %v", err)
+	}
+	if !slices.Contains(polling.WatchList(), unsupported) {
+		t.Error("Expected a directory that cannot be watched to be polled")
+	}
+
+	if err := source.Remove(polled); err != nil || slices.Contains(polling.WatchList(), polled) {
+		t.Errorf("Expected the polled directory to be removed, got %v", err)
+	}
+}
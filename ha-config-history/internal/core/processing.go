@@ -6,24 +6,33 @@ import (
 	"ha-config-history/internal/types"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 )
 
-func (s *Server) ProcessAllConfigOptions() {
-	for _, group := range s.AppSettings.ConfigGroups {
-		for _, options := range group.Configs {
-			s.processConfigOptions(group.Slug, options)
-		}
-	}
+// processConfigOptions reads a config's file and queues backup jobs for its entries as
+// part of run, which may be nil.
+func (s *Server) processConfigOptions(groupSlug types.GroupSlug, options *types.ConfigBackupOptions, run *Run) error {
+	s.settingsMu.RLock()
+	settings := s.AppSettings
+	s.settingsMu.RUnlock()
 
-}
+	rootDir, err := configRootDir(settings, options)
+	if err != nil {
+		slog.Error("Skipping config that cannot be read from its source root", "path", options.Path, "error", err)
+		return err
+	}
 
-func (s *Server) processConfigOptions(groupSlug types.GroupSlug, options *types.ConfigBackupOptions) {
 	if options.BackupType == "multiple" {
-		current, err := io.ReadMultipleConfigsFromSingleFile(s.AppSettings.HomeAssistantConfigDir, options)
+		current, err := io.ReadMultipleConfigsFromSingleFile(rootDir, options)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Debug("Multiple config file not found", "path", options.Path)
+			s.queueRemovedEntries(settings, groupSlug, options, nil, run)
+			return nil
+		}
 		if err != nil {
 			slog.Error("Error reading single file for multiple configs", "error", err)
-			return
+			return err
 		}
 
 		slog.Info("Processing backups for multiple configs",
@@ -31,16 +40,22 @@ func (s *Server) processConfigOptions(groupSlug types.GroupSlug, options *types.
 			"known_backups", len(s.State.CachedBackupSummaries),
 		)
 
+		s.queueRemovedEntries(settings, groupSlug, options, current, run)
 		for _, configBackup := range current {
-			s.queueAndWatch(groupSlug, options, configBackup)
+			s.queueAndWatch(settings, groupSlug, options, configBackup, run)
 		}
 	}
 
 	if options.BackupType == "single" {
-		configBackup, err := io.ReadSingleConfigFromSingleFile(s.AppSettings.HomeAssistantConfigDir, options)
+		configBackup, err := io.ReadSingleConfigFromSingleFile(rootDir, options)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Debug("Single config file not found", "path", options.Path)
+			s.queueDeletionIfTracked(groupSlug, options, types.ConfigBackupIdentifier{Path: options.Path, ID: options.Path}, run)
+			return nil
+		}
 		if err != nil {
 			slog.Error("Error reading single config file", "error", err)
-			return
+			return err
 		}
 
 		slog.Info("Processing backup for single config",
@@ -48,14 +63,21 @@ func (s *Server) processConfigOptions(groupSlug types.GroupSlug, options *types.
 			"friendlyName", configBackup.FriendlyName,
 		)
 
-		s.queueAndWatch(groupSlug, options, configBackup)
+		s.queueAndWatch(settings, groupSlug, options, configBackup, run)
 	}
 
 	if options.BackupType == "directory" {
-		current, err := io.ReadMultipleConfigsFromDirectory(s.AppSettings.HomeAssistantConfigDir, options)
+		current, err := io.ReadMultipleConfigsFromDirectory(rootDir, options)
 		if err != nil {
 			slog.Error("Error reading configs from directory", "error", err)
-			return
+			return err
+		}
+
+		// The folder is watched even when it is empty so new files are picked up
+		if settings.Watches(groupSlug, options) {
+			if err := s.watchDirectory(filepath.Join(rootDir, options.Path)); err != nil {
+				slog.Error("Error watching directory for new files", "path", options.Path, "error", err)
+			}
 		}
 
 		slog.Info("Processing backups for directory configs",
@@ -63,20 +85,22 @@ func (s *Server) processConfigOptions(groupSlug types.GroupSlug, options *types.
 			"known_backups", len(s.State.CachedBackupSummaries),
 		)
 
+		s.queueRemovedEntries(settings, groupSlug, options, current, run)
 		for _, configBackup := range current {
-			s.queueAndWatch(groupSlug, options, configBackup)
+			s.queueAndWatch(settings, groupSlug, options, configBackup, run)
 		}
 	}
 
 	if options.BackupType == "keyed" {
-		current, err := io.ReadKeyedConfigsFromSingleFile(s.AppSettings.HomeAssistantConfigDir, options)
+		current, err := io.ReadKeyedConfigsFromSingleFile(rootDir, options)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Debug("Keyed config file not found", "path", options.Path)
+			s.queueRemovedEntries(settings, groupSlug, options, nil, run)
+			return nil
+		}
 		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				slog.Debug("Keyed config file not found, skipping", "path", options.Path)
-				return
-			}
 			slog.Error("Error reading single file for keyed configs", "error", err)
-			return
+			return err
 		}
 
 		slog.Info("Processing backups for keyed configs",
@@ -84,76 +108,243 @@ func (s *Server) processConfigOptions(groupSlug types.GroupSlug, options *types.
 			"known_backups", len(s.State.CachedBackupSummaries),
 		)
 
+		s.queueRemovedEntries(settings, groupSlug, options, current, run)
+		for _, configBackup := range current {
+			s.queueAndWatch(settings, groupSlug, options, configBackup, run)
+		}
+	}
+
+	if options.BackupType == "multidocument" {
+		current, err := io.ReadMultipleDocumentsFromSingleFile(rootDir, options)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Debug("Multi-document config file not found", "path", options.Path)
+			s.queueRemovedEntries(settings, groupSlug, options, nil, run)
+			return nil
+		}
+		if err != nil {
+			slog.Error("Error reading single file for multi-document configs", "error", err)
+			return err
+		}
+
+		slog.Info("Processing backups for multi-document configs",
+			"found_active_configs", len(current),
+			"known_backups", len(s.State.CachedBackupSummaries),
+		)
+
+		s.queueRemovedEntries(settings, groupSlug, options, current, run)
 		for _, configBackup := range current {
-			s.queueAndWatch(groupSlug, options, configBackup)
+			s.queueAndWatch(settings, groupSlug, options, configBackup, run)
 		}
 	}
+
+	if options.BackupType == "blueprint" {
+		current, err := io.ReadBlueprintsFromDirectory(rootDir, options)
+		if err != nil {
+			slog.Error("Error reading blueprints from directory", "error", err)
+			return err
+		}
+
+		slog.Info("Processing backups for blueprints",
+			"found_active_configs", len(current),
+			"known_backups", len(s.State.CachedBackupSummaries),
+		)
+
+		s.queueRemovedEntries(settings, groupSlug, options, current, run)
+		for _, configBackup := range current {
+			s.queueAndWatch(settings, groupSlug, options, configBackup, run)
+		}
+	}
+
+	if options.BackupType == "nodered" {
+		current, err := io.ReadNodeREDFlowsFromSingleFile(rootDir, options)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Debug("Node-RED flows file not found", "path", options.Path)
+			s.queueRemovedEntries(settings, groupSlug, options, nil, run)
+			return nil
+		}
+		if err != nil {
+			slog.Error("Error reading Node-RED flows file", "error", err)
+			return err
+		}
+
+		slog.Info("Processing backups for Node-RED flows",
+			"found_active_configs", len(current),
+			"known_backups", len(s.State.CachedBackupSummaries),
+		)
+
+		s.queueRemovedEntries(settings, groupSlug, options, current, run)
+		for _, configBackup := range current {
+			s.queueAndWatch(settings, groupSlug, options, configBackup, run)
+		}
+	}
+
+	return nil
+}
+
+// configRootDir returns the directory a config's path is relative to, checking that the
+// path stays within it.
+func configRootDir(settings *types.AppSettings, options *types.ConfigBackupOptions) (string, error) {
+	rootDir, err := settings.RootDir(options)
+	if err != nil {
+		return "", err
+	}
+	if _, err := types.SandboxedPath(rootDir, options.Path); err != nil {
+		return "", err
+	}
+	return rootDir, nil
 }
 
-func (s *Server) queueAndWatch(groupSlug types.GroupSlug, options *types.ConfigBackupOptions, configBackup *types.ConfigBackup) {
-	s.queue <- NewBackupJob(groupSlug, options, configBackup)
+// queueAndWatch queues a backup and watches its file, unless the config is only backed up
+// on a schedule.
+func (s *Server) queueAndWatch(settings *types.AppSettings, groupSlug types.GroupSlug, options *types.ConfigBackupOptions, configBackup *types.ConfigBackup, run *Run) {
+	s.enqueue(NewBackupJob(groupSlug, options, configBackup), run)
+	if !settings.Watches(groupSlug, options) {
+		return
+	}
 	err := s.watchDirectoryForFile(groupSlug, configBackup.FilePath, options)
 	if err != nil {
 		slog.Error("Error watching file for changes", "error", err)
 	}
 }
 
-func (s *Server) startQueueProcessor() {
-	go func() {
-		for job := range s.queue {
-			start := time.Now()
-			s.processingFile = true
-			s.handleUpdateToFile(job.GroupSlug, job.Options, job.Backup)
-			s.processingFile = false
-			slog.Debug("Processed backup job",
-				"id", job.Backup.ID,
-				"path", job.Backup.Path,
-				"group", job.GroupSlug,
-				"duration", time.Since(start),
-			)
-		}
-		slog.Info("Queue processor stopped")
-	}()
+// processJob carries out a backup job. Jobs for the same config are never processed at
+// the same time.
+func (s *Server) processJob(job backupJob) {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+
+	// Jobs queued before a settings change use the config's current options, and are
+	// skipped when it is no longer tracked
+	options := s.trackedOptions(job.GroupSlug, job.Options.Path)
+	if options == nil {
+		slog.Debug("Skipping job for config that is no longer tracked", "group", job.GroupSlug, "path", job.Options.Path)
+		s.completeJournaled(job)
+		return
+	}
+	job.Options = options
+
+	start := time.Now()
+	var result jobResult
+	switch job.Action {
+	case jobActionSave:
+		result = s.handleUpdateToFile(job.GroupSlug, job.Options, job.Backup)
+	case jobActionRename:
+		result.err = s.handleRename(job.GroupSlug, job.Backup.ConfigBackupIdentifier, job.RenamedTo)
+	case jobActionDelete:
+		result.err = s.handleDeletion(job.GroupSlug, job.Backup.ConfigBackupIdentifier)
+	case jobActionClearDeletion:
+		result.err = s.clearDeletion(job.GroupSlug, job.Backup.ConfigBackupIdentifier)
+	}
+	if job.run != nil {
+		job.run.recordJob(job, result)
+	}
+	// A job that failed, such as when the disk is full, stays in the journal and is
+	// replayed on the next start. Jobs dropped by a closed queue never get here, so they
+	// stay in it too.
+	if result.err == nil {
+		s.completeJournaled(job)
+	}
+	slog.Debug("Processed backup job",
+		"id", job.Backup.ID,
+		"path", job.Backup.Path,
+		"group", job.GroupSlug,
+		"duration", time.Since(start),
+	)
 }
 
 func (s *Server) handleUpdateToFile(
 	groupSlug types.GroupSlug,
 	backupOptions *types.ConfigBackupOptions,
-	activeConfigBackup *types.ConfigBackup) {
+	activeConfigBackup *types.ConfigBackup) jobResult {
 
-	if s.needsUpdate(groupSlug, activeConfigBackup) {
-		slog.Info("Config changed, saving backup",
+	if s.reappearedUnchanged(groupSlug, activeConfigBackup) {
+		slog.Info("Deleted config is back, clearing deletion",
 			"friendlyName", activeConfigBackup.FriendlyName,
 			"id", activeConfigBackup.ID,
 		)
+		return jobResult{err: s.clearDeletion(groupSlug, activeConfigBackup.ConfigBackupIdentifier)}
+	}
 
-		err := io.SaveConfigBackup(s.AppSettings.BackupDir, groupSlug, activeConfigBackup)
-		if err != nil {
-			slog.Error("Error saving config backup",
-				"id", activeConfigBackup.ID,
-				"error", err,
-			)
-		}
+	if !s.needsUpdate(groupSlug, activeConfigBackup) {
+		return jobResult{}
+	}
 
-		updatedMetadata, err := io.CleanupAndUpdateMetadata(
-			groupSlug,
-			activeConfigBackup,
-			backupOptions,
-			s.AppSettings.BackupDir,
-			s.AppSettings.DefaultMaxBackups,
-			s.AppSettings.DefaultMaxBackupAgeDays)
+	if len(activeConfigBackup.PreviousIds) == 0 {
+		activeConfigBackup.PreviousIds = s.cachedPreviousIds(groupSlug, activeConfigBackup.ConfigBackupIdentifier)
+	}
 
-		if err != nil {
-			slog.Error("Error updating config metadata",
-				"id", activeConfigBackup.ID,
-				"error", err,
-			)
-		}
+	slog.Info("Config changed, saving backup",
+		"friendlyName", activeConfigBackup.FriendlyName,
+		"id", activeConfigBackup.ID,
+	)
 
-		if updatedMetadata != nil {
-			s.updateCachedMetadata(groupSlug, updatedMetadata)
-		}
+	storedConfigBackup := activeConfigBackup
+	if s.AppSettings.Redaction.StoresRedacted() {
+		redacted := *activeConfigBackup
+		redacted.Blob = s.AppSettings.Redaction.Redact(activeConfigBackup.Blob)
+		storedConfigBackup = &redacted
+	}
+
+	err := io.SaveConfigBackup(s.AppSettings.BackupDir, groupSlug, storedConfigBackup)
+	if err != nil {
+		slog.Error("Error saving config backup",
+			"id", activeConfigBackup.ID,
+			"error", err,
+		)
+		return jobResult{err: err}
+	}
+
+	updatedMetadata, removed, err := io.CleanupAndUpdateMetadata(
+		groupSlug,
+		activeConfigBackup,
+		backupOptions,
+		s.AppSettings.BackupDir,
+		s.AppSettings.DefaultMaxBackups,
+		s.AppSettings.DefaultMaxBackupAgeDays)
+
+	if err != nil {
+		slog.Error("Error updating config metadata",
+			"id", activeConfigBackup.ID,
+			"error", err,
+		)
+	}
+
+	if updatedMetadata != nil {
+		s.updateCachedMetadata(groupSlug, updatedMetadata)
+	}
+
+	s.events.Publish(EventVersionSaved, VersionEvent{
+		Group:    groupSlug,
+		Path:     activeConfigBackup.Path,
+		ID:       activeConfigBackup.ID,
+		Filename: io.BackupFilename(activeConfigBackup.ModifiedDate),
+	})
+	s.scheduleAttribution(groupSlug, activeConfigBackup)
+	if removed > 0 {
+		s.events.Publish(EventVersionsRemoved, VersionsRemovedEvent{
+			Group:   groupSlug,
+			Path:    activeConfigBackup.Path,
+			ID:      activeConfigBackup.ID,
+			Removed: removed,
+		})
+	}
+
+	return jobResult{saved: true, removed: removed, err: err}
+}
+
+func (s *Server) handleRename(groupSlug types.GroupSlug, identifier types.ConfigBackupIdentifier, renamedTo string) error {
+	updatedMetadata, err := io.MarkConfigRenamed(s.AppSettings.BackupDir, groupSlug, identifier.Path, identifier.ID, renamedTo)
+	if err != nil {
+		slog.Error("Error marking config as renamed",
+			"id", identifier.ID,
+			"renamedTo", renamedTo,
+			"error", err,
+		)
+		return err
 	}
+
+	s.updateCachedMetadata(groupSlug, updatedMetadata)
+	return nil
 }
 
 func (s *Server) needsUpdate(groupSlug types.GroupSlug, activeConfigBackup *types.ConfigBackup) bool {
@@ -165,8 +356,21 @@ func (s *Server) needsUpdate(groupSlug types.GroupSlug, activeConfigBackup *type
 		return true
 	}
 
+	// A renamed config that reappears under its old id starts being tracked again
 	metadata, exists := groupMetadata[activeConfigBackup.ConfigBackupIdentifier]
-	return !exists || activeConfigBackup.Hash != metadata.LastHash
+	return !exists || activeConfigBackup.Hash != metadata.LastHash || metadata.RenamedTo != ""
+}
+
+// cachedPreviousIds returns the ids a config was previously known as, so they are kept
+// when its metadata is rewritten.
+func (s *Server) cachedPreviousIds(groupSlug types.GroupSlug, identifier types.ConfigBackupIdentifier) []string {
+	s.State.Mu.RLock()
+	defer s.State.Mu.RUnlock()
+
+	if metadata, exists := s.State.CachedBackupSummaries[groupSlug][identifier]; exists {
+		return metadata.PreviousIds
+	}
+	return nil
 }
 
 func (s *Server) updateCachedMetadata(groupSlug types.GroupSlug, metadata *types.BackupConfigSummary) {
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueueMetrics describes the backup job queue, including how often producers had to wait
+// for space in it.
+type QueueMetrics struct {
+	Workers    int    `json:"workers"`
+	Capacity   int    `json:"capacity"`
+	Queued     int    `json:"queued"`
+	MaxQueued  int64  `json:"maxQueued"`
+	InProgress int64  `json:"inProgress"`
+	Enqueued   uint64 `json:"enqueued"`
+	Completed  uint64 `json:"completed"`
+	Dropped    uint64 `json:"dropped"`
+	// Blocked is the number of jobs that had to wait for space in the queue, and
+	// BlockedMs the total time they waited
+	Blocked   uint64 `json:"blocked"`
+	BlockedMs int64  `json:"blockedMs"`
+}
+
+// jobQueue is a bounded queue of backup jobs processed by a pool of workers. Jobs for the
+// same config always go to the same worker, so they are processed one at a time and in
+// the order they were queued.
+type jobQueue struct {
+	mu      sync.RWMutex
+	closed  bool
+	workers []chan backupJob
+	handle  func(backupJob)
+
+	pending sync.WaitGroup
+	running sync.WaitGroup
+
+	queued     atomic.Int64
+	maxQueued  atomic.Int64
+	inProgress atomic.Int64
+	enqueued   atomic.Uint64
+	completed  atomic.Uint64
+	dropped    atomic.Uint64
+	blocked    atomic.Uint64
+	blockedNs  atomic.Int64
+}
+
+// newJobQueue starts workers that call handle for each job. size is the total number of
+// jobs that can wait, shared between the workers.
+func newJobQueue(workers, size int, handle func(backupJob)) *jobQueue {
+	workers = max(workers, 1)
+	perWorker := max((size+workers-1)/workers, 1)
+
+	q := &jobQueue{handle: handle}
+	for range workers {
+		jobs := make(chan backupJob, perWorker)
+		q.workers = append(q.workers, jobs)
+
+		q.running.Add(1)
+		go q.work(jobs)
+	}
+	return q
+}
+
+func (q *jobQueue) work(jobs chan backupJob) {
+	defer q.running.Done()
+
+	for job := range jobs {
+		q.queued.Add(-1)
+		q.inProgress.Add(1)
+		q.handle(job)
+		q.inProgress.Add(-1)
+		q.completed.Add(1)
+
+		if job.done != nil {
+			job.done()
+		}
+		q.pending.Done()
+	}
+}
+
+// Enqueue adds a job to the queue, waiting for space when the job's worker is full. Jobs
+// queued after Close are dropped.
+func (q *jobQueue) Enqueue(job backupJob) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		q.dropped.Add(1)
+		slog.Warn("Backup queue is closed, dropping job", "id", job.Backup.ID, "path", job.Backup.Path)
+		if job.done != nil {
+			job.done()
+		}
+		return
+	}
+
+	q.pending.Add(1)
+	q.enqueued.Add(1)
+	queued := q.queued.Add(1)
+	for maxQueued := q.maxQueued.Load(); queued > maxQueued; maxQueued = q.maxQueued.Load() {
+		if q.maxQueued.CompareAndSwap(maxQueued, queued) {
+			break
+		}
+	}
+
+	jobs := q.workers[q.workerFor(job)]
+	select {
+	case jobs <- job:
+	default:
+		start := time.Now()
+		jobs <- job
+		q.blocked.Add(1)
+		q.blockedNs.Add(int64(time.Since(start)))
+	}
+}
+
+// workerFor picks the worker for a job from its config identity.
+func (q *jobQueue) workerFor(job backupJob) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(job.GroupSlug))
+	hash.Write([]byte{0})
+	hash.Write([]byte(job.Backup.Path))
+	hash.Write([]byte{0})
+	hash.Write([]byte(job.Backup.ID))
+	return int(hash.Sum32() % uint32(len(q.workers)))
+}
+
+// Wait blocks until every job queued so far has been processed.
+func (q *jobQueue) Wait() {
+	q.pending.Wait()
+}
+
+// Close stops accepting jobs and waits for the workers to finish the queued ones.
+func (q *jobQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	for _, jobs := range q.workers {
+		close(jobs)
+	}
+	q.mu.Unlock()
+
+	q.running.Wait()
+}
+
+// Metrics returns a snapshot of the queue's state and counters.
+func (q *jobQueue) Metrics() QueueMetrics {
+	capacity := 0
+	for _, jobs := range q.workers {
+		capacity += cap(jobs)
+	}
+
+	return QueueMetrics{
+		Workers:    len(q.workers),
+		Capacity:   capacity,
+		Queued:     int(max(q.queued.Load(), 0)),
+		MaxQueued:  q.maxQueued.Load(),
+		InProgress: q.inProgress.Load(),
+		Enqueued:   q.enqueued.Load(),
+		Completed:  q.completed.Load(),
+		Dropped:    q.dropped.Load(),
+		Blocked:    q.blocked.Load(),
+		BlockedMs:  time.Duration(q.blockedNs.Load()).Milliseconds(),
+	}
+}
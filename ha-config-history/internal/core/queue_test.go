@@ -0,0 +1,126 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"ha-config-history/internal/types"
+)
+
+func testJob(id string, sequence int) backupJob {
+	return NewBackupJob("test", nil, &types.ConfigBackup{
+		ConfigBackupIdentifier: types.ConfigBackupIdentifier{Path: "automations.yaml", ID: id},
+		FriendlyName:           fmt.Sprint(sequence),
+	})
+}
+
+func TestJobQueueSerializesJobsPerConfig(t *testing.T) {
+	var mu sync.Mutex
+	running := map[string]bool{}
+	processed := map[string][]string{}
+	overlapped := false
+
+	queue := newJobQueue(4, 16, func(job backupJob) {
+		mu.Lock()
+		if running[job.Backup.ID] {
+			overlapped = true
+		}
+		running[job.Backup.ID] = true
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running[job.Backup.ID] = false
+		processed[job.Backup.ID] = append(processed[job.Backup.ID], job.Backup.FriendlyName)
+		mu.Unlock()
+	})
+	defer queue.Close()
+
+	ids := []string{"porch", "hall", "garage"}
+	for sequence := range 20 {
+		for _, id := range ids {
+			queue.Enqueue(testJob(id, sequence))
+		}
+	}
+	queue.Wait()
+
+	if overlapped {
+		t.Error("Expected jobs for the same config to never run at the same time")
+	}
+	for _, id := range ids {
+		if len(processed[id]) != 20 {
+			t.Fatalf("Expected 20 jobs for %s, got %d", id, len(processed[id]))
+		}
+		for sequence, processedSequence := range processed[id] {
+			if processedSequence != fmt.Sprint(sequence) {
+				t.Fatalf("Expected jobs for %s in order, got %v", id, processed[id])
+			}
+		}
+	}
+
+	if metrics := queue.Metrics(); metrics.Enqueued != 60 || metrics.Completed != 60 || metrics.Queued != 0 {
+		t.Errorf("Unexpected metrics %+v", metrics)
+	}
+}
+
+func TestJobQueueAppliesBackPressure(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	queue := newJobQueue(1, 1, func(job backupJob) {
+		started <- struct{}{}
+		<-release
+	})
+	defer queue.Close()
+
+	// The first job is taken by the worker and the second fills the queue
+	queue.Enqueue(testJob("porch", 0))
+	<-started
+	queue.Enqueue(testJob("porch", 1))
+
+	enqueued := make(chan struct{})
+	go func() {
+		queue.Enqueue(testJob("porch", 2))
+		close(enqueued)
+	}()
+
+	select {
+	case <-enqueued:
+		t.Fatal("Expected the third job to wait for space in the queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-enqueued
+	queue.Wait()
+
+	metrics := queue.Metrics()
+	if metrics.Blocked != 1 || metrics.BlockedMs < 40 {
+		t.Errorf("Expected one blocked job that waited, got %+v", metrics)
+	}
+	if metrics.Capacity != 1 || metrics.Workers != 1 || metrics.Completed != 3 || metrics.MaxQueued < 2 {
+		t.Errorf("Unexpected metrics %+v", metrics)
+	}
+}
+
+func TestJobQueueClose(t *testing.T) {
+	processed := 0
+	queue := newJobQueue(2, 8, func(job backupJob) { processed++ })
+
+	queue.Enqueue(testJob("porch", 0))
+	queue.Close()
+
+	done := false
+	job := testJob("porch", 1)
+	job.done = func() { done = true }
+	queue.Enqueue(job)
+
+	if processed != 1 {
+		t.Errorf("Expected queued jobs to be processed before Close returns, got %d", processed)
+	}
+	if !done || queue.Metrics().Dropped != 1 {
+		t.Errorf("Expected the job queued after Close to be dropped")
+	}
+}
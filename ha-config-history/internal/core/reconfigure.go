@@ -0,0 +1,137 @@
+package core
+
+import (
+	"ha-config-history/internal/types"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"slices"
+)
+
+// configKey identifies a config across settings changes, which replace every options value.
+type configKey struct {
+	GroupSlug types.GroupSlug
+	Path      string
+}
+
+// trackedConfigs returns every config in the settings with its group.
+func trackedConfigs(settings *types.AppSettings) []GroupedConfigBackupOptions {
+	configs := []GroupedConfigBackupOptions{}
+	for _, group := range settings.ConfigGroups {
+		for _, options := range group.Configs {
+			configs = append(configs, GroupedConfigBackupOptions{GroupSlug: group.Slug, Options: options})
+		}
+	}
+	return configs
+}
+
+// trackedOptions returns the current options of a config, or nil when it is no longer
+// tracked. The caller must hold settingsMu.
+func (s *Server) trackedOptions(groupSlug types.GroupSlug, path string) *types.ConfigBackupOptions {
+	for _, config := range trackedConfigs(s.AppSettings) {
+		if config.GroupSlug == groupSlug && config.Options.Path == path {
+			return config.Options
+		}
+	}
+	return nil
+}
+
+// ApplySettings replaces the settings and reconciles the file watcher with them. Configs
+// that were removed stop being watched and their queued jobs are skipped, and configs that
+// were added, or whose files are now read differently, are scanned by the returned run.
+// The run is nil when there is nothing new to scan.
+func (s *Server) ApplySettings(newSettings *types.AppSettings) *Run {
+	s.settingsMu.Lock()
+	oldSettings := s.AppSettings
+	s.AppSettings = newSettings
+	rescan := s.reconcileWatches(oldSettings, newSettings)
+	s.settingsMu.Unlock()
+
+	if !slices.Equal(oldSettings.Schedules(), newSettings.Schedules()) {
+		if err := s.RestartCronJob(); err == nil {
+			slog.Info("Cron schedules updated", "schedules", newSettings.Schedules())
+		}
+	}
+
+	s.events.Publish(EventSettingsUpdated, struct{}{})
+
+	if len(rescan) == 0 {
+		return nil
+	}
+	slog.Info("Scanning configs added by settings change", "configs", len(rescan))
+	return s.startRun(RunTriggerSettings, rescan)
+}
+
+// reconcileWatches rebuilds the watched file lookup for the new settings and stops
+// watching directories that no config needs any more. It returns the configs that must be
+// scanned to be watched again. The caller must hold settingsMu.
+func (s *Server) reconcileWatches(oldSettings, newSettings *types.AppSettings) []GroupedConfigBackupOptions {
+	previous := map[configKey]*types.ConfigBackupOptions{}
+	for _, config := range trackedConfigs(oldSettings) {
+		previous[configKey{config.GroupSlug, config.Options.Path}] = config.Options
+	}
+
+	// Configs that are read the same way keep their watches
+	unchanged := map[configKey]*types.ConfigBackupOptions{}
+	rescan := []GroupedConfigBackupOptions{}
+	for _, config := range trackedConfigs(newSettings) {
+		key := configKey{config.GroupSlug, config.Options.Path}
+		if options, exists := previous[key]; exists && readTheSame(oldSettings, newSettings, config.GroupSlug, options, config.Options) {
+			unchanged[key] = config.Options
+			continue
+		}
+		rescan = append(rescan, config)
+	}
+
+	s.State.Mu.Lock()
+	lookup := make(WatchedFileLookup)
+	for filePath, entries := range s.State.FileLookup {
+		for _, entry := range entries {
+			if options, exists := unchanged[configKey{entry.GroupSlug, entry.Options.Path}]; exists {
+				lookup.AddOrUpdate(filePath, entry.GroupSlug, options)
+			}
+		}
+	}
+	s.State.FileLookup = lookup
+	s.State.Mu.Unlock()
+
+	needed := map[string]bool{}
+	for filePath := range lookup {
+		needed[filepath.Dir(filePath)] = true
+	}
+	for key, options := range unchanged {
+		if !newSettings.Watches(key.GroupSlug, options) {
+			continue
+		}
+		if rootDir, err := newSettings.RootDir(options); err == nil && options.BackupType == "directory" {
+			needed[filepath.Join(rootDir, options.Path)] = true
+		}
+	}
+	for _, directory := range s.fileWatcher.WatchList() {
+		if needed[directory] {
+			continue
+		}
+		slog.Info("Removing directory from watcher", "directory", directory)
+		if err := s.fileWatcher.Remove(directory); err != nil {
+			slog.Error("Error removing directory watcher", "directory", directory, "error", err)
+		}
+	}
+
+	return rescan
+}
+
+// readTheSame reports whether a config finds the same files under both settings and is
+// watched the same way, so its watches are still correct. Its schedule does not matter.
+func readTheSame(oldSettings, newSettings *types.AppSettings, groupSlug types.GroupSlug, previous, current *types.ConfigBackupOptions) bool {
+	previousOptions, currentOptions := *previous, *current
+	previousOptions.CronSchedule, currentOptions.CronSchedule = nil, nil
+	if !reflect.DeepEqual(previousOptions, currentOptions) {
+		return false
+	}
+	if oldSettings.Watches(groupSlug, previous) != newSettings.Watches(groupSlug, current) {
+		return false
+	}
+	oldRoot, oldExists := oldSettings.SourceRoot(previous.Root)
+	newRoot, newExists := newSettings.SourceRoot(current.Root)
+	return oldExists && newExists && oldRoot.Path == newRoot.Path && oldRoot.Poll == newRoot.Poll
+}
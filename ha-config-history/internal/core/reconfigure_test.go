@@ -0,0 +1,78 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"ha-config-history/internal/types"
+)
+
+func TestApplySettingsReconcilesWatches(t *testing.T) {
+	lights := types.NewSingleConfigBackupOptions("packages/lights.yaml")
+	queued, server, source, haConfigDir := newWatcherTestServer(t, types.NewSingleConfigBackupOptions("configuration.yaml"), lights)
+
+	packagesDir := filepath.Join(haConfigDir, "packages")
+	if err := os.MkdirAll(packagesDir, 0755); err != nil {
+		t.Fatalf("Failed to create packages directory: %v", err)
+	}
+	writeTestFile(t, filepath.Join(haConfigDir, "configuration.yaml"), "homeassistant:\n")
+	writeTestFile(t, filepath.Join(haConfigDir, "scripts.yaml"), "porch:\n  alias: Porch\n")
+	writeTestFile(t, filepath.Join(packagesDir, "lights.yaml"), "light:\n")
+
+	configuration := types.NewSingleConfigBackupOptions("configuration.yaml")
+	newSettings := *server.AppSettings
+	newSettings.ConfigGroups = []*types.ConfigBackupOptionGroup{
+		types.NewConfigBackupOptionGroup("Test", []*types.ConfigBackupOptions{
+			configuration,
+			types.NewSingleConfigBackupOptions("scripts.yaml"),
+		}),
+	}
+
+	run := server.ApplySettings(&newSettings)
+	if run == nil {
+		t.Fatal("Expected a run to scan the added config")
+	}
+	run.Wait()
+
+	jobs := collectJobs(queued)
+	if len(jobs) != 1 || jobs[0].Backup.Path != "scripts.yaml" {
+		t.Fatalf("Expected only the added config to be scanned, got %+v", jobs)
+	}
+
+	server.State.Mu.RLock()
+	lookup := server.State.FileLookup
+	server.State.Mu.RUnlock()
+
+	if entries := lookup[filepath.Join(haConfigDir, "configuration.yaml")]; len(entries) != 1 || entries[0].Options != configuration {
+		t.Errorf("Expected the unchanged config to use its new options, got %+v", entries)
+	}
+	if _, exists := lookup[filepath.Join(haConfigDir, "scripts.yaml")]; !exists {
+		t.Error("Expected the added config to be watched")
+	}
+	if _, exists := lookup[filepath.Join(packagesDir, "lights.yaml")]; exists {
+		t.Error("Expected the removed config to no longer be watched")
+	}
+	if slices.Contains(source.WatchList(), packagesDir) {
+		t.Error("Expected the directory only the removed config needed to be unwatched")
+	}
+
+	// A job for the removed config that was still queued is skipped
+	server.processJob(NewBackupJob("test", lights, &types.ConfigBackup{
+		ConfigBackupIdentifier: types.ConfigBackupIdentifier{Path: "packages/lights.yaml", ID: "packages/lights.yaml"},
+		Blob:                   []byte("light:\n"),
+	}))
+	if _, err := os.Stat(filepath.Join(server.AppSettings.BackupDir, "test")); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup for the removed config, got %v", err)
+	}
+}
+
+func TestApplySettingsWithoutNewConfigs(t *testing.T) {
+	_, server, _, _ := newWatcherTestServer(t, types.NewSingleConfigBackupOptions("configuration.yaml"))
+
+	newSettings := *server.AppSettings
+	if run := server.ApplySettings(&newSettings); run != nil {
+		t.Errorf("Expected no run when no configs were added, got %+v", run.Snapshot())
+	}
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"ha-config-history/internal/homeassistant"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"time"
+)
+
+// ReloadResult reports the Home Assistant reload called after a restore.
+type ReloadResult struct {
+	Service string `json:"service"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReloadService returns the service called after restoring a config, or an empty string
+// when the config does not reload Home Assistant after a restore.
+func ReloadService(configOptions *types.ConfigBackupOptions) string {
+	if configOptions.ReloadAfterRestore == nil || !*configOptions.ReloadAfterRestore {
+		return ""
+	}
+	if configOptions.ReloadService != nil && *configOptions.ReloadService != "" {
+		return *configOptions.ReloadService
+	}
+	return homeassistant.ReloadServiceForPath(configOptions.Path)
+}
+
+// ReloadAfterRestore calls the Home Assistant service that picks up a restored config. It
+// returns nil when the config does not reload after a restore. A failed reload does not
+// undo the restore, so it is reported in the result rather than as an error.
+func (s *Server) ReloadAfterRestore(configOptions *types.ConfigBackupOptions) *ReloadResult {
+	service := ReloadService(configOptions)
+	if service == "" {
+		return nil
+	}
+
+	s.settingsMu.RLock()
+	client := homeassistant.FromSettings(s.AppSettings.HomeAssistant)
+	s.settingsMu.RUnlock()
+
+	result := &ReloadResult{Service: service}
+	if client == nil {
+		result.Error = "Home Assistant API is not configured"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+	if err := client.CallService(ctx, service, nil); err != nil {
+		slog.Warn("Error reloading Home Assistant after restore", "path", configOptions.Path, "service", service, "error", err)
+		result.Error = err.Error()
+		return result
+	}
+
+	slog.Info("Reloaded Home Assistant after restore", "path", configOptions.Path, "service", service)
+	result.Success = true
+	return result
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"slices"
+	"sort"
+)
+
+// renameSimilarityThreshold is how alike a new entry must be to one that disappeared
+// from the same file for it to be treated as a rename.
+const renameSimilarityThreshold = 0.7
+
+type renameCandidate struct {
+	previous *types.BackupConfigSummary
+	current  *types.ConfigBackup
+	score    float64
+}
+
+// queueRenames links entries that appear under a new id to the missing entry they most
+// closely match, and queues the old config to be marked as renamed. It returns the ids of
+// the missing entries that were linked.
+func (s *Server) queueRenames(
+	backupDir string,
+	groupSlug types.GroupSlug,
+	options *types.ConfigBackupOptions,
+	missing []*types.BackupConfigSummary,
+	added []*types.ConfigBackup,
+	run *Run,
+) map[string]bool {
+	linkedPrevious := map[string]bool{}
+	if len(missing) == 0 || len(added) == 0 {
+		return linkedPrevious
+	}
+
+	candidates := []renameCandidate{}
+	for _, previous := range missing {
+		content, err := io.GetLatestConfigBackup(backupDir, groupSlug, previous.Path, previous.ID)
+		if err != nil {
+			slog.Debug("No previous content to compare for rename", "id", previous.ID, "error", err)
+			continue
+		}
+
+		for _, configBackup := range added {
+			score := io.Similarity(content, configBackup.Blob)
+			if score >= renameSimilarityThreshold {
+				candidates = append(candidates, renameCandidate{previous, configBackup, score})
+			}
+		}
+	}
+
+	// Pair the closest matches first so each entry is linked at most once
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	linkedCurrent := map[string]bool{}
+	for _, candidate := range candidates {
+		if linkedPrevious[candidate.previous.ID] || linkedCurrent[candidate.current.ID] {
+			continue
+		}
+		linkedPrevious[candidate.previous.ID] = true
+		linkedCurrent[candidate.current.ID] = true
+
+		slog.Info("Detected renamed config",
+			"path", options.Path,
+			"previousId", candidate.previous.ID,
+			"id", candidate.current.ID,
+			"similarity", candidate.score,
+		)
+
+		candidate.current.PreviousIds = append([]string{candidate.previous.ID}, candidate.previous.PreviousIds...)
+		s.enqueue(NewRenameJob(groupSlug, options, candidate.previous.ConfigBackupIdentifier, candidate.current.ID), run)
+	}
+
+	return linkedPrevious
+}
+
+// missingAndAddedEntries returns the known configs for a file that are no longer in it,
+// and the entries in it that have no history yet. Configs already marked as renamed or
+// deleted are not missing, so a new entry is never linked to one deleted long ago.
+func (s *Server) missingAndAddedEntries(
+	groupSlug types.GroupSlug,
+	options *types.ConfigBackupOptions,
+	current []*types.ConfigBackup,
+) ([]*types.BackupConfigSummary, []*types.ConfigBackup) {
+	s.State.Mu.RLock()
+	defer s.State.Mu.RUnlock()
+
+	groupSummaries := s.State.CachedBackupSummaries[groupSlug]
+
+	currentIds := []string{}
+	added := []*types.ConfigBackup{}
+	for _, configBackup := range current {
+		currentIds = append(currentIds, configBackup.ID)
+		if _, exists := groupSummaries[configBackup.ConfigBackupIdentifier]; !exists {
+			added = append(added, configBackup)
+		}
+	}
+
+	missing := []*types.BackupConfigSummary{}
+	for identifier, summary := range groupSummaries {
+		if identifier.Path != options.Path || summary.RenamedTo != "" || summary.Deleted || slices.Contains(currentIds, identifier.ID) {
+			continue
+		}
+		missing = append(missing, summary)
+	}
+
+	return missing, added
+}
+
+// HistoryIds returns the id of a config followed by the ids it was previously known as,
+// newest first, which together locate all of its backups.
+func (s *Server) HistoryIds(groupSlug types.GroupSlug, identifier types.ConfigBackupIdentifier) []string {
+	return append([]string{identifier.ID}, s.cachedPreviousIds(groupSlug, identifier)...)
+}
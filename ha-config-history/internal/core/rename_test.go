@@ -0,0 +1,68 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ha-config-history/internal/types"
+)
+
+func TestRenamesAreNotLinkedToDeletedConfigs(t *testing.T) {
+	server, haConfigDir := newRunTestServer(t, types.NewMultipleConfigBackupOptions("automations.yaml", "id", "alias"))
+	automations := filepath.Join(haConfigDir, "automations.yaml")
+	porch := "  alias: Porch lights\n  trigger:\n    - platform: sun\n      event: sunset\n  action:\n    - service: light.turn_on\n      target:\n        entity_id: light.porch\n"
+
+	writeTestFile(t, automations, "- id: porch\n"+porch+"- id: kept\n  alias: Kept\n")
+	server.StartRun(RunTriggerManual).Wait()
+
+	// The porch automation is deleted, then a similar one is added much later
+	writeTestFile(t, automations, "- id: kept\n  alias: Kept\n")
+	server.StartRun(RunTriggerManual).Wait()
+	writeTestFile(t, automations, "- id: kept\n  alias: Kept\n- id: porch_again\n"+porch)
+	server.StartRun(RunTriggerManual).Wait()
+
+	server.State.Mu.RLock()
+	defer server.State.Mu.RUnlock()
+	summaries := server.State.CachedBackupSummaries["test"]
+
+	deleted := summaries[types.ConfigBackupIdentifier{Path: "automations.yaml", ID: "porch"}]
+	if deleted == nil || !deleted.Deleted || deleted.RenamedTo != "" {
+		t.Errorf("Expected the deleted config to stay deleted, got %+v", deleted)
+	}
+	added := summaries[types.ConfigBackupIdentifier{Path: "automations.yaml", ID: "porch_again"}]
+	if added == nil || len(added.PreviousIds) != 0 {
+		t.Errorf("Expected the new config to have no previous ids, got %+v", added)
+	}
+}
+
+func TestDeletedFilesMarkTheirEntriesDeleted(t *testing.T) {
+	server, haConfigDir := newRunTestServer(t,
+		types.NewMultipleConfigBackupOptions("automations.yaml", "id", "alias"),
+		types.NewMultiDocumentConfigBackupOptions("documents.yaml", nil, nil),
+	)
+	automations := filepath.Join(haConfigDir, "automations.yaml")
+	documents := filepath.Join(haConfigDir, "documents.yaml")
+
+	writeTestFile(t, automations, "- id: porch\n  alias: Porch\n- id: kitchen\n  alias: Kitchen\n")
+	writeTestFile(t, documents, "name: one\n---\nname: two\n")
+	server.StartRun(RunTriggerManual).Wait()
+
+	for _, path := range []string{automations, documents} {
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("Failed to remove %s: %v", path, err)
+		}
+	}
+	server.StartRun(RunTriggerManual).Wait()
+
+	server.State.Mu.RLock()
+	defer server.State.Mu.RUnlock()
+	for identifier, summary := range server.State.CachedBackupSummaries["test"] {
+		if !summary.Deleted {
+			t.Errorf("Expected %s in %s to be marked as deleted", identifier.ID, identifier.Path)
+		}
+	}
+	if count := len(server.State.CachedBackupSummaries["test"]); count != 4 {
+		t.Errorf("Expected 4 tracked entries, got %d", count)
+	}
+}
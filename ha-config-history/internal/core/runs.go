@@ -0,0 +1,266 @@
+package core
+
+import (
+	"ha-config-history/internal/types"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxRunHistory is the number of finished runs kept for GET /jobs
+const maxRunHistory = 50
+
+type RunTrigger string
+
+const (
+	RunTriggerStartup   RunTrigger = "startup"
+	RunTriggerManual    RunTrigger = "manual"
+	RunTriggerScheduled RunTrigger = "scheduled"
+	RunTriggerSettings  RunTrigger = "settings"
+)
+
+type RunStatus string
+
+const (
+	RunStatusRunning   RunStatus = "running"
+	RunStatusCompleted RunStatus = "completed"
+	// RunStatusCancelled is a run that stopped scanning early because the server is
+	// shutting down
+	RunStatusCancelled RunStatus = "cancelled"
+)
+
+// RunError is a config or entry that could not be scanned or backed up during a run.
+type RunError struct {
+	Group types.GroupSlug `json:"group"`
+	Path  string          `json:"path"`
+	ID    string          `json:"id,omitempty"`
+	Error string          `json:"error"`
+}
+
+// RunSummary is the progress of a backup run, a scan of every config for changes started
+// manually, on schedule or on startup.
+type RunSummary struct {
+	ID                 string     `json:"id"`
+	Trigger            RunTrigger `json:"trigger"`
+	Status             RunStatus  `json:"status"`
+	StartedAt          time.Time  `json:"startedAt"`
+	FinishedAt         *time.Time `json:"finishedAt,omitempty"`
+	ConfigsScanned     int        `json:"configsScanned"`
+	VersionsSaved      int        `json:"versionsSaved"`
+	Unchanged          int        `json:"unchanged"`
+	Renamed            int        `json:"renamed"`
+	Deleted            int        `json:"deleted"`
+	RetentionDeletions int        `json:"retentionDeletions"`
+	Errors             []RunError `json:"errors"`
+}
+
+// Run tracks a backup run until every job it queued has been processed.
+type Run struct {
+	mu      sync.Mutex
+	summary RunSummary
+	pending sync.WaitGroup
+}
+
+// jobResult is what processing a backup job did, recorded against its run.
+type jobResult struct {
+	saved   bool
+	removed int
+	err     error
+}
+
+func (r *Run) update(update func(*RunSummary)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	update(&r.summary)
+}
+
+func (r *Run) recordJob(job backupJob, result jobResult) {
+	r.update(func(r *RunSummary) {
+		if result.err != nil {
+			r.Errors = append(r.Errors, RunError{Group: job.GroupSlug, Path: job.Backup.Path, ID: job.Backup.ID, Error: result.err.Error()})
+			return
+		}
+
+		switch job.Action {
+		case jobActionRename:
+			r.Renamed++
+		case jobActionDelete:
+			r.Deleted++
+		default:
+			if result.saved {
+				r.VersionsSaved++
+			} else {
+				r.Unchanged++
+			}
+		}
+		r.RetentionDeletions += result.removed
+	})
+}
+
+func (r *Run) recordScan(groupSlug types.GroupSlug, options *types.ConfigBackupOptions, err error) {
+	r.update(func(r *RunSummary) {
+		r.ConfigsScanned++
+		if err != nil {
+			r.Errors = append(r.Errors, RunError{Group: groupSlug, Path: options.Path, Error: err.Error()})
+		}
+	})
+}
+
+// Snapshot returns a copy of the run's progress that is safe to read while it is running.
+func (r *Run) Snapshot() RunSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	summary := r.summary
+	summary.Errors = slices.Clone(r.summary.Errors)
+	return summary
+}
+
+// Wait blocks until the run has finished.
+func (r *Run) Wait() {
+	r.pending.Wait()
+}
+
+// runHistory keeps the runs in progress and the most recent finished runs, newest first.
+type runHistory struct {
+	mu   sync.Mutex
+	runs []*Run
+}
+
+func (h *runHistory) add(run *Run) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.runs = append([]*Run{run}, h.runs...)
+
+	// Drop the oldest finished runs beyond the limit, never one that is still running
+	for i := len(h.runs) - 1; i >= 0 && len(h.runs) > maxRunHistory; i-- {
+		if h.runs[i].Snapshot().Status != RunStatusRunning {
+			h.runs = slices.Delete(h.runs, i, i+1)
+		}
+	}
+}
+
+func (h *runHistory) list() []RunSummary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshots := make([]RunSummary, 0, len(h.runs))
+	for _, run := range h.runs {
+		snapshots = append(snapshots, run.Snapshot())
+	}
+	return snapshots
+}
+
+func (h *runHistory) get(id string) (RunSummary, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, run := range h.runs {
+		if summary := run.Snapshot(); summary.ID == id {
+			return summary, true
+		}
+	}
+	return RunSummary{}, false
+}
+
+// StartRun scans every config for changes in the background and returns the run, which
+// finishes once all the backup jobs it queued have been processed.
+func (s *Server) StartRun(trigger RunTrigger) *Run {
+	s.settingsMu.RLock()
+	configs := trackedConfigs(s.AppSettings)
+	s.settingsMu.RUnlock()
+
+	return s.startRun(trigger, configs)
+}
+
+func (s *Server) startRun(trigger RunTrigger, configs []GroupedConfigBackupOptions) *Run {
+	run := &Run{summary: RunSummary{
+		ID:        uuid.NewString(),
+		Trigger:   trigger,
+		Status:    RunStatusRunning,
+		StartedAt: time.Now().UTC(),
+		Errors:    []RunError{},
+	}}
+	s.runs.add(run)
+	s.events.Publish(EventJobStarted, run.Snapshot())
+
+	// The scan itself is pending until every config has been read
+	run.pending.Add(1)
+	s.scans.Add(1)
+	cancelled := false
+	go func() {
+		defer s.scans.Done()
+		defer run.pending.Done()
+
+		for _, config := range configs {
+			if s.ctx.Err() != nil {
+				cancelled = true
+				return
+			}
+			run.recordScan(config.GroupSlug, config.Options, s.processConfigOptions(config.GroupSlug, config.Options, run))
+		}
+	}()
+
+	go func() {
+		run.Wait()
+		finishedAt := time.Now().UTC()
+		run.update(func(r *RunSummary) {
+			r.Status = RunStatusCompleted
+			if cancelled {
+				r.Status = RunStatusCancelled
+			}
+			r.FinishedAt = &finishedAt
+		})
+
+		summary := run.Snapshot()
+		s.events.Publish(EventJobFinished, summary)
+		slog.Info("Backup run finished",
+			"id", summary.ID,
+			"trigger", summary.Trigger,
+			"saved", summary.VersionsSaved,
+			"unchanged", summary.Unchanged,
+			"errors", len(summary.Errors),
+			"duration", finishedAt.Sub(summary.StartedAt),
+		)
+	}()
+
+	return run
+}
+
+// Runs returns the runs in progress and the most recent finished runs, newest first.
+func (s *Server) Runs() []RunSummary {
+	return s.runs.list()
+}
+
+// GetRun returns the run with the given id.
+func (s *Server) GetRun(id string) (RunSummary, bool) {
+	return s.runs.get(id)
+}
+
+// enqueue journals and queues a backup job, counting it towards run when it is part of
+// one.
+func (s *Server) enqueue(job backupJob, run *Run) {
+	// A save of content that is already the latest backup is not journaled, which keeps
+	// scans of unchanged configs off the disk
+	if s.journal != nil && (job.Action != jobActionSave || s.needsUpdate(job.GroupSlug, job.Backup)) {
+		s.settingsMu.RLock()
+		redaction := s.AppSettings.Redaction
+		s.settingsMu.RUnlock()
+
+		id, err := s.journal.record(job, redaction)
+		if err != nil {
+			slog.Error("Error writing backup job to journal", "id", job.Backup.ID, "error", err)
+		}
+		job.journalID = id
+	}
+	if run != nil {
+		run.pending.Add(1)
+		job.run = run
+		job.done = run.pending.Done
+	}
+	s.queue.Enqueue(job)
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ha-config-history/internal/types"
+)
+
+func newRunTestServer(t *testing.T, configs ...*types.ConfigBackupOptions) (*Server, string) {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	haConfigDir := filepath.Join(tempDir, "ha-config")
+	if err := os.MkdirAll(haConfigDir, 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+
+	server := newServer(&types.AppSettings{
+		HomeAssistantConfigDir: haConfigDir,
+		BackupDir:              filepath.Join(tempDir, "backups"),
+		ConfigGroups: []*types.ConfigBackupOptionGroup{
+			types.NewConfigBackupOptionGroup("Test", configs),
+		},
+	}, filepath.Join(tempDir, "settings.json"), newFakeEventSource())
+	t.Cleanup(server.queue.Close)
+
+	return server, haConfigDir
+}
+
+func TestRunCountsSavedAndUnchangedVersions(t *testing.T) {
+	server, haConfigDir := newRunTestServer(t,
+		types.NewMultipleConfigBackupOptions("automations.yaml", "id", "alias"),
+		types.NewSingleConfigBackupOptions("missing.yaml"),
+	)
+	automations := filepath.Join(haConfigDir, "automations.yaml")
+	writeTestFile(t, automations, "- id: one\n  alias: One\n- id: two\n  alias: Two\n")
+
+	first := server.StartRun(RunTriggerManual)
+	first.Wait()
+
+	summary, exists := server.GetRun(first.Snapshot().ID)
+	if !exists {
+		t.Fatal("Expected the run to be in the history")
+	}
+	if summary.ConfigsScanned != 2 {
+		t.Errorf("Expected 2 configs scanned, got %d", summary.ConfigsScanned)
+	}
+	if summary.VersionsSaved != 2 || summary.Unchanged != 0 {
+		t.Errorf("Expected 2 versions saved, got %d saved and %d unchanged", summary.VersionsSaved, summary.Unchanged)
+	}
+
+	writeTestFile(t, automations, "- id: one\n  alias: One\n- id: two\n  alias: Two changed\n")
+	second := server.StartRun(RunTriggerScheduled)
+	second.Wait()
+
+	summary = second.Snapshot()
+	if summary.VersionsSaved != 1 || summary.Unchanged != 1 {
+		t.Errorf("Expected 1 saved and 1 unchanged, got %d saved and %d unchanged", summary.VersionsSaved, summary.Unchanged)
+	}
+
+	runs := server.Runs()
+	if len(runs) != 2 || runs[0].ID != summary.ID {
+		t.Fatalf("Expected both runs newest first, got %d runs", len(runs))
+	}
+}
+
+func TestRunRecordsConfigErrors(t *testing.T) {
+	server, haConfigDir := newRunTestServer(t, types.NewMultipleConfigBackupOptions("automations.yaml", "id", "alias"))
+	writeTestFile(t, filepath.Join(haConfigDir, "automations.yaml"), "- id: one\n  alias: [unclosed\n")
+
+	run := server.StartRun(RunTriggerManual)
+	run.Wait()
+
+	summary := run.Snapshot()
+	if len(summary.Errors) != 1 || summary.Errors[0].Path != "automations.yaml" {
+		t.Fatalf("Expected an error for automations.yaml, got %+v", summary.Errors)
+	}
+}
+
+func TestRunHistoryKeepsRecentRuns(t *testing.T) {
+	history := runHistory{}
+	for range maxRunHistory + 5 {
+		history.add(&Run{summary: RunSummary{Status: RunStatusCompleted}})
+	}
+	running := &Run{summary: RunSummary{ID: "running", Status: RunStatusRunning}}
+	history.add(running)
+
+	runs := history.list()
+	if len(runs) != maxRunHistory {
+		t.Fatalf("Expected %d runs, got %d", maxRunHistory, len(runs))
+	}
+	if runs[0].ID != "running" {
+		t.Errorf("Expected the newest run first, got %s", runs[0].ID)
+	}
+}
@@ -1,22 +1,44 @@
 package core
 
 import (
+	"context"
 	"ha-config-history/internal/io"
 	"ha-config-history/internal/types"
 	"log"
 	"log/slog"
+	"path/filepath"
 	"sync"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/robfig/cron/v3"
 )
 
+type jobAction int
+
+const (
+	// jobActionSave saves the backup if its content changed
+	jobActionSave jobAction = iota
+	// jobActionRename marks the backup's config as renamed to RenamedTo
+	jobActionRename
+	// jobActionDelete marks the backup's config as removed from its file
+	jobActionDelete
+	// jobActionClearDeletion marks the backup's config as back in its file
+	jobActionClearDeletion
+)
+
 // backupJob represents a backup job to be processed in the queue
 type backupJob struct {
+	Action    jobAction
 	GroupSlug types.GroupSlug
 	Options   *types.ConfigBackupOptions
 	Backup    *types.ConfigBackup
+	RenamedTo string
+	// run is the backup run that queued the job, if any
+	run *Run
+	// done is called once the job has been processed
+	done func()
+	// journalID is the job's entry in the job journal, 0 when it was not journaled
+	journalID uint64
 }
 
 func NewBackupJob(
@@ -24,19 +46,69 @@ func NewBackupJob(
 	options *types.ConfigBackupOptions,
 	backup *types.ConfigBackup) backupJob {
 	return backupJob{
+		Action:    jobActionSave,
 		GroupSlug: groupSlug,
 		Options:   options,
 		Backup:    backup,
 	}
 }
 
+func NewRenameJob(
+	groupSlug types.GroupSlug,
+	options *types.ConfigBackupOptions,
+	identifier types.ConfigBackupIdentifier,
+	renamedTo string) backupJob {
+	return backupJob{
+		Action:    jobActionRename,
+		GroupSlug: groupSlug,
+		Options:   options,
+		Backup:    &types.ConfigBackup{ConfigBackupIdentifier: identifier},
+		RenamedTo: renamedTo,
+	}
+}
+
+func NewDeleteJob(
+	groupSlug types.GroupSlug,
+	options *types.ConfigBackupOptions,
+	identifier types.ConfigBackupIdentifier) backupJob {
+	return backupJob{
+		Action:    jobActionDelete,
+		GroupSlug: groupSlug,
+		Options:   options,
+		Backup:    &types.ConfigBackup{ConfigBackupIdentifier: identifier},
+	}
+}
+
+func NewClearDeletionJob(
+	groupSlug types.GroupSlug,
+	options *types.ConfigBackupOptions,
+	identifier types.ConfigBackupIdentifier) backupJob {
+	return backupJob{
+		Action:    jobActionClearDeletion,
+		GroupSlug: groupSlug,
+		Options:   options,
+		Backup:    &types.ConfigBackup{ConfigBackupIdentifier: identifier},
+	}
+}
+
 type Server struct {
-	State          *State
-	AppSettings    *types.AppSettings
-	ConfigPath     string
-	queue          chan backupJob
-	processingFile bool
-	fileWatcher    *fsnotify.Watcher
+	State       *State
+	AppSettings *types.AppSettings
+	ConfigPath  string
+	queue       *jobQueue
+	fileWatcher eventSource
+	changes     *debouncer
+	runs        runHistory
+	events      *EventBus
+	journal     *jobJournal
+	// ctx is cancelled when the server stops taking in new work
+	ctx        context.Context
+	stopIntake context.CancelFunc
+	// scans counts the runs still reading configs and queueing jobs
+	scans sync.WaitGroup
+	// settingsMu is held for reading while a job is processed and for writing while the
+	// settings are replaced, so a job never sees a half applied change
+	settingsMu sync.RWMutex
 }
 
 func (s *Server) validateConfig() {
@@ -45,6 +117,12 @@ func (s *Server) validateConfig() {
 			"dir", s.AppSettings.HomeAssistantConfigDir)
 	}
 
+	for _, root := range s.AppSettings.SourceRoots {
+		if root != nil && !io.DirectoryExists(root.Path) {
+			slog.Warn("Source root directory does not exist", "root", root.Name, "dir", root.Path)
+		}
+	}
+
 	// Check configs from new grouped structure
 	for _, group := range s.AppSettings.ConfigGroups {
 		uniquePaths := make(map[string]struct{})
@@ -61,6 +139,43 @@ func (s *Server) validateConfig() {
 }
 
 func NewServer(config *types.AppSettings, configPath string) *Server {
+	notify, err := newFsnotifySource()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := newServer(config, configPath, nil)
+	s.fileWatcher = newFallbackSource(notify, newPollingSource(s.pollInterval), s.pollsPath)
+	return s
+}
+
+// Settings returns the current settings. They are replaced rather than changed when settings
+// are saved, so the returned settings can be read without holding a lock.
+func (s *Server) Settings() *types.AppSettings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.AppSettings
+}
+
+func (s *Server) pollInterval() time.Duration {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.AppSettings.PollInterval()
+}
+
+func (s *Server) watchQuietPeriod() time.Duration {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.AppSettings.WatchQuietPeriod()
+}
+
+func (s *Server) pollsPath(path string) bool {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.AppSettings.PollsPath(path)
+}
+
+func newServer(config *types.AppSettings, configPath string, fileWatcher eventSource) *Server {
 	summaries, err := io.LoadAllBackupConfigSummaries(config.BackupDir)
 	if err != nil {
 		slog.Error("Error loading metadata", "error", err)
@@ -69,60 +184,99 @@ func NewServer(config *types.AppSettings, configPath string) *Server {
 		summaries = map[types.GroupSlug]types.BackupConfigSummaryMap{}
 	}
 
-	fileWatcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return &Server{
+	s := &Server{
 		State: &State{
 			CachedBackupSummaries: summaries,
 			FileLookup:            make(WatchedFileLookup),
 		},
 		AppSettings: config,
 		ConfigPath:  configPath,
-		queue:       make(chan backupJob),
 		fileWatcher: fileWatcher,
+		events:      NewEventBus(),
+	}
+	if config.BackupDir != "" {
+		s.journal = openJobJournal(filepath.Join(config.BackupDir, journalFolderName))
 	}
+	s.ctx, s.stopIntake = context.WithCancel(context.Background())
+	s.queue = newJobQueue(config.QueueWorkers(), config.QueueCapacity(), s.processJob)
+	return s
 }
 
-func (s *Server) Start() {
-	s.startQueueProcessor()
+// Start replays the job journal, watches the configs and starts the startup run and cron
+// schedule. The server stops taking in new work when ctx is done, and Shutdown finishes the
+// work already taken in.
+func (s *Server) Start(ctx context.Context) {
+	context.AfterFunc(ctx, s.stopIntake)
+
+	s.replayJournal()
 	s.startFileWatcher()
 	s.validateConfig()
-	s.ProcessAllConfigOptions()
+	s.StartRun(RunTriggerStartup)
 	_ = s.RestartCronJob()
 }
 
+// WaitForInactive blocks until every backup job queued so far has been processed.
 func (s *Server) WaitForInactive() {
-	time.Sleep(1 * time.Second)
+	s.queue.Wait()
+}
 
-	for len(s.queue) != 0 || s.processingFile {
-		time.Sleep(100 * time.Millisecond)
-	}
+// Events returns the feed of changes published to clients.
+func (s *Server) Events() *EventBus {
+	return s.events
 }
 
-// Shutdown gracefully stops the server resources
-func (s *Server) Shutdown() {
+// QueueMetrics returns the state of the backup job queue.
+func (s *Server) QueueMetrics() QueueMetrics {
+	return s.queue.Metrics()
+}
+
+// Shutdown stops taking in new work, then processes the changes that were already seen and
+// the jobs already queued. It returns ctx's error if they are not done before ctx is, and
+// the jobs left in the journal are replayed on the next start.
+func (s *Server) Shutdown(ctx context.Context) error {
 	slog.Info("Shutting down server...")
+	defer s.events.Close()
+
+	// Stop intake: scheduled runs, scans in progress and file events
+	s.stopIntake()
+	s.State.Mu.RLock()
+	cronJob := s.State.CronJob
+	s.State.Mu.RUnlock()
+	if cronJob != nil {
+		<-cronJob.Stop().Done()
+	}
 	if s.fileWatcher != nil {
 		if err := s.fileWatcher.Close(); err != nil {
 			slog.Error("Error closing file watcher", "error", err)
 		}
 	}
-	if s.queue != nil {
-		close(s.queue)
-	}
-	if s.State.CronJob != nil {
-		s.State.CronJob.Stop()
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		s.scans.Wait()
+		// Changes still in their quiet period are read now so they are queued
+		if s.changes != nil {
+			s.changes.Drain()
+		}
+		s.queue.Close()
+	}()
+
+	select {
+	case <-drained:
+		slog.Info("Server shutdown complete")
+		return nil
+	case <-ctx.Done():
+		slog.Warn("Shutdown timed out before all backup jobs were processed", "queued", s.queue.Metrics().Queued)
+		return ctx.Err()
 	}
-	slog.Info("Server shutdown complete")
 }
 
 type State struct {
 	Mu                    sync.RWMutex
 	CachedBackupSummaries map[types.GroupSlug]types.BackupConfigSummaryMap
 	CronJob               *cron.Cron
+	CronEntries           map[cron.EntryID]string // Schedule of each cron entry
 	FileLookup            WatchedFileLookup
 }
 
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ha-config-history/internal/types"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestShutdownDuringBurstOfFileEvents(t *testing.T) {
+	configs := []*types.ConfigBackupOptions{}
+	for i := range 20 {
+		configs = append(configs, types.NewSingleConfigBackupOptions(fmt.Sprintf("package_%d.yaml", i)))
+	}
+
+	tempDir := t.TempDir()
+	haConfigDir := filepath.Join(tempDir, "ha-config")
+	if err := os.MkdirAll(haConfigDir, 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+
+	// A quiet period longer than the test leaves every change pending when shutdown starts
+	quietPeriodMs := 60000
+	source := newFakeEventSource()
+	server := newServer(&types.AppSettings{
+		HomeAssistantConfigDir: haConfigDir,
+		BackupDir:              filepath.Join(tempDir, "backups"),
+		WatchQuietPeriodMs:     &quietPeriodMs,
+		ConfigGroups:           []*types.ConfigBackupOptionGroup{types.NewConfigBackupOptionGroup("Test", configs)},
+	}, filepath.Join(tempDir, "settings.json"), source)
+
+	for _, options := range configs {
+		path := filepath.Join(haConfigDir, options.Path)
+		writeTestFile(t, path, "homeassistant:\n")
+		if err := server.watchDirectoryForFile("test", path, options); err != nil {
+			t.Fatalf("Failed to watch %s: %v", options.Path, err)
+		}
+	}
+	server.startFileWatcher()
+
+	// Events keep arriving while the server shuts down
+	burstDone := make(chan struct{})
+	go func() {
+		defer close(burstDone)
+		for round := range 5 {
+			for _, options := range configs {
+				source.send(filepath.Join(haConfigDir, options.Path), fsnotify.Write)
+			}
+			if round == 0 {
+				time.Sleep(testQuietPeriod)
+			}
+		}
+	}()
+	time.Sleep(testQuietPeriod)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Expected pending changes to be processed, got %v", err)
+	}
+	<-burstDone
+
+	// Every file changed before shutdown was backed up, even though its quiet period had
+	// not ended
+	for _, options := range configs {
+		backups, err := os.ReadDir(filepath.Join(tempDir, "backups", "test", options.Path, options.Path))
+		if err != nil || len(backups) == 0 {
+			t.Errorf("Expected %s to be backed up before shutdown, got %v", options.Path, err)
+		}
+	}
+
+	metrics := server.QueueMetrics()
+	if metrics.Queued != 0 || metrics.InProgress != 0 {
+		t.Errorf("Expected the queue to be drained, got %+v", metrics)
+	}
+}
+
+func TestShutdownTimesOut(t *testing.T) {
+	server, _ := newRunTestServer(t)
+
+	release := make(chan struct{})
+	server.queue.Close()
+	server.queue = newJobQueue(1, 10, func(job backupJob) { <-release })
+	defer close(release)
+
+	server.queue.Enqueue(NewDeleteJob("test", types.NewSingleConfigBackupOptions("configuration.yaml"), types.ConfigBackupIdentifier{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testQuietPeriod)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the shutdown to time out, got %v", err)
+	}
+}
+
+func TestShutdownCancelsRunsInProgress(t *testing.T) {
+	server, haConfigDir := newRunTestServer(t,
+		types.NewSingleConfigBackupOptions("configuration.yaml"),
+		types.NewSingleConfigBackupOptions("customize.yaml"),
+	)
+	writeTestFile(t, filepath.Join(haConfigDir, "configuration.yaml"), "homeassistant:\n")
+
+	// Intake stops before the run reads its first config
+	server.stopIntake()
+	run := server.StartRun(RunTriggerManual)
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected a clean shutdown, got %v", err)
+	}
+	run.Wait()
+
+	// The run's status is set once its jobs are done
+	deadline := time.Now().Add(time.Second)
+	for run.Snapshot().Status == RunStatusRunning && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if summary := run.Snapshot(); summary.Status != RunStatusCancelled || summary.ConfigsScanned != 0 {
+		t.Errorf("Expected the run to be cancelled before scanning, got %+v", summary)
+	}
+}
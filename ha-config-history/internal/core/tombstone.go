@@ -0,0 +1,89 @@
+package core
+
+import (
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"time"
+)
+
+// queueRemovedEntries compares the entries currently in a file with the known configs for
+// that file. Missing entries that were renamed are linked to their new id, and the rest are
+// queued to be marked as deleted.
+func (s *Server) queueRemovedEntries(settings *types.AppSettings, groupSlug types.GroupSlug, options *types.ConfigBackupOptions, current []*types.ConfigBackup, run *Run) {
+	missing, added := s.missingAndAddedEntries(groupSlug, options, current)
+	renamed := s.queueRenames(settings.BackupDir, groupSlug, options, missing, added, run)
+
+	for _, summary := range missing {
+		if renamed[summary.ID] {
+			continue
+		}
+		s.enqueue(NewDeleteJob(groupSlug, options, summary.ConfigBackupIdentifier), run)
+	}
+}
+
+// queueDeletionIfTracked queues a config to be marked as deleted when its file is gone and
+// it has backups that are not already marked.
+func (s *Server) queueDeletionIfTracked(groupSlug types.GroupSlug, options *types.ConfigBackupOptions, identifier types.ConfigBackupIdentifier, run *Run) {
+	s.State.Mu.RLock()
+	summary, exists := s.State.CachedBackupSummaries[groupSlug][identifier]
+	s.State.Mu.RUnlock()
+
+	if !exists || summary.Deleted || summary.RenamedTo != "" {
+		return
+	}
+	s.enqueue(NewDeleteJob(groupSlug, options, identifier), run)
+}
+
+func (s *Server) handleDeletion(groupSlug types.GroupSlug, identifier types.ConfigBackupIdentifier) error {
+	deletedAt := time.Now().UTC()
+	updatedMetadata, err := io.MarkConfigDeleted(s.AppSettings.BackupDir, groupSlug, identifier.Path, identifier.ID, deletedAt)
+	if err != nil {
+		slog.Error("Error marking config as deleted",
+			"id", identifier.ID,
+			"error", err,
+		)
+		return err
+	}
+
+	slog.Info("Config removed from file, marked as deleted",
+		"friendlyName", updatedMetadata.FriendlyName,
+		"id", identifier.ID,
+		"path", identifier.Path,
+		"deletedAt", deletedAt,
+	)
+
+	s.updateCachedMetadata(groupSlug, updatedMetadata)
+	return nil
+}
+
+// QueueClearDeletion queues the deletion record of a config that was put back in its file
+// to be removed, after any jobs already queued for the config.
+func (s *Server) QueueClearDeletion(groupSlug types.GroupSlug, options *types.ConfigBackupOptions, identifier types.ConfigBackupIdentifier) {
+	s.enqueue(NewClearDeletionJob(groupSlug, options, identifier), nil)
+}
+
+// clearDeletion removes the deletion record from a config that is back in its file.
+func (s *Server) clearDeletion(groupSlug types.GroupSlug, identifier types.ConfigBackupIdentifier) error {
+	updatedMetadata, err := io.ClearConfigDeleted(s.AppSettings.BackupDir, groupSlug, identifier.Path, identifier.ID)
+	if err != nil {
+		slog.Error("Error clearing config deletion",
+			"id", identifier.ID,
+			"error", err,
+		)
+		return err
+	}
+
+	s.updateCachedMetadata(groupSlug, updatedMetadata)
+	return nil
+}
+
+// reappearedUnchanged reports whether a deleted config is back in its file with the
+// same content as its last backup, so no new backup is needed.
+func (s *Server) reappearedUnchanged(groupSlug types.GroupSlug, activeConfigBackup *types.ConfigBackup) bool {
+	s.State.Mu.RLock()
+	defer s.State.Mu.RUnlock()
+
+	metadata, exists := s.State.CachedBackupSummaries[groupSlug][activeConfigBackup.ConfigBackupIdentifier]
+	return exists && metadata.Deleted && metadata.LastHash == activeConfigBackup.Hash
+}
@@ -0,0 +1,93 @@
+package homeassistant
+
+import (
+	"context"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// configDomains maps the files Home Assistant's editors write to the domain they belong to.
+var configDomains = map[string]string{
+	"automations.yaml":   "automation",
+	"scripts.yaml":       "script",
+	"scenes.yaml":        "scene",
+	"configuration.yaml": "homeassistant",
+}
+
+// DomainForPath returns the Home Assistant domain whose config is stored in a file, or an
+// empty string when the file is not one of the editors' files.
+func DomainForPath(path string) string {
+	return configDomains[filepath.Base(path)]
+}
+
+// Attribute finds the Home Assistant activity closest to a change made at changedAt, within
+// window either side, and returns who made it. Only the reload the config editors call after
+// saving a file in domain is matched, since other activity around the change, such as an
+// automation turning on a light, did not write the file. A change with no matching activity,
+// or to a file outside the editors' domains, was made outside Home Assistant.
+func (c *Client) Attribute(ctx context.Context, changedAt time.Time, window time.Duration, domain string) (*types.VersionAttribution, error) {
+	if domain == "" {
+		return &types.VersionAttribution{Source: types.ChangeSourceExternal}, nil
+	}
+
+	entries, err := c.Logbook(ctx, changedAt.Add(-window), changedAt.Add(window))
+	if err != nil {
+		return nil, err
+	}
+
+	var best *LogbookEntry
+	for i, entry := range entries {
+		if !isEditorReload(entry, domain) {
+			continue
+		}
+		if best == nil || distance(entry.When, changedAt) < distance(best.When, changedAt) {
+			best = &entries[i]
+		}
+	}
+
+	if best == nil {
+		return &types.VersionAttribution{Source: types.ChangeSourceExternal}, nil
+	}
+
+	activityAt := best.When
+	attribution := &types.VersionAttribution{
+		Source:     types.ChangeSourceIntegration,
+		Detail:     describe(best),
+		ActivityAt: &activityAt,
+	}
+	if best.ContextUserID != "" {
+		attribution.Source = types.ChangeSourceUser
+		attribution.UserID = best.ContextUserID
+		attribution.User = best.ContextUserID
+
+		people, err := c.People(ctx)
+		if err != nil {
+			slog.Debug("Could not look up user name", "userId", best.ContextUserID, "error", err)
+		} else if name, exists := people[best.ContextUserID]; exists && name != "" {
+			attribution.User = name
+		}
+	}
+	return attribution, nil
+}
+
+// isEditorReload reports whether an entry comes from a reload of domain, which the config
+// editors call after saving.
+func isEditorReload(entry LogbookEntry, domain string) bool {
+	return entry.ContextEventType == "call_service" && entry.ContextDomain == domain &&
+		strings.HasPrefix(entry.ContextService, "reload")
+}
+
+// describe returns the service that caused an entry, or the entry itself.
+func describe(entry *LogbookEntry) string {
+	if entry.ContextDomain != "" && entry.ContextService != "" {
+		return entry.ContextDomain + "." + entry.ContextService
+	}
+	return strings.TrimSpace(entry.Name + " " + entry.Message)
+}
+
+func distance(a, b time.Time) time.Duration {
+	return max(a.Sub(b), b.Sub(a))
+}
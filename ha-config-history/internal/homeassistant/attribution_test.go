@@ -0,0 +1,155 @@
+package homeassistant_test
+
+import (
+	"context"
+	"encoding/json"
+	"ha-config-history/internal/homeassistant"
+	"ha-config-history/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// standInServer serves the parts of the Home Assistant API used for attribution.
+func standInServer(t *testing.T, logbook []map[string]any) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/logbook/"):
+			if _, err := time.Parse(time.RFC3339, strings.TrimPrefix(r.URL.Path, "/api/logbook/")); err != nil {
+				t.Errorf("Expected a start time in the logbook path, got %s", r.URL.Path)
+			}
+			if r.URL.Query().Get("end_time") == "" {
+				t.Error("Expected an end time for the logbook")
+			}
+			json.NewEncoder(w).Encode(logbook)
+		case r.URL.Path == "/api/states":
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"entity_id": "person.alex", "attributes": map[string]any{"friendly_name": "Alex", "user_id": "user-alex"}},
+				{"entity_id": "light.porch", "attributes": map[string]any{"friendly_name": "Porch"}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAttribute(t *testing.T) {
+	changedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) string { return changedAt.Add(offset).Format(time.RFC3339Nano) }
+
+	tests := []struct {
+		name     string
+		domain   string
+		logbook  []map[string]any
+		expected types.VersionAttribution
+	}{
+		{
+			name:   "automation saved in the UI editor",
+			domain: "automation",
+			logbook: []map[string]any{
+				{"when": at(-20 * time.Second), "name": "Kitchen", "message": "turned on", "domain": "light", "context_user_id": "user-alex", "context_event_type": "call_service", "context_domain": "light", "context_service": "turn_on"},
+				{"when": at(time.Second), "name": "Porch lights", "message": "reloaded", "domain": "automation", "context_user_id": "user-alex", "context_event_type": "call_service", "context_domain": "automation", "context_service": "reload"},
+			},
+			expected: types.VersionAttribution{Source: types.ChangeSourceUser, User: "Alex", UserID: "user-alex", Detail: "automation.reload"},
+		},
+		{
+			name:   "user without a person keeps their id",
+			domain: "script",
+			logbook: []map[string]any{
+				{"when": at(2 * time.Second), "name": "Morning", "domain": "script", "context_user_id": "user-sam", "context_event_type": "call_service", "context_domain": "script", "context_service": "reload"},
+			},
+			expected: types.VersionAttribution{Source: types.ChangeSourceUser, User: "user-sam", UserID: "user-sam", Detail: "script.reload"},
+		},
+		{
+			name:   "reload without a user",
+			domain: "scene",
+			logbook: []map[string]any{
+				{"when": at(-time.Second), "name": "Scenes", "message": "reloaded", "domain": "scene", "context_event_type": "call_service", "context_domain": "scene", "context_service": "reload"},
+			},
+			expected: types.VersionAttribution{Source: types.ChangeSourceIntegration, Detail: "scene.reload"},
+		},
+		{
+			name:   "unrelated service call in the window",
+			domain: "automation",
+			logbook: []map[string]any{
+				{"when": at(-20 * time.Second), "name": "Kitchen", "message": "turned on", "domain": "light", "context_event_type": "automation_triggered", "context_domain": "automation", "context_service": ""},
+				{"when": at(-5 * time.Second), "name": "Kitchen", "message": "turned on", "domain": "light", "context_user_id": "user-alex", "context_event_type": "call_service", "context_domain": "light", "context_service": "turn_on"},
+			},
+			expected: types.VersionAttribution{Source: types.ChangeSourceExternal},
+		},
+		{
+			name: "file outside the editors' domains",
+			logbook: []map[string]any{
+				{"when": at(-time.Second), "name": "Dashboard", "message": "updated", "domain": "lovelace", "context_event_type": "call_service", "context_domain": "lovelace", "context_service": "reload_resources"},
+			},
+			expected: types.VersionAttribution{Source: types.ChangeSourceExternal},
+		},
+		{
+			name:   "no matching activity",
+			domain: "automation",
+			logbook: []map[string]any{
+				{"when": at(0), "name": "Porch", "message": "turned on", "domain": "light", "context_event_type": "state_changed"},
+			},
+			expected: types.VersionAttribution{Source: types.ChangeSourceExternal},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := standInServer(t, tt.logbook)
+			client := homeassistant.NewClient(server.URL, "test-token")
+
+			attribution, err := client.Attribute(context.Background(), changedAt, 30*time.Second, tt.domain)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			attribution.ActivityAt = nil
+			if *attribution != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *attribution)
+			}
+		})
+	}
+}
+
+func TestAttributeReportsAPIErrors(t *testing.T) {
+	server := standInServer(t, nil)
+	client := homeassistant.NewClient(server.URL, "wrong-token")
+
+	if _, err := client.Attribute(context.Background(), time.Now(), time.Minute, "automation"); err == nil {
+		t.Error("Expected an error when the API rejects the token")
+	}
+}
+
+func TestFromSettings(t *testing.T) {
+	t.Setenv("SUPERVISOR_TOKEN", "")
+	if client := homeassistant.FromSettings(nil); client != nil {
+		t.Error("Expected no client without a token")
+	}
+	if client := homeassistant.FromSettings(&types.HomeAssistantSettings{URL: "http://homeassistant.local:8123", Token: "token"}); client == nil {
+		t.Error("Expected a client for a configured URL and token")
+	}
+
+	t.Setenv("SUPERVISOR_TOKEN", "supervisor-token")
+	if client := homeassistant.FromSettings(nil); client == nil {
+		t.Error("Expected a client for the Supervisor proxy")
+	}
+}
+
+func TestDomainForPath(t *testing.T) {
+	if domain := homeassistant.DomainForPath("automations.yaml"); domain != "automation" {
+		t.Errorf("Expected automation, got %q", domain)
+	}
+	if domain := homeassistant.DomainForPath("packages/lights.yaml"); domain != "" {
+		t.Errorf("Expected no domain, got %q", domain)
+	}
+}
@@ -0,0 +1,135 @@
+// Package homeassistant talks to the Home Assistant REST API, directly or through the
+// Supervisor's proxy when running as an add-on.
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ha-config-history/internal/types"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// SupervisorURL is the Home Assistant API as proxied by the Supervisor for add-ons.
+const SupervisorURL = "http://supervisor/core"
+
+// Client calls the Home Assistant REST API.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client for the API at baseURL, such as
+// "http://homeassistant.local:8123", authenticated with token.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// FromSettings returns a client for the configured API, or for the Supervisor's proxy
+// when no URL is configured and SUPERVISOR_TOKEN is set. It returns nil when there is no
+// API to call.
+func FromSettings(settings *types.HomeAssistantSettings) *Client {
+	baseURL, token := "", ""
+	if settings != nil {
+		baseURL, token = settings.URL, settings.Token
+	}
+	if baseURL == "" {
+		baseURL = SupervisorURL
+		if token == "" {
+			token = os.Getenv("SUPERVISOR_TOKEN")
+		}
+	}
+	if token == "" {
+		return nil
+	}
+	return NewClient(baseURL, token)
+}
+
+// LogbookEntry is an entry in the Home Assistant logbook. The context fields describe the
+// event that caused it, such as a service call and the user who made it.
+type LogbookEntry struct {
+	When             time.Time `json:"when"`
+	Name             string    `json:"name"`
+	Message          string    `json:"message"`
+	Domain           string    `json:"domain"`
+	EntityID         string    `json:"entity_id"`
+	ContextUserID    string    `json:"context_user_id"`
+	ContextEventType string    `json:"context_event_type"`
+	ContextDomain    string    `json:"context_domain"`
+	ContextService   string    `json:"context_service"`
+}
+
+// Logbook returns the logbook entries between start and end.
+func (c *Client) Logbook(ctx context.Context, start, end time.Time) ([]LogbookEntry, error) {
+	path := "/api/logbook/" + url.PathEscape(start.UTC().Format(time.RFC3339)) +
+		"?end_time=" + url.QueryEscape(end.UTC().Format(time.RFC3339))
+
+	var entries []LogbookEntry
+	if err := c.get(ctx, path, &entries); err != nil {
+		return nil, fmt.Errorf("failed to read logbook: %w", err)
+	}
+	return entries, nil
+}
+
+type state struct {
+	EntityID   string `json:"entity_id"`
+	Attributes struct {
+		FriendlyName string `json:"friendly_name"`
+		UserID       string `json:"user_id"`
+	} `json:"attributes"`
+}
+
+// People returns the names of the people linked to Home Assistant users, by user id.
+func (c *Client) People(ctx context.Context) (map[string]string, error) {
+	var states []state
+	if err := c.get(ctx, "/api/states", &states); err != nil {
+		return nil, fmt.Errorf("failed to read people: %w", err)
+	}
+
+	people := map[string]string{}
+	for _, s := range states {
+		if strings.HasPrefix(s.EntityID, "person.") && s.Attributes.UserID != "" {
+			people[s.Attributes.UserID] = s.Attributes.FriendlyName
+		}
+	}
+	return people, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, result)
+}
+
+func (c *Client) do(req *http.Request, result any) error {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned %s", req.Method, req.URL.Path, resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package homeassistant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// reloadServices maps the domains whose config can be reloaded without a restart to the
+// service that reloads them.
+var reloadServices = map[string]string{
+	"automation":    "automation.reload",
+	"script":        "script.reload",
+	"scene":         "scene.reload",
+	"homeassistant": "homeassistant.reload_core_config",
+}
+
+// ReloadServiceForPath returns the service that makes Home Assistant pick up changes to a
+// file, or an empty string when the file has none.
+func ReloadServiceForPath(path string) string {
+	return reloadServices[DomainForPath(path)]
+}
+
+var serviceNamePattern = regexp.MustCompile(`^[a-z0-9_]+\.[a-z0-9_]+$`)
+
+// SplitService splits a service name such as "automation.reload" into its domain and
+// service. ok is false when the name is not in that form.
+func SplitService(name string) (domain, service string, ok bool) {
+	if !serviceNamePattern.MatchString(name) {
+		return "", "", false
+	}
+	domain, service, _ = strings.Cut(name, ".")
+	return domain, service, true
+}
+
+// CallService calls a Home Assistant service, such as "automation.reload", with data as
+// its service data.
+func (c *Client) CallService(ctx context.Context, name string, data any) error {
+	domain, service, ok := SplitService(name)
+	if !ok {
+		return fmt.Errorf("invalid service name %q", name)
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/services/"+domain+"/"+service, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to call %s: %w", name, err)
+	}
+	return nil
+}
+
+// ConfigCheck is the result of Home Assistant checking its configuration.
+type ConfigCheck struct {
+	Result   string `json:"result"` // "valid" or "invalid"
+	Errors   string `json:"errors"`
+	Warnings string `json:"warnings"`
+}
+
+// Valid reports whether Home Assistant found no errors in its configuration.
+func (c *ConfigCheck) Valid() bool {
+	return c.Result == "valid"
+}
+
+// CheckConfig asks Home Assistant to check its configuration as it is on disk. Home
+// Assistant checks the whole configuration, so errors may be in files other than the one
+// that changed.
+func (c *Client) CheckConfig(ctx context.Context) (*ConfigCheck, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/config/core/check_config", nil)
+	if err != nil {
+		return nil, err
+	}
+	var check ConfigCheck
+	if err := c.do(req, &check); err != nil {
+		return nil, fmt.Errorf("failed to check config: %w", err)
+	}
+	return &check, nil
+}
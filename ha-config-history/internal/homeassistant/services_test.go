@@ -0,0 +1,80 @@
+package homeassistant_test
+
+import (
+	"context"
+	"encoding/json"
+	"ha-config-history/internal/homeassistant"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallService(t *testing.T) {
+	var path string
+	var data map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&data)
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	client := homeassistant.NewClient(server.URL, "test-token")
+	if err := client.CallService(context.Background(), "script.reload", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if path != "/api/services/script/reload" || data == nil {
+		t.Errorf("Expected script.reload to be called with empty data, got %s %v", path, data)
+	}
+
+	if err := client.CallService(context.Background(), "reload", nil); err == nil {
+		t.Error("Expected an error for a service name without a domain")
+	}
+	if err := homeassistant.NewClient(server.URL, "wrong-token").CallService(context.Background(), "script.reload", nil); err == nil {
+		t.Error("Expected an error when the API rejects the call")
+	}
+}
+
+func TestReloadServiceForPath(t *testing.T) {
+	tests := map[string]string{
+		"automations.yaml":      "automation.reload",
+		"scripts.yaml":          "script.reload",
+		"scenes.yaml":           "scene.reload",
+		"configuration.yaml":    "homeassistant.reload_core_config",
+		"esphome/porch.yaml":    "",
+		"packages/scripts.yaml": "script.reload",
+	}
+	for path, expected := range tests {
+		if service := homeassistant.ReloadServiceForPath(path); service != expected {
+			t.Errorf("ReloadServiceForPath(%q) = %q, expected %q", path, service, expected)
+		}
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	response := `{"result": "valid", "errors": null, "warnings": null}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/config/core/check_config" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+	client := homeassistant.NewClient(server.URL, "test-token")
+
+	check, err := client.CheckConfig(context.Background())
+	if err != nil || !check.Valid() {
+		t.Fatalf("Expected a valid config, got %+v (%v)", check, err)
+	}
+
+	response = `{"result": "invalid", "errors": "Invalid config for 'automation' at automations.yaml, line 3", "warnings": null}`
+	check, err = client.CheckConfig(context.Background())
+	if err != nil || check.Valid() || check.Errors == "" {
+		t.Errorf("Expected an invalid config with errors, got %+v (%v)", check, err)
+	}
+}
@@ -0,0 +1,66 @@
+package io
+
+import (
+	"encoding/json"
+	"fmt"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// attributionSuffix ends the name of the file next to a backup that records who made the
+// change, so 20231010T120000.backup is attributed in 20231010T120000.meta.json.
+const attributionSuffix = ".meta.json"
+
+func attributionFilename(backupFilename string) string {
+	return strings.TrimSuffix(backupFilename, filepath.Ext(backupFilename)) + attributionSuffix
+}
+
+func isAttributionFile(name string) bool {
+	return strings.HasSuffix(name, attributionSuffix)
+}
+
+// SaveVersionAttribution records who made the change captured by a backup.
+func SaveVersionAttribution(backupFolder string, groupSlug types.GroupSlug, configPath, id, filename string, attribution *types.VersionAttribution) error {
+	backupPath, err := createBackupPath(backupFolder, groupSlug, configPath, id, filename)
+	if err != nil {
+		return fmt.Errorf("failed to create backup path: %w", err)
+	}
+	if _, err := os.Stat(backupPath); err != nil {
+		return fmt.Errorf("backup file not found: %s", filename)
+	}
+
+	blob, err := json.Marshal(attribution)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attribution: %w", err)
+	}
+	attributionPath := filepath.Join(filepath.Dir(backupPath), attributionFilename(filename))
+	if err := os.WriteFile(attributionPath, blob, 0644); err != nil {
+		return fmt.Errorf("failed to write attribution: %w", err)
+	}
+	return nil
+}
+
+// readVersionAttribution returns who made the change captured by a backup, or nil when it
+// is not known.
+func readVersionAttribution(configFolder, filename string) *types.VersionAttribution {
+	blob, err := os.ReadFile(filepath.Join(configFolder, attributionFilename(filename)))
+	if err != nil {
+		return nil
+	}
+	var attribution types.VersionAttribution
+	if err := json.Unmarshal(blob, &attribution); err != nil {
+		slog.Warn("Failed to parse attribution", "file", filename, "error", err)
+		return nil
+	}
+	return &attribution
+}
+
+func removeVersionAttribution(configFolder, filename string) {
+	attributionPath := filepath.Join(configFolder, attributionFilename(filename))
+	if err := os.Remove(attributionPath); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to remove attribution", "file", attributionPath, "error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package io
+
+import (
+	"fmt"
+	"ha-config-history/internal/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReadBlueprintsFromDirectory returns a backup for every blueprint file in a directory and
+// its subdirectories.
+func ReadBlueprintsFromDirectory(rootPath string, config *types.ConfigBackupOptions) ([]*types.ConfigBackup, error) {
+	directoryPath := rootPath + "/" + config.Path
+
+	configBackups := []*types.ConfigBackup{}
+	err := filepath.WalkDir(directoryPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isYamlFilename(entry.Name()) {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(directoryPath, path)
+		if err != nil {
+			return err
+		}
+
+		configBackup, err := ReadBlueprintFromFile(rootPath, relativePath, config)
+		if err != nil {
+			return err
+		}
+		configBackups = append(configBackups, configBackup)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blueprints in %s: %w", directoryPath, err)
+	}
+
+	return configBackups, nil
+}
+
+// ReadBlueprintFromFile reads a single blueprint given its path relative to the tracked
+// blueprints directory.
+func ReadBlueprintFromFile(rootPath, relativePath string, config *types.ConfigBackupOptions) (*types.ConfigBackup, error) {
+	currentTime := time.Now().UTC()
+	filePath := filepath.Join(rootPath, config.Path, relativePath)
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	return types.NewBlueprintConfigBackup(filePath, relativePath, data, config, currentTime)
+}
+
+// BackupAt returns the backup that was current at t, which is the newest backup saved at
+// or before t. backups must be sorted newest first, as returned by ListConfigBackups.
+func BackupAt(backups []BackupInfo, t time.Time) (BackupInfo, bool) {
+	for _, backup := range backups {
+		if !backup.Date.After(t) {
+			return backup, true
+		}
+	}
+	return BackupInfo{}, false
+}
@@ -0,0 +1,371 @@
+package io
+
+import (
+	"encoding/json"
+	"fmt"
+	"ha-config-history/internal/types"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// discoveryStorageExcludes lists .storage files that change constantly or hold
+// credentials, and so are never proposed for tracking.
+var discoveryStorageExcludes = []string{
+	"auth",
+	"auth_provider.*",
+	"core.analytics",
+	"core.restore_state",
+	"core.uuid",
+	"http",
+	"onboarding",
+	"trace.saved_traces",
+	"*.bak",
+}
+
+var discoveryKindOrder = map[string]int{
+	types.DiscoveryKindMultiple:  0,
+	types.DiscoveryKindKeyed:     1,
+	types.DiscoveryKindSingle:    2,
+	types.DiscoveryKindStorage:   3,
+	types.DiscoveryKindESPHome:   4,
+	types.DiscoveryKindBlueprint: 5,
+	types.DiscoveryKindNodeRED:   6,
+}
+
+// DiscoverConfigs scans a Home Assistant config directory and proposes config
+// groups for the files it finds, classified by their structure.
+func DiscoverConfigs(rootPath string) ([]*types.DiscoveredConfig, error) {
+	entries, err := os.ReadDir(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", rootPath, err)
+	}
+
+	discovered := []*types.DiscoveredConfig{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isYamlFilename(entry.Name()) || entry.Name() == "secrets.yaml" {
+			continue
+		}
+
+		proposal, err := discoverYamlFile(rootPath, entry.Name())
+		if err != nil {
+			slog.Debug("Skipping file during discovery", "file", entry.Name(), "error", err)
+			continue
+		}
+		discovered = append(discovered, proposal)
+	}
+
+	for _, discover := range []func(string) (*types.DiscoveredConfig, error){
+		discoverStorage,
+		discoverESPHome,
+		discoverBlueprints,
+		discoverNodeRED,
+	} {
+		proposal, err := discover(rootPath)
+		if err != nil {
+			return nil, err
+		}
+		if proposal != nil {
+			discovered = append(discovered, proposal)
+		}
+	}
+
+	sort.SliceStable(discovered, func(i, j int) bool {
+		if discovered[i].Kind != discovered[j].Kind {
+			return discoveryKindOrder[discovered[i].Kind] < discoveryKindOrder[discovered[j].Kind]
+		}
+		return discovered[i].Group.Name < discovered[j].Group.Name
+	})
+
+	return discovered, nil
+}
+
+func discoverYamlFile(rootPath, filename string) (*types.DiscoveredConfig, error) {
+	filePath := filepath.Join(rootPath, filename)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	var rootNode yaml.Node
+	if err := yaml.Unmarshal(data, &rootNode); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML in %s: %w", filePath, err)
+	}
+
+	var options *types.ConfigBackupOptions
+	kind := types.DiscoveryKindSingle
+
+	if len(rootNode.Content) > 0 {
+		contentNode := rootNode.Content[0]
+		if friendlyNameNode, ok := classifyMultiple(contentNode); ok {
+			kind = types.DiscoveryKindMultiple
+			options = types.NewMultipleConfigBackupOptions(filename, "id", friendlyNameNode)
+		} else if friendlyNameNode, ok := classifyKeyed(contentNode); ok {
+			kind = types.DiscoveryKindKeyed
+			options = &types.ConfigBackupOptions{Path: filename, BackupType: types.BackupTypeKeyedName}
+			if friendlyNameNode != "" {
+				options.FriendlyNameNode = &friendlyNameNode
+			}
+		}
+	}
+
+	if options == nil {
+		options = types.NewSingleConfigBackupOptions(filename)
+	}
+
+	proposal := &types.DiscoveredConfig{
+		Kind:  kind,
+		Group: types.NewConfigBackupOptionGroup(titleFromFilename(filename), []*types.ConfigBackupOptions{options}),
+	}
+	proposal.AddFile(filename, int64(len(data)))
+	return proposal, nil
+}
+
+// classifyMultiple reports whether node is a non-empty sequence of mappings that
+// all carry an id, returning the best friendly name field to use.
+func classifyMultiple(node *yaml.Node) (string, bool) {
+	if node.Kind != yaml.SequenceNode || len(node.Content) == 0 {
+		return "", false
+	}
+
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			return "", false
+		}
+		if _, ok := types.GetYamlNodeValueOk(item, "id"); !ok {
+			return "", false
+		}
+	}
+
+	return friendlyNameField(node.Content, "id"), true
+}
+
+// classifyKeyed reports whether node is a non-empty mapping whose values are all
+// mappings, returning the best friendly name field to use (possibly empty).
+func classifyKeyed(node *yaml.Node) (string, bool) {
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		return "", false
+	}
+
+	values := []*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i+1].Kind != yaml.MappingNode {
+			return "", false
+		}
+		values = append(values, node.Content[i+1])
+	}
+
+	return friendlyNameField(values, ""), true
+}
+
+func friendlyNameField(items []*yaml.Node, fallback string) string {
+	for _, field := range []string{"alias", "name"} {
+		for _, item := range items {
+			if _, ok := types.GetYamlNodeValueOk(item, field); ok {
+				return field
+			}
+		}
+	}
+	return fallback
+}
+
+func discoverStorage(rootPath string) (*types.DiscoveredConfig, error) {
+	directory := ".storage"
+	entries, err := readDirIfExists(filepath.Join(rootPath, directory))
+	if err != nil || entries == nil {
+		return nil, err
+	}
+
+	options := types.NewDirectoryConfigBackupOptions(directory, nil, discoveryStorageExcludes)
+	proposal := &types.DiscoveredConfig{
+		Kind:  types.DiscoveryKindStorage,
+		Group: types.NewConfigBackupOptionGroup("Storage", []*types.ConfigBackupOptions{options}),
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		excluded, err := isFileExcluded(options, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if excluded {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		proposal.AddFile(filepath.Join(directory, entry.Name()), info.Size())
+	}
+
+	if proposal.FileCount == 0 {
+		return nil, nil
+	}
+	return proposal, nil
+}
+
+func discoverESPHome(rootPath string) (*types.DiscoveredConfig, error) {
+	directory := "esphome"
+	entries, err := readDirIfExists(filepath.Join(rootPath, directory))
+	if err != nil || entries == nil {
+		return nil, err
+	}
+
+	excludes := []string{"secrets.yaml"}
+	proposal := &types.DiscoveredConfig{Kind: types.DiscoveryKindESPHome}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isYamlFilename(entry.Name()) || entry.Name() == "secrets.yaml" {
+			continue
+		}
+
+		filePath := filepath.Join(rootPath, directory, entry.Name())
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			continue
+		}
+
+		var rootNode yaml.Node
+		isDevice := yaml.Unmarshal(data, &rootNode) == nil &&
+			len(rootNode.Content) > 0 &&
+			rootNode.Content[0].Kind == yaml.MappingNode
+		if isDevice {
+			_, isDevice = types.GetYamlNodeValueOk(rootNode.Content[0], "esphome")
+		}
+
+		if !isDevice {
+			excludes = append(excludes, entry.Name())
+			continue
+		}
+		proposal.AddFile(filepath.Join(directory, entry.Name()), int64(len(data)))
+	}
+
+	if proposal.FileCount == 0 {
+		return nil, nil
+	}
+
+	proposal.Group = types.NewConfigBackupOptionGroup("ESP Home", []*types.ConfigBackupOptions{
+		types.NewDirectoryConfigBackupOptions(directory, []string{"*.yaml"}, excludes),
+	})
+	return proposal, nil
+}
+
+func discoverBlueprints(rootPath string) (*types.DiscoveredConfig, error) {
+	directory := "blueprints"
+	if !DirectoryExists(filepath.Join(rootPath, directory)) {
+		return nil, nil
+	}
+
+	options := types.NewBlueprintConfigBackupOptions(directory)
+	blueprints, err := ReadBlueprintsFromDirectory(rootPath, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan blueprints: %w", err)
+	}
+	if len(blueprints) == 0 {
+		return nil, nil
+	}
+
+	proposal := &types.DiscoveredConfig{
+		Kind:  types.DiscoveryKindBlueprint,
+		Group: types.NewConfigBackupOptionGroup("Blueprints", []*types.ConfigBackupOptions{options}),
+	}
+	for _, blueprint := range blueprints {
+		proposal.AddFile(filepath.Join(directory, types.BlueprintRelativePath(blueprint.ID)), int64(len(blueprint.Blob)))
+	}
+	return proposal, nil
+}
+
+func discoverNodeRED(rootPath string) (*types.DiscoveredConfig, error) {
+	proposal := &types.DiscoveredConfig{Kind: types.DiscoveryKindNodeRED}
+	configs := []*types.ConfigBackupOptions{}
+
+	for _, directory := range []string{".", "node-red", "nodered"} {
+		entries, err := readDirIfExists(filepath.Join(rootPath, directory))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			isFlowsFile := strings.HasPrefix(name, "flows") &&
+				filepath.Ext(name) == ".json" &&
+				!strings.Contains(name, "_cred")
+			if entry.IsDir() || !isFlowsFile {
+				continue
+			}
+
+			relativePath := filepath.Join(directory, name)
+			data, err := os.ReadFile(filepath.Join(rootPath, relativePath))
+			if err != nil || !isNodeREDFlows(data) {
+				continue
+			}
+
+			configs = append(configs, types.NewNodeREDConfigBackupOptions(relativePath))
+			proposal.AddFile(relativePath, int64(len(data)))
+		}
+	}
+
+	if proposal.FileCount == 0 {
+		return nil, nil
+	}
+
+	proposal.Group = types.NewConfigBackupOptionGroup("Node-RED", configs)
+	return proposal, nil
+}
+
+// isNodeREDFlows reports whether data looks like a Node-RED flows file: a JSON
+// array of objects that each carry an id and a type.
+func isNodeREDFlows(data []byte) bool {
+	var nodes []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &nodes); err != nil || len(nodes) == 0 {
+		return false
+	}
+	for _, node := range nodes {
+		if node.ID == "" || node.Type == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func readDirIfExists(path string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+func isYamlFilename(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// titleFromFilename turns "input_booleans.yaml" into "Input Booleans".
+func titleFromFilename(filename string) string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	words := strings.FieldsFunc(base, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	})
+	if len(words) == 0 {
+		return filename
+	}
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,98 @@
+package io_test
+
+import (
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_DiscoverConfigs(t *testing.T) {
+	proposals, err := io.DiscoverConfigs("test-data/discover")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	byName := map[string]*types.DiscoveredConfig{}
+	for _, proposal := range proposals {
+		byName[proposal.Group.Name] = proposal
+	}
+
+	t.Run("Proposes every trackable file and skips secrets", func(t *testing.T) {
+		names := []string{}
+		for _, proposal := range proposals {
+			names = append(names, proposal.Group.Name)
+		}
+
+		expected := []string{"Automations", "Scripts", "Configuration", "Storage", "ESP Home", "Blueprints", "Node-RED"}
+		if diff := cmp.Diff(expected, names); diff != "" {
+			t.Errorf("Proposed groups do not match expected:\n%s", diff)
+		}
+	})
+
+	t.Run("Classifies a sequence with ids as multiple", func(t *testing.T) {
+		proposal := byName["Automations"]
+		config := proposal.Group.Configs[0]
+		if proposal.Kind != types.DiscoveryKindMultiple || config.BackupType != "multiple" {
+			t.Fatalf("Expected multiple, got kind %s and backup type %s", proposal.Kind, config.BackupType)
+		}
+		if *config.IdNode != "id" || *config.FriendlyNameNode != "alias" {
+			t.Errorf("Expected id/alias nodes, got %s/%s", *config.IdNode, *config.FriendlyNameNode)
+		}
+		if proposal.FileCount != 1 || proposal.TotalSize == 0 {
+			t.Errorf("Expected one non-empty file, got %d files of %d bytes", proposal.FileCount, proposal.TotalSize)
+		}
+	})
+
+	t.Run("Classifies a mapping of objects as keyed", func(t *testing.T) {
+		proposal := byName["Scripts"]
+		config := proposal.Group.Configs[0]
+		if proposal.Kind != types.DiscoveryKindKeyed || config.BackupType != "keyed" {
+			t.Fatalf("Expected keyed, got kind %s and backup type %s", proposal.Kind, config.BackupType)
+		}
+		if config.FriendlyNameNode == nil || *config.FriendlyNameNode != "alias" {
+			t.Errorf("Expected alias friendly name node, got %v", config.FriendlyNameNode)
+		}
+	})
+
+	t.Run("Classifies other YAML as single", func(t *testing.T) {
+		if config := byName["Configuration"].Group.Configs[0]; config.BackupType != "single" {
+			t.Errorf("Expected single, got %s", config.BackupType)
+		}
+	})
+
+	t.Run("Excludes volatile storage files", func(t *testing.T) {
+		expected := []string{".storage/core.config", ".storage/lovelace"}
+		if diff := cmp.Diff(expected, byName["Storage"].Files); diff != "" {
+			t.Errorf("Storage files do not match expected:\n%s", diff)
+		}
+	})
+
+	t.Run("Only counts ESPHome device configs", func(t *testing.T) {
+		proposal := byName["ESP Home"]
+		if diff := cmp.Diff([]string{"esphome/garage.yaml"}, proposal.Files); diff != "" {
+			t.Errorf("ESPHome files do not match expected:\n%s", diff)
+		}
+		expectedExcludes := []string{"secrets.yaml", "common.yaml"}
+		if diff := cmp.Diff(expectedExcludes, proposal.Group.Configs[0].ExcludeFilePatterns); diff != "" {
+			t.Errorf("ESPHome excludes do not match expected:\n%s", diff)
+		}
+	})
+
+	t.Run("Proposes the blueprints folder", func(t *testing.T) {
+		configs := byName["Blueprints"].Group.Configs
+		if len(configs) != 1 || configs[0].Path != "blueprints" || configs[0].BackupType != "blueprint" {
+			t.Errorf("Unexpected blueprint configs: %+v", configs[0])
+		}
+	})
+
+	t.Run("Finds Node-RED flows but not credentials", func(t *testing.T) {
+		if diff := cmp.Diff([]string{"node-red/flows.json"}, byName["Node-RED"].Files); diff != "" {
+			t.Errorf("Node-RED files do not match expected:\n%s", diff)
+		}
+		if config := byName["Node-RED"].Group.Configs[0]; config.BackupType != "nodered" {
+			t.Errorf("Expected nodered, got %s", config.BackupType)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package io_test
+
+import (
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func saveTestBackup(t *testing.T, backupDir, id, content string, modified time.Time) {
+	t.Helper()
+
+	configBackup := &types.ConfigBackup{
+		ConfigBackupIdentifier: types.ConfigBackupIdentifier{Path: "automations.yaml", ID: id},
+		ModifiedDate:           modified,
+		Blob:                   []byte(content),
+	}
+	if err := io.SaveConfigBackup(backupDir, "automations", configBackup); err != nil {
+		t.Fatalf("Failed to save backup: %v", err)
+	}
+}
+
+func Test_ConfigBackupHistory(t *testing.T) {
+	backupDir := t.TempDir()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	saveTestBackup(t, backupDir, "old_id", "alias: first", start)
+	saveTestBackup(t, backupDir, "old_id", "alias: second", start.Add(time.Hour))
+	saveTestBackup(t, backupDir, "new_id", "alias: third", start.Add(2*time.Hour))
+
+	t.Run("Lists backups from previous ids newest first", func(t *testing.T) {
+		backups, err := io.ListConfigBackupHistory(backupDir, "automations", "automations.yaml", "new_id", []string{"old_id"})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(backups) != 3 {
+			t.Fatalf("Expected 3 backups, got %d", len(backups))
+		}
+
+		expectedIds := []string{"new_id", "old_id", "old_id"}
+		for i, backup := range backups {
+			if backup.ID != expectedIds[i] {
+				t.Errorf("Expected backup %d to be stored under %s, got %s", i, expectedIds[i], backup.ID)
+			}
+		}
+	})
+
+	t.Run("Finds a backup stored under a previous id", func(t *testing.T) {
+		content, id, err := io.GetConfigBackupFromHistory(backupDir, "automations", "automations.yaml",
+			[]string{"new_id", "old_id"}, "20250101T120000.backup")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if id != "old_id" || string(content) != "alias: first" {
+			t.Errorf("Expected first backup under old_id, got %q under %s", content, id)
+		}
+	})
+
+	t.Run("Returns the latest backup for an id", func(t *testing.T) {
+		content, err := io.GetLatestConfigBackup(backupDir, "automations", "automations.yaml", "old_id")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if string(content) != "alias: second" {
+			t.Errorf("Expected latest backup content, got %q", content)
+		}
+	})
+}
+
+func Test_VersionAttribution(t *testing.T) {
+	backupDir := t.TempDir()
+	start := time.Now().UTC().AddDate(0, 0, -10).Truncate(time.Second)
+	saveTestBackup(t, backupDir, "porch", "alias: first", start)
+	saveTestBackup(t, backupDir, "porch", "alias: second", start.Add(time.Hour))
+
+	first := io.BackupFilename(start)
+	attribution := &types.VersionAttribution{Source: types.ChangeSourceUser, User: "Alex", UserID: "abc123", Detail: "automation.reload"}
+	if err := io.SaveVersionAttribution(backupDir, "automations", "automations.yaml", "porch", first, attribution); err != nil {
+		t.Fatalf("Failed to save attribution: %v", err)
+	}
+	if err := io.SaveVersionAttribution(backupDir, "automations", "automations.yaml", "porch", "20000101T000000.backup", attribution); err == nil {
+		t.Error("Expected an error attributing a backup that does not exist")
+	}
+
+	backups, err := io.ListConfigBackups(backupDir, "automations", "automations.yaml", "porch")
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %v (%v)", backups, err)
+	}
+	if backups[0].Attribution != nil {
+		t.Errorf("Expected the second backup to have no attribution, got %+v", backups[0].Attribution)
+	}
+	if backups[1].Attribution == nil || *backups[1].Attribution != *attribution {
+		t.Errorf("Expected the first backup to be attributed to Alex, got %+v", backups[1].Attribution)
+	}
+
+	// The attribution is not counted as a backup and is removed with its backup
+	latest := &types.ConfigBackup{ConfigBackupIdentifier: types.ConfigBackupIdentifier{Path: "automations.yaml", ID: "porch"}}
+	metadata, _, err := io.CleanupAndUpdateMetadata("automations", latest, &types.ConfigBackupOptions{}, backupDir, nil, nil)
+	if err != nil || metadata.BackupCount != 2 {
+		t.Fatalf("Expected 2 backups to be counted, got %+v (%v)", metadata, err)
+	}
+	if !io.RemoveBackup(filepath.Join(backupDir, "automations", "automations.yaml", "porch"), first, "test") {
+		t.Fatal("Expected the first backup to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "automations", "automations.yaml", "porch", strings.TrimSuffix(first, ".backup")+".meta.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected the attribution to be removed with its backup, got %v", err)
+	}
+}
+
+func Test_UpdateMetadataAfterDeletion(t *testing.T) {
+	backupDir := t.TempDir()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	saveTestBackup(t, backupDir, "porch", "alias: first", start)
+	saveTestBackup(t, backupDir, "porch", "alias: second", start.Add(time.Hour))
+
+	latest := &types.ConfigBackup{ConfigBackupIdentifier: types.ConfigBackupIdentifier{Path: "automations.yaml", ID: "porch"}, FriendlyName: "Porch"}
+	if _, _, err := io.CleanupAndUpdateMetadata("automations", latest, &types.ConfigBackupOptions{}, backupDir, nil, nil); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+
+	// The metadata is read from the config's folder, not from a path nested inside it
+	if err := io.DeleteBackup(backupDir, "automations", "automations.yaml", "porch", io.BackupFilename(start)); err != nil {
+		t.Fatalf("Failed to delete backup: %v", err)
+	}
+	metadata, err := io.UpdateMetadataAfterDeletion(backupDir, "automations", "automations.yaml", "porch")
+	if err != nil || metadata == nil || metadata.BackupCount != 1 || metadata.FriendlyName != "Porch" {
+		t.Fatalf("Expected the metadata to count one backup, got %+v (%v)", metadata, err)
+	}
+
+	if err := io.DeleteBackup(backupDir, "automations", "automations.yaml", "porch", io.BackupFilename(start.Add(time.Hour))); err != nil {
+		t.Fatalf("Failed to delete backup: %v", err)
+	}
+	metadata, err = io.UpdateMetadataAfterDeletion(backupDir, "automations", "automations.yaml", "porch")
+	if err != nil || metadata != nil {
+		t.Errorf("Expected no metadata once every backup is deleted, got %+v (%v)", metadata, err)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "automations", "automations.yaml", "porch")); !os.IsNotExist(err) {
+		t.Errorf("Expected the config's folder to be removed, got %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package io
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"ha-config-history/internal/types"
+	goio "io"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -60,15 +63,27 @@ func ReadMultipleConfigsFromSingleFile(rootPath string, config *types.ConfigBack
 	}
 
 	contentNode := rootNode.Content[0]
+	identities := types.ResolveEntryIdentities(contentNode.Content, config)
 
-	for _, yamlNode := range contentNode.Content {
-		configBackup, err := types.NewYamlConfigBackup(config.Path, filePath, yamlNode, config, currentTime)
+	missingIds := 0
+	for index, yamlNode := range contentNode.Content {
+		configBackup, err := types.NewYamlConfigBackup(config.Path, filePath, yamlNode, identities[index], config, currentTime)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create config backup for %s: %w", filePath, err)
 		}
+		if configBackup.MissingId {
+			missingIds++
+		}
 		configBackups = append(configBackups, configBackup)
 	}
 
+	if missingIds > 0 {
+		slog.Warn("Entries without an id found, using fallback ids",
+			"file", filePath,
+			"count", missingIds,
+		)
+	}
+
 	return configBackups, nil
 }
 
@@ -115,6 +130,79 @@ func ReadKeyedConfigsFromSingleFile(rootPath string, config *types.ConfigBackupO
 	return configBackups, nil
 }
 
+// ReadMultipleDocumentsFromSingleFile reads a multi-document YAML file, treating each
+// "---" separated document as an independently tracked config.
+func ReadMultipleDocumentsFromSingleFile(rootPath string, config *types.ConfigBackupOptions) ([]*types.ConfigBackup, error) {
+	currentTime := time.Now().UTC()
+	filePath := rootPath + "/" + config.Path
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	documents, err := decodeYamlDocuments(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YAML in %s: %w", filePath, err)
+	}
+
+	ids := documentIds(documents, config)
+	configBackups := []*types.ConfigBackup{}
+	for index, document := range documents {
+		if isEmptyYamlDocument(document) {
+			continue
+		}
+
+		configBackup, err := types.NewDocumentConfigBackup(filePath, index, ids[index], document.Content[0], config, currentTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create config backup for %s: %w", filePath, err)
+		}
+		configBackups = append(configBackups, configBackup)
+	}
+
+	return configBackups, nil
+}
+
+// decodeYamlDocuments decodes every document in a YAML stream, keeping empty documents so
+// that positions match the file.
+func decodeYamlDocuments(data []byte) ([]*yaml.Node, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	documents := []*yaml.Node{}
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, goio.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, &document)
+	}
+
+	return documents, nil
+}
+
+// documentIds resolves the id of every non-empty document in a YAML stream.
+func documentIds(documents []*yaml.Node, config *types.ConfigBackupOptions) []string {
+	contentNodes := make([]*yaml.Node, len(documents))
+	for index, document := range documents {
+		if !isEmptyYamlDocument(document) {
+			contentNodes[index] = document.Content[0]
+		}
+	}
+	return types.ResolveDocumentIds(contentNodes, config)
+}
+
+func isEmptyYamlDocument(document *yaml.Node) bool {
+	if len(document.Content) == 0 {
+		return true
+	}
+	contentNode := document.Content[0]
+	return contentNode.Kind == yaml.ScalarNode && contentNode.Tag == "!!null"
+}
+
 func ReadSingleConfigFromSingleFile(rootPath string, config *types.ConfigBackupOptions) (*types.ConfigBackup, error) {
 	return ReadSingleConfigFromSingleFilename(rootPath, config.Path, config)
 }
@@ -148,17 +236,11 @@ func ReadMultipleConfigsFromDirectory(rootPath string, config *types.ConfigBacku
 			continue
 		}
 
-		included, err := isFileIncluded(config, file)
+		included, err := DirectoryIncludesFile(config, file.Name())
 		if err != nil {
 			return nil, err
 		}
-
-		excluded, err := isFileExcluded(config, file)
-		if err != nil {
-			return nil, err
-		}
-
-		if !included || excluded {
+		if !included {
 			continue
 		}
 
@@ -172,12 +254,27 @@ func ReadMultipleConfigsFromDirectory(rootPath string, config *types.ConfigBacku
 	return configBackups, nil
 }
 
-func isFileIncluded(config *types.ConfigBackupOptions, file os.DirEntry) (bool, error) {
+// DirectoryIncludesFile reports whether a file in a directory config's folder matches its
+// include patterns and none of its exclude patterns.
+func DirectoryIncludesFile(config *types.ConfigBackupOptions, filename string) (bool, error) {
+	included, err := isFileIncluded(config, filename)
+	if err != nil || !included {
+		return false, err
+	}
+
+	excluded, err := isFileExcluded(config, filename)
+	if err != nil {
+		return false, err
+	}
+	return !excluded, nil
+}
+
+func isFileIncluded(config *types.ConfigBackupOptions, filename string) (bool, error) {
 	included := true
 	if len(config.IncludeFilePatterns) > 0 {
 		matched := false
 		for _, pattern := range config.IncludeFilePatterns {
-			match, err := filepath.Match(pattern, file.Name())
+			match, err := filepath.Match(pattern, filename)
 			if err != nil {
 				return false, fmt.Errorf("invalid include pattern %s: %w", pattern, err)
 			}
@@ -194,11 +291,11 @@ func isFileIncluded(config *types.ConfigBackupOptions, file os.DirEntry) (bool,
 	return included, nil
 }
 
-func isFileExcluded(config *types.ConfigBackupOptions, file os.DirEntry) (bool, error) {
+func isFileExcluded(config *types.ConfigBackupOptions, filename string) (bool, error) {
 	excluded := false
 	if len(config.ExcludeFilePatterns) > 0 {
 		for _, pattern := range config.ExcludeFilePatterns {
-			match, err := filepath.Match(pattern, file.Name())
+			match, err := filepath.Match(pattern, filename)
 			if err != nil {
 				return false, fmt.Errorf("invalid exclude pattern %s: %w", pattern, err)
 			}
@@ -228,7 +325,8 @@ func LoadAllBackupConfigSummaries(backupFolder string) (map[types.GroupSlug]type
 	metadataMap := map[types.GroupSlug]types.BackupConfigSummaryMap{}
 
 	for _, group := range groups {
-		if group.IsDir() {
+		// Folders starting with a dot hold the add-on's own state, such as the job journal
+		if group.IsDir() && !strings.HasPrefix(group.Name(), ".") {
 			groupSlug := types.GroupSlug(group.Name()) // folder name is slug
 			groupPath := filepath.Join(backupFolder, string(groupSlug))
 			paths, err := os.ReadDir(groupPath)
@@ -277,6 +375,12 @@ func LoadAllBackupConfigSummaries(backupFolder string) (map[types.GroupSlug]type
 	return metadataMap, nil
 }
 
+// BackupFilename returns the name a backup of content modified at the given time is
+// stored under.
+func BackupFilename(modified time.Time) string {
+	return fmt.Sprintf("%s.backup", modified.Format("20060102T150405"))
+}
+
 func SaveConfigBackup(backupFolder string, groupSlug types.GroupSlug, configBackup *types.ConfigBackup) error {
 	backupDir, err := createConfigDirectory(backupFolder, groupSlug, configBackup.Path, configBackup.ID)
 	if err != nil {
@@ -290,7 +394,7 @@ func SaveConfigBackup(backupFolder string, groupSlug types.GroupSlug, configBack
 		}
 	}
 
-	backupPath := filepath.Join(backupDir, fmt.Sprintf("%s.backup", configBackup.ModifiedDate.Format("20060102T150405")))
+	backupPath := filepath.Join(backupDir, BackupFilename(configBackup.ModifiedDate))
 	err = os.WriteFile(backupPath, configBackup.Blob, 0644)
 
 	if err != nil {
@@ -305,6 +409,9 @@ func SaveConfigBackup(backupFolder string, groupSlug types.GroupSlug, configBack
 	return nil
 }
 
+// CleanupAndUpdateMetadata removes the config's backups that are beyond its retention
+// limits and rewrites its metadata. It returns the metadata and the number of backups
+// that were removed.
 func CleanupAndUpdateMetadata(
 	groupSlug types.GroupSlug,
 	configBackup *types.ConfigBackup,
@@ -312,15 +419,15 @@ func CleanupAndUpdateMetadata(
 	backupDirectory string,
 	defaultMaxBackups *int,
 	defaultMaxBackupAgeDays *int,
-) (*types.BackupConfigSummary, error) {
+) (*types.BackupConfigSummary, int, error) {
 	configDir, err := createConfigDirectory(backupDirectory, (groupSlug), configBackup.Path, configBackup.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
+		return nil, 0, fmt.Errorf("failed to create config directory: %w", err)
 	}
 
 	backupsCount, backupsSize, err := dirMetrics(configDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get directory metrics for %s: %w", backupDirectory, err)
+		return nil, 0, fmt.Errorf("failed to get directory metrics for %s: %w", backupDirectory, err)
 	}
 
 	// Determine effective MaxBackupAgeDays: prefer option value, fall back to default
@@ -340,10 +447,11 @@ func CleanupAndUpdateMetadata(
 		effectiveMaxBackups = defaultMaxBackups
 	}
 
+	removed := 0
 	if effectiveMaxBackups != nil {
 		entries, err := os.ReadDir(backupDirectory)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read backup directory %s: %w", backupDirectory, err)
+			return nil, 0, fmt.Errorf("failed to read backup directory %s: %w", backupDirectory, err)
 		}
 
 		filenames := []string{}
@@ -360,7 +468,9 @@ func CleanupAndUpdateMetadata(
 		for _, filename := range filenames {
 			currentBackupCount++
 			if currentBackupCount > *effectiveMaxBackups {
-				RemoveBackup(backupDirectory, filename, "exceeded max backups limit")
+				if RemoveBackup(backupDirectory, filename, "exceeded max backups limit") {
+					removed++
+				}
 				continue
 			}
 
@@ -370,7 +480,9 @@ func CleanupAndUpdateMetadata(
 				continue
 			}
 			if backupDate.Before(oldestBackupTimeAllowed) {
-				RemoveBackup(backupDirectory, filename, "older than max backup age")
+				if RemoveBackup(backupDirectory, filename, "older than max backup age") {
+					removed++
+				}
 			}
 		}
 	}
@@ -379,20 +491,23 @@ func CleanupAndUpdateMetadata(
 	metadata := types.NewConfigBackupSummary(configBackup, backupsCount, backupsSize, backupOptions.BackupType)
 	metadataBlob, err := json.Marshal(metadata)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, removed, fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	return metadata, os.WriteFile(metadataPath, metadataBlob, 0644)
+	return metadata, removed, os.WriteFile(metadataPath, metadataBlob, 0644)
 }
 
-func RemoveBackup(backupDirectory, filename, reason string) {
+// RemoveBackup removes an old backup and reports whether it was removed.
+func RemoveBackup(backupDirectory, filename, reason string) bool {
 	backupPath := filepath.Join(backupDirectory, filename)
 	err := os.Remove(backupPath)
 	if err != nil {
 		slog.Error("Failed to remove old backup", "file", backupPath, "error", err, "reason", reason)
-	} else {
-		slog.Info("Removed old backup due to max backups limit", "file", backupPath, "reason", reason)
+		return false
 	}
+	removeVersionAttribution(backupDirectory, filename)
+	slog.Info("Removed old backup", "file", backupPath, "reason", reason)
+	return true
 }
 
 func dirMetrics(path string) (int, int64, error) {
@@ -402,7 +517,7 @@ func dirMetrics(path string) (int, int64, error) {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() && info.Name() != "metadata.json" {
+		if !info.IsDir() && info.Name() != "metadata.json" && !isAttributionFile(info.Name()) {
 			size += info.Size()
 			count++
 		}
@@ -430,9 +545,72 @@ func GetConfigBackup(backupFolder string, groupSlug types.GroupSlug, configPath,
 }
 
 type BackupInfo struct {
-	Filename string    `json:"filename"`
-	Date     time.Time `json:"date"`
-	Size     int64     `json:"size"`
+	Filename    string                    `json:"filename"`
+	Date        time.Time                 `json:"date"`
+	Size        int64                     `json:"size"`
+	ID          string                    `json:"id,omitempty"`          // Config id the backup is stored under, set when listing history across renames
+	Attribution *types.VersionAttribution `json:"attribution,omitempty"` // Who made the change, when it could be looked up
+}
+
+// ListConfigBackupHistory lists the backups for a config together with the backups
+// stored under the ids it was previously known as, newest first.
+func ListConfigBackupHistory(backupFolder string, groupSlug types.GroupSlug, configPath, configID string, previousIds []string) ([]BackupInfo, error) {
+	backups, err := ListConfigBackups(backupFolder, groupSlug, configPath, configID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range backups {
+		backups[i].ID = configID
+	}
+
+	for _, previousId := range previousIds {
+		previousBackups, err := ListConfigBackups(backupFolder, groupSlug, configPath, previousId)
+		if err != nil {
+			slog.Warn("Failed to list backups for previous id", "id", previousId, "error", err)
+			continue
+		}
+		for i := range previousBackups {
+			previousBackups[i].ID = previousId
+		}
+		backups = append(backups, previousBackups...)
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Date.After(backups[j].Date)
+	})
+
+	return backups, nil
+}
+
+// GetConfigBackupFromHistory finds a backup by filename under the first of ids that has
+// it, returning its content and the id it was found under.
+func GetConfigBackupFromHistory(backupFolder string, groupSlug types.GroupSlug, configPath string, ids []string, filename string) ([]byte, string, error) {
+	var firstErr error
+	for _, id := range ids {
+		content, err := GetConfigBackup(backupFolder, groupSlug, configPath, id, filename)
+		if err == nil {
+			return content, id, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("backup file not found: %s", filename)
+	}
+	return nil, "", firstErr
+}
+
+// GetLatestConfigBackup returns the content of the most recent backup of a config.
+func GetLatestConfigBackup(backupFolder string, groupSlug types.GroupSlug, configPath, id string) ([]byte, error) {
+	backups, err := ListConfigBackups(backupFolder, groupSlug, configPath, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("no backups found for config: %s", id)
+	}
+	return GetConfigBackup(backupFolder, groupSlug, configPath, id, backups[0].Filename)
 }
 
 func ListConfigBackups(backupFolder string, groupSlug types.GroupSlug, configPath, configID string) ([]BackupInfo, error) {
@@ -471,9 +649,10 @@ func ListConfigBackups(backupFolder string, groupSlug types.GroupSlug, configPat
 			}
 
 			backups = append(backups, BackupInfo{
-				Filename: entry.Name(),
-				Date:     date,
-				Size:     info.Size(),
+				Filename:    entry.Name(),
+				Date:        date,
+				Size:        info.Size(),
+				Attribution: readVersionAttribution(configFolder, entry.Name()),
 			})
 		}
 	}
@@ -501,12 +680,16 @@ func RestoreEntireFile(filepath string, blob []byte) error {
 	return nil
 }
 
-func RestorePartialFile(filepath string, blobToRestore []byte, options types.ConfigBackupOptions) error {
+// RestorePartialFile restores a single entry into a multiple (sequence-rooted) file,
+// replacing the entry with the given id or appending it when missing.
+func RestorePartialFile(filepath string, id string, blobToRestore []byte, options types.ConfigBackupOptions) error {
 	var dataToRestore yaml.Node
 	if err := yaml.Unmarshal(blobToRestore, &dataToRestore); err != nil {
 		return fmt.Errorf("failed to parse backup YAML: %w", err)
 	}
-	nodeIdToRestore := types.GetYamlNodeValue(dataToRestore.Content[0], *options.IdNode)
+	if len(dataToRestore.Content) == 0 {
+		return fmt.Errorf("backup content is empty")
+	}
 
 	currentData, err := os.ReadFile(filepath)
 	if err != nil {
@@ -524,9 +707,9 @@ func RestorePartialFile(filepath string, blobToRestore []byte, options types.Con
 
 	updated := false
 	contentNode := rootNode.Content[0]
-	for _, yamlNode := range contentNode.Content {
-		existingNodeId := types.GetYamlNodeValue(yamlNode, *options.IdNode)
-		if existingNodeId == nodeIdToRestore {
+	identities := types.ResolveEntryIdentities(contentNode.Content, &options)
+	for index, yamlNode := range contentNode.Content {
+		if identities[index].ID == id {
 			*yamlNode = *dataToRestore.Content[0]
 			updated = true
 			break
@@ -630,6 +813,69 @@ func RestoreKeyedPartialFile(filepath string, key string, blobToRestore []byte,
 	return nil
 }
 
+// RestoreDocumentPartialFile restores a single document into a multi-document YAML file,
+// replacing the document with the matching id in place or appending it when missing.
+func RestoreDocumentPartialFile(filepath string, id string, blobToRestore []byte, options types.ConfigBackupOptions) error {
+	var dataToRestore yaml.Node
+	if err := yaml.Unmarshal(blobToRestore, &dataToRestore); err != nil {
+		return fmt.Errorf("failed to parse backup YAML: %w", err)
+	}
+	if len(dataToRestore.Content) == 0 {
+		return fmt.Errorf("backup content is empty")
+	}
+	contentToRestore := dataToRestore.Content[0]
+
+	currentData, err := os.ReadFile(filepath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing config file %s: %w", filepath, err)
+	}
+
+	documents, err := decodeYamlDocuments(currentData)
+	if err != nil {
+		return fmt.Errorf("failed to parse existing YAML in %s: %w", filepath, err)
+	}
+
+	ids := documentIds(documents, &options)
+	updated := false
+	for index, document := range documents {
+		if isEmptyYamlDocument(document) {
+			continue
+		}
+		if ids[index] == id {
+			document.Content[0] = contentToRestore
+			updated = true
+			break
+		}
+	}
+
+	if !updated {
+		documents = append(documents, &yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{contentToRestore},
+		})
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	for _, document := range documents {
+		if len(document.Content) == 0 {
+			document.Content = []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!null"}}
+		}
+		if err := encoder.Encode(document); err != nil {
+			return fmt.Errorf("failed to serialize updated YAML: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to serialize updated YAML: %w", err)
+	}
+
+	if err := os.WriteFile(filepath, buffer.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write updated config file %s: %w", filepath, err)
+	}
+
+	return nil
+}
+
 // DeleteBackup deletes a single backup file and returns an error if it fails
 func DeleteBackup(backupFolder string, groupSlug types.GroupSlug, configPath, id, filename string) error {
 	backupPath, err := createBackupPath(backupFolder, groupSlug, configPath, id, filename)
@@ -646,6 +892,7 @@ func DeleteBackup(backupFolder string, groupSlug types.GroupSlug, configPath, id
 	if err := os.Remove(backupPath); err != nil {
 		return fmt.Errorf("failed to delete backup file: %w", err)
 	}
+	removeVersionAttribution(filepath.Dir(backupPath), filename)
 
 	slog.Info("Backup deleted", "file", backupPath)
 	return nil
@@ -667,7 +914,7 @@ func UpdateMetadataAfterDeletion(backupFolder string, groupSlug types.GroupSlug,
 
 	// If no backups remain, delete metadata file and directory
 	if backupsCount == 0 {
-		metadataPath := createMetadataPath(backupDirectory, groupSlug, configPath, id)
+		metadataPath := createMetadataPath(backupFolder, groupSlug, configPath, id)
 		if err := os.Remove(metadataPath); err != nil && !os.IsNotExist(err) {
 			slog.Warn("Failed to remove metadata file", "path", metadataPath, "error", err)
 		}
@@ -680,8 +927,43 @@ func UpdateMetadataAfterDeletion(backupFolder string, groupSlug types.GroupSlug,
 		return nil, nil
 	}
 
-	// Read existing metadata to preserve other fields
-	metadataPath := createMetadataPath(backupDirectory, groupSlug, configPath, id)
+	// Update counts, preserving the other metadata fields
+	return updateMetadata(backupFolder, groupSlug, configPath, id, func(metadata *types.BackupConfigSummary) {
+		metadata.BackupCount = backupsCount
+		metadata.BackupsSize = backupsSize
+	})
+}
+
+// MarkConfigRenamed records in a config's metadata that its history continues under a new id.
+func MarkConfigRenamed(backupFolder string, groupSlug types.GroupSlug, configPath, id, renamedTo string) (*types.BackupConfigSummary, error) {
+	return updateMetadata(backupFolder, groupSlug, configPath, id, func(metadata *types.BackupConfigSummary) {
+		metadata.RenamedTo = renamedTo
+	})
+}
+
+// MarkConfigDeleted records that a config was removed from its file at deletedAt.
+func MarkConfigDeleted(backupFolder string, groupSlug types.GroupSlug, configPath, id string, deletedAt time.Time) (*types.BackupConfigSummary, error) {
+	return updateMetadata(backupFolder, groupSlug, configPath, id, func(metadata *types.BackupConfigSummary) {
+		metadata.Deleted = true
+		metadata.DeletedAt = &deletedAt
+	})
+}
+
+// ClearConfigDeleted removes the deletion record from a config that is back in its file.
+func ClearConfigDeleted(backupFolder string, groupSlug types.GroupSlug, configPath, id string) (*types.BackupConfigSummary, error) {
+	return updateMetadata(backupFolder, groupSlug, configPath, id, func(metadata *types.BackupConfigSummary) {
+		metadata.Deleted = false
+		metadata.DeletedAt = nil
+	})
+}
+
+// updateMetadata applies update to a config's stored metadata and writes it back.
+func updateMetadata(backupFolder string, groupSlug types.GroupSlug, configPath, id string, update func(*types.BackupConfigSummary)) (*types.BackupConfigSummary, error) {
+	if _, err := createConfigDirectory(backupFolder, groupSlug, configPath, id); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	metadataPath := createMetadataPath(backupFolder, groupSlug, configPath, id)
 	metadataBlob, err := os.ReadFile(metadataPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read metadata file %s: %w", metadataPath, err)
@@ -692,11 +974,8 @@ func UpdateMetadataAfterDeletion(backupFolder string, groupSlug types.GroupSlug,
 		return nil, fmt.Errorf("failed to parse metadata JSON in %s: %w", metadataPath, err)
 	}
 
-	// Update counts
-	metadata.BackupCount = backupsCount
-	metadata.BackupsSize = backupsSize
+	update(&metadata)
 
-	// Write updated metadata
 	updatedMetadataBlob, err := json.Marshal(metadata)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
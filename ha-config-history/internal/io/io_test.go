@@ -390,3 +390,30 @@ func TestSanitizePath(t *testing.T) {
 		}
 	})
 }
+
+func Test_ReadMultipleConfigsFromSingleFileWithoutIds(t *testing.T) {
+	t.Run("Entries without ids get distinct fallback ids", func(t *testing.T) {
+		strategy := types.MissingIdStrategySlug
+		options := types.NewMultipleConfigBackupOptions("sample-multi-missing-id.yaml", "id", "alias")
+		options.MissingIdStrategy = &strategy
+
+		configBackups, err := io.ReadMultipleConfigsFromSingleFile("test-data", options)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		expected := []types.ConfigBackupIdentifier{
+			{ID: "with-id", Path: "sample-multi-missing-id.yaml"},
+			{ID: "slug-morning_lights", Path: "sample-multi-missing-id.yaml"},
+			{ID: "slug-morning_lights-2", Path: "sample-multi-missing-id.yaml"},
+		}
+		for i, configBackup := range configBackups {
+			if configBackup.ConfigBackupIdentifier != expected[i] {
+				t.Errorf("Backup %d: expected %+v, got %+v", i, expected[i], configBackup.ConfigBackupIdentifier)
+			}
+			if configBackup.MissingId != (i > 0) {
+				t.Errorf("Backup %d: unexpected missingId %v", i, configBackup.MissingId)
+			}
+		}
+	})
+}
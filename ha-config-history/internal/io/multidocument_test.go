@@ -0,0 +1,57 @@
+package io_test
+
+import (
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"testing"
+)
+
+func Test_ReadMultipleDocumentsFromSingleFile(t *testing.T) {
+	t.Run("Tracks every document by position", func(t *testing.T) {
+		backups, err := io.ReadMultipleDocumentsFromSingleFile("test-data",
+			types.NewMultiDocumentConfigBackupOptions("sample-multidocument.yaml", nil, nil))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		expected := []struct{ id, friendlyName string }{
+			{"0", "Document 1"},
+			{"1", "Document 2"},
+			{"3", "Document 4"},
+		}
+		if len(backups) != len(expected) {
+			t.Fatalf("Expected %d config backups, got: %d", len(expected), len(backups))
+		}
+		for i, e := range expected {
+			if backups[i].ID != e.id || backups[i].FriendlyName != e.friendlyName {
+				t.Errorf("Backup %d: expected %s/%s, got %s/%s", i, e.id, e.friendlyName, backups[i].ID, backups[i].FriendlyName)
+			}
+			if backups[i].Path != "sample-multidocument.yaml" {
+				t.Errorf("Backup %d: unexpected path %s", i, backups[i].Path)
+			}
+		}
+		if string(backups[1].Blob) != "name: second\nvalue: 2\n" {
+			t.Errorf("Unexpected blob for second document: %q", backups[1].Blob)
+		}
+	})
+
+	t.Run("Uses the id and friendly name nodes when set", func(t *testing.T) {
+		idNode, friendlyNameNode := "name", "name"
+		backups, err := io.ReadMultipleDocumentsFromSingleFile("test-data",
+			types.NewMultiDocumentConfigBackupOptions("sample-multidocument.yaml", &idNode, &friendlyNameNode))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		ids := []string{}
+		for _, backup := range backups {
+			ids = append(ids, backup.ID)
+			if backup.FriendlyName != backup.ID {
+				t.Errorf("Expected friendly name %s, got %s", backup.ID, backup.FriendlyName)
+			}
+		}
+		if len(ids) != 3 || ids[0] != "first" || ids[1] != "second" || ids[2] != "fourth" {
+			t.Errorf("Unexpected ids: %v", ids)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package io
+
+import (
+	"bytes"
+	"fmt"
+	"ha-config-history/internal/types"
+	"os"
+	"time"
+)
+
+// ReadNodeREDFlowsFromSingleFile reads a Node-RED flows file and returns a backup for each
+// flow tab and subflow with its nodes, plus one for config nodes outside any flow.
+func ReadNodeREDFlowsFromSingleFile(rootPath string, config *types.ConfigBackupOptions) ([]*types.ConfigBackup, error) {
+	currentTime := time.Now().UTC()
+	filePath := rootPath + "/" + config.Path
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	nodes, err := types.ParseNodeREDFlows(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Node-RED flows in %s: %w", filePath, err)
+	}
+
+	flowIds, nodesByFlow := groupNodeREDNodes(nodes)
+
+	configBackups := []*types.ConfigBackup{}
+	for _, flowId := range flowIds {
+		configBackup, err := types.NewNodeREDFlowConfigBackup(filePath, flowId, nodesByFlow[flowId], config, currentTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create config backup for %s: %w", filePath, err)
+		}
+		configBackups = append(configBackups, configBackup)
+	}
+
+	return configBackups, nil
+}
+
+// groupNodeREDNodes groups nodes by flow, keeping the order flows first appear in the file.
+func groupNodeREDNodes(nodes []types.NodeREDNode) ([]string, map[string][]types.NodeREDNode) {
+	flowIds := []string{}
+	nodesByFlow := map[string][]types.NodeREDNode{}
+
+	for _, node := range nodes {
+		flowId := node.FlowId()
+		if _, exists := nodesByFlow[flowId]; !exists {
+			flowIds = append(flowIds, flowId)
+		}
+		nodesByFlow[flowId] = append(nodesByFlow[flowId], node)
+	}
+
+	return flowIds, nodesByFlow
+}
+
+// RestoreNodeREDFlowPartialFile replaces the nodes of a single flow in a Node-RED flows
+// file, keeping the flow's position, or appends them when the flow no longer exists.
+// The file keeps its compact or pretty printed format.
+func RestoreNodeREDFlowPartialFile(filepath string, flowId string, blobToRestore []byte) error {
+	restoredNodes, err := types.ParseNodeREDFlows(blobToRestore)
+	if err != nil {
+		return fmt.Errorf("failed to parse backup flow: %w", err)
+	}
+	if len(restoredNodes) == 0 {
+		return fmt.Errorf("backup content is empty")
+	}
+
+	currentData, err := os.ReadFile(filepath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing flows file %s: %w", filepath, err)
+	}
+
+	currentNodes, err := types.ParseNodeREDFlows(currentData)
+	if err != nil {
+		return fmt.Errorf("failed to parse existing flows in %s: %w", filepath, err)
+	}
+
+	// Nodes moved to another flow since the backup are replaced too, so ids stay unique
+	restoredIds := map[string]bool{}
+	for _, node := range restoredNodes {
+		restoredIds[node.ID] = true
+	}
+
+	updatedNodes := []types.NodeREDNode{}
+	inserted := false
+	for _, node := range currentNodes {
+		if node.FlowId() != flowId && !restoredIds[node.ID] {
+			updatedNodes = append(updatedNodes, node)
+			continue
+		}
+		if !inserted {
+			updatedNodes = append(updatedNodes, restoredNodes...)
+			inserted = true
+		}
+	}
+
+	if !inserted {
+		updatedNodes = append(updatedNodes, restoredNodes...)
+	}
+
+	// Node-RED writes flows compact unless flowFilePretty is enabled
+	indent := ""
+	if bytes.Contains(bytes.TrimSpace(currentData), []byte("\n")) {
+		indent = "    "
+	}
+
+	updatedBlob, err := types.MarshalNodeREDNodes(updatedNodes, indent)
+	if err != nil {
+		return fmt.Errorf("failed to serialize updated flows: %w", err)
+	}
+
+	if err := os.WriteFile(filepath, updatedBlob, 0644); err != nil {
+		return fmt.Errorf("failed to write updated flows file %s: %w", filepath, err)
+	}
+
+	return nil
+}
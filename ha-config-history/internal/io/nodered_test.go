@@ -0,0 +1,152 @@
+package io_test
+
+import (
+	"ha-config-history/internal/io"
+	"ha-config-history/internal/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_ReadNodeREDFlowsFromSingleFile(t *testing.T) {
+	backups, err := io.ReadNodeREDFlowsFromSingleFile("test-data",
+		types.NewNodeREDConfigBackupOptions("sample-nodered-flows.json"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []struct{ id, friendlyName string }{
+		{"tab1", "Lights"},
+		{"tab2", "Heating"},
+		{types.NodeREDGlobalFlowId, "Global config nodes"},
+		{"sf1", "Subflow: Notify"},
+	}
+	if len(backups) != len(expected) {
+		t.Fatalf("Expected %d config backups, got: %d", len(expected), len(backups))
+	}
+	for i, e := range expected {
+		if backups[i].ID != e.id || backups[i].FriendlyName != e.friendlyName {
+			t.Errorf("Backup %d: expected %s/%s, got %s/%s", i, e.id, e.friendlyName, backups[i].ID, backups[i].FriendlyName)
+		}
+	}
+
+	nodes, err := types.ParseNodeREDFlows(backups[0].Blob)
+	if err != nil {
+		t.Fatalf("Expected flow backup to be valid JSON, got: %v", err)
+	}
+	if len(nodes) != 3 || nodes[0].ID != "tab1" || nodes[2].ID != "n2" {
+		t.Errorf("Unexpected nodes in Lights flow: %+v", nodes)
+	}
+}
+
+func Test_RestoreNodeREDFlowPartialFile(t *testing.T) {
+	original, err := os.ReadFile("test-data/sample-nodered-flows.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	restoredFlow := []byte(`[
+  {"id":"tab1","type":"tab","label":"Lights (old)","disabled":false,"info":""},
+  {"id":"n1","type":"inject","z":"tab1","name":"Sunset","wires":[[]]}
+]`)
+
+	readIds := func(t *testing.T, path string) []string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read flows: %v", err)
+		}
+		nodes, err := types.ParseNodeREDFlows(data)
+		if err != nil {
+			t.Fatalf("Restored flows are not valid: %v", err)
+		}
+		ids := []string{}
+		for _, node := range nodes {
+			ids = append(ids, node.ID)
+		}
+		return ids
+	}
+
+	t.Run("Replaces only the nodes of the restored flow in place", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "flows.json")
+		if err := os.WriteFile(path, original, 0644); err != nil {
+			t.Fatalf("Failed to write flows: %v", err)
+		}
+
+		if err := io.RestoreNodeREDFlowPartialFile(path, "tab1", restoredFlow); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		ids := strings.Join(readIds(t, path), ",")
+		if ids != "tab1,n1,tab2,n3,srv,sf1,n4" {
+			t.Errorf("Unexpected node order after restore: %s", ids)
+		}
+
+		restored, _ := os.ReadFile(path)
+		if !strings.Contains(string(restored), "Lights (old)") {
+			t.Error("Expected restored tab label")
+		}
+		if strings.Contains(strings.TrimSpace(string(restored)), "\n") {
+			t.Error("Expected compact flows file to stay compact")
+		}
+	})
+
+	t.Run("Appends a flow that no longer exists", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "flows.json")
+		withoutLights := `[
+    {"id":"tab2","type":"tab","label":"Heating"},
+    {"id":"n3","type":"inject","z":"tab2","name":"Morning","wires":[[]]}
+]`
+		if err := os.WriteFile(path, []byte(withoutLights), 0644); err != nil {
+			t.Fatalf("Failed to write flows: %v", err)
+		}
+
+		if err := io.RestoreNodeREDFlowPartialFile(path, "tab1", restoredFlow); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		ids := strings.Join(readIds(t, path), ",")
+		if ids != "tab2,n3,tab1,n1" {
+			t.Errorf("Unexpected node order after restore: %s", ids)
+		}
+
+		restored, _ := os.ReadFile(path)
+		if !strings.Contains(string(restored), "\n    {") {
+			t.Error("Expected pretty printed flows file to stay pretty printed")
+		}
+	})
+}
+
+func Test_NodeREDFunctionCodeIsNotEscaped(t *testing.T) {
+	code := `if (msg.a && msg.b < 3 || msg.c > 4) { return msg; }`
+	flows := []byte(`[
+  {"id":"tab1","type":"tab","label":"Lights"},
+  {"id":"f1","type":"function","z":"tab1","name":"Check","func":"` + code + `"},
+  {"id":"tab2","type":"tab","label":"Heating"},
+  {"id":"f2","type":"function","z":"tab2","name":"Other","func":"` + code + `"}
+]`)
+	path := filepath.Join(t.TempDir(), "flows.json")
+	if err := os.WriteFile(path, flows, 0644); err != nil {
+		t.Fatalf("Failed to write flows: %v", err)
+	}
+
+	backups, err := io.ReadNodeREDFlowsFromSingleFile(filepath.Dir(path), types.NewNodeREDConfigBackupOptions("flows.json"))
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("Expected two flows, got %d (%v)", len(backups), err)
+	}
+	if !strings.Contains(string(backups[0].Blob), code) {
+		t.Errorf("Expected the function code to be backed up as written, got %s", backups[0].Blob)
+	}
+
+	// Restoring one flow rewrites the whole file, which must leave the other flow's code alone
+	if err := io.RestoreNodeREDFlowPartialFile(path, "tab1", backups[0].Blob); err != nil {
+		t.Fatalf("Failed to restore flow: %v", err)
+	}
+	restored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read flows: %v", err)
+	}
+	if strings.Count(string(restored), code) != 2 || strings.Contains(string(restored), `\u0026`) {
+		t.Errorf("Expected the function code of both flows to be unescaped, got %s", restored)
+	}
+}
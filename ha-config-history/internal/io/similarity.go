@@ -0,0 +1,46 @@
+package io
+
+import (
+	"bytes"
+	"ha-config-history/internal/types"
+)
+
+// Similarity returns how alike two backups are as the Dice coefficient of their
+// non-empty lines, ignoring indentation: 1 when every line matches and 0 when none do.
+// Binary content has no lines to compare, so it is only alike when identical.
+func Similarity(a, b []byte) float64 {
+	if types.IsBinary(a) || types.IsBinary(b) {
+		if bytes.Equal(a, b) {
+			return 1
+		}
+		return 0
+	}
+
+	linesA := lineCounts(a)
+	linesB := lineCounts(b)
+
+	totalA, totalB, shared := 0, 0, 0
+	for line, count := range linesA {
+		totalA += count
+		shared += min(count, linesB[line])
+	}
+	for _, count := range linesB {
+		totalB += count
+	}
+
+	if totalA+totalB == 0 {
+		return 1
+	}
+	return float64(2*shared) / float64(totalA+totalB)
+}
+
+func lineCounts(blob []byte) map[string]int {
+	counts := map[string]int{}
+	for _, line := range bytes.Split(blob, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 {
+			counts[string(trimmed)]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,49 @@
+package io_test
+
+import (
+	"ha-config-history/internal/io"
+	"testing"
+)
+
+func Test_Similarity(t *testing.T) {
+	original := []byte(`id: old_id
+alias: Porch light
+trigger:
+  - platform: sun
+    event: sunset
+action:
+  - service: light.turn_on
+    target:
+      entity_id: light.porch
+`)
+
+	tests := []struct {
+		name     string
+		other    []byte
+		minScore float64
+		maxScore float64
+	}{
+		{"identical content", original, 1, 1},
+		{"only the id changed", []byte(`id: new_id
+alias: Porch light
+trigger:
+  - platform: sun
+    event: sunset
+action:
+  - service: light.turn_on
+    target:
+      entity_id: light.porch
+`), 0.85, 0.95},
+		{"unrelated content", []byte("sequence:\n  - delay: 5\n"), 0, 0.2},
+		{"empty content", []byte(""), 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := io.Similarity(original, tt.other)
+			if score < tt.minScore || score > tt.maxScore {
+				t.Errorf("expected score between %v and %v, got %v", tt.minScore, tt.maxScore, score)
+			}
+		})
+	}
+}
@@ -5,14 +5,17 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 )
 
 type GroupSlug string
 
 type ConfigBackupOptionGroup struct {
-	Name    string                 `json:"groupName"`
-	Slug    GroupSlug              `json:"slug"`
-	Configs []*ConfigBackupOptions `json:"configs"`
+	Name         string                 `json:"groupName"`
+	Slug         GroupSlug              `json:"slug"`
+	Configs      []*ConfigBackupOptions `json:"configs"`
+	CronSchedule *string                `json:"cronSchedule,omitempty"` // Schedule the group's configs are scanned on instead of the app's
+	DisableWatch bool                   `json:"disableWatch,omitempty"` // Only back up the group's configs on a schedule, without watching their files
 }
 
 func NewConfigBackupOptionGroup(name string, configs []*ConfigBackupOptions) *ConfigBackupOptionGroup {
@@ -25,25 +28,91 @@ func NewConfigBackupOptionGroup(name string, configs []*ConfigBackupOptions) *Co
 }
 
 type AppSettings struct {
-	HomeAssistantConfigDir  string                     `json:"homeAssistantConfigDir"`
-	BackupDir               string                     `json:"backupDir"`
-	Port                    string                     `json:"port"`
-	CronSchedule            *string                    `json:"cronSchedule,omitempty"`
-	DefaultMaxBackups       *int                       `json:"defaultMaxBackups,omitempty"`
-	DefaultMaxBackupAgeDays *int                       `json:"defaultMaxBackupAgeDays,omitempty"`
-	ConfigGroups            []*ConfigBackupOptionGroup `json:"configGroups,omitempty"`
-	Configs                 []*ConfigBackupOptions     `json:"configs,omitempty"` // Deprecated: kept for migration
+	HomeAssistantConfigDir     string                     `json:"homeAssistantConfigDir"`
+	BackupDir                  string                     `json:"backupDir"`
+	Port                       string                     `json:"port"`
+	CronSchedule               *string                    `json:"cronSchedule,omitempty"`
+	DefaultMaxBackups          *int                       `json:"defaultMaxBackups,omitempty"`
+	DefaultMaxBackupAgeDays    *int                       `json:"defaultMaxBackupAgeDays,omitempty"`
+	SourceRoots                []*SourceRoot              `json:"sourceRoots,omitempty"`
+	Redaction                  *RedactionSettings         `json:"redaction,omitempty"`
+	Workers                    *int                       `json:"workers,omitempty"`                    // Number of backup jobs processed at once
+	QueueSize                  *int                       `json:"queueSize,omitempty"`                  // Number of backup jobs that can wait before producers are held up
+	WatchQuietPeriodMs         *int                       `json:"watchQuietPeriodMs,omitempty"`         // How long a watched file must be unchanged before it is read
+	PollIntervalMs             *int                       `json:"pollIntervalMs,omitempty"`             // How often polled directories are checked for changes
+	PollHomeAssistantConfigDir bool                       `json:"pollHomeAssistantConfigDir,omitempty"` // Poll the Home Assistant config directory instead of waiting for file system events
+	ResolveSecrets             bool                       `json:"resolveSecrets,omitempty"`             // Allow resolving !secret references in history for authenticated users
+	HomeAssistant              *HomeAssistantSettings     `json:"homeAssistant,omitempty"`
+	ConfigGroups               []*ConfigBackupOptionGroup `json:"configGroups,omitempty"`
+	Configs                    []*ConfigBackupOptions     `json:"configs,omitempty"` // Deprecated: kept for migration
+}
+
+// DefaultWatchQuietPeriod is how long a watched file must be unchanged before it is read
+// when WatchQuietPeriodMs is not set.
+const DefaultWatchQuietPeriod = 500 * time.Millisecond
+
+// WatchQuietPeriod returns how long a watched file must be unchanged before it is read,
+// so that the events of one save are handled together.
+func (a *AppSettings) WatchQuietPeriod() time.Duration {
+	if a.WatchQuietPeriodMs == nil {
+		return DefaultWatchQuietPeriod
+	}
+	return time.Duration(*a.WatchQuietPeriodMs) * time.Millisecond
+}
+
+// DefaultPollInterval is how often polled directories are checked for changes when
+// PollIntervalMs is not set.
+const DefaultPollInterval = 10 * time.Second
+
+// PollInterval returns how often directories that cannot use file system events are
+// checked for changes.
+func (a *AppSettings) PollInterval() time.Duration {
+	if a.PollIntervalMs == nil {
+		return DefaultPollInterval
+	}
+	return time.Duration(*a.PollIntervalMs) * time.Millisecond
+}
+
+const (
+	// DefaultWorkers is the number of backup jobs processed at once when Workers is not set
+	DefaultWorkers = 2
+	// DefaultQueueSize is the number of backup jobs that can wait when QueueSize is not set
+	DefaultQueueSize = 256
+)
+
+// QueueWorkers returns the number of backup jobs processed at once.
+func (a *AppSettings) QueueWorkers() int {
+	if a.Workers == nil {
+		return DefaultWorkers
+	}
+	return *a.Workers
+}
+
+// QueueCapacity returns the number of backup jobs that can wait to be processed.
+func (a *AppSettings) QueueCapacity() int {
+	if a.QueueSize == nil {
+		return DefaultQueueSize
+	}
+	return *a.QueueSize
 }
 
 type ConfigBackupOptions struct {
-	Path                string   `json:"path"`
-	BackupType          string   `json:"backupType"` // "multiple", "single", "directory", "keyed"
-	MaxBackups          *int     `json:"maxBackups,omitempty"`
-	MaxBackupAgeDays    *int     `json:"maxBackupAgeDays,omitempty"`
-	IdNode              *string  `json:"idNode,omitempty"`
-	FriendlyNameNode    *string  `json:"friendlyNameNode,omitempty"`
-	IncludeFilePatterns []string `json:"includeFilePatterns,omitempty"`
-	ExcludeFilePatterns []string `json:"excludeFilePatterns,omitempty"`
+	Path                 string            `json:"path"`
+	BackupType           string            `json:"backupType"` // "multiple", "single", "directory", "keyed", "multidocument", "nodered", "blueprint"
+	MaxBackups           *int              `json:"maxBackups,omitempty"`
+	MaxBackupAgeDays     *int              `json:"maxBackupAgeDays,omitempty"`
+	IdNode               *string           `json:"idNode,omitempty"`
+	FriendlyNameNode     *string           `json:"friendlyNameNode,omitempty"`
+	IncludeFilePatterns  []string          `json:"includeFilePatterns,omitempty"`
+	ExcludeFilePatterns  []string          `json:"excludeFilePatterns,omitempty"`
+	MissingIdStrategy    *string           `json:"missingIdStrategy,omitempty"`    // "fingerprint" (default), "slug", "index"
+	Root                 string            `json:"root,omitempty"`                 // Source root the path is relative to, defaults to "homeassistant"
+	Normalize            *NormalizeOptions `json:"normalize,omitempty"`            // Rules applied before hashing so volatile fields do not create versions
+	CronSchedule         *string           `json:"cronSchedule,omitempty"`         // Schedule the config is scanned on instead of its group's
+	DisableWatch         *bool             `json:"disableWatch,omitempty"`         // Overrides the group's DisableWatch
+	ReloadAfterRestore   *bool             `json:"reloadAfterRestore,omitempty"`   // Calls a Home Assistant reload service after a restore
+	ReloadService        *string           `json:"reloadService,omitempty"`        // "domain.service" to call instead of the file's default
+	CheckConfigOnRestore *bool             `json:"checkConfigOnRestore,omitempty"` // Rolls back a restore that fails Home Assistant's config check
 }
 
 func NewSingleConfigBackupOptions(path string) *ConfigBackupOptions {
@@ -80,8 +149,38 @@ func NewKeyedConfigBackupOptions(path string, friendlyNameNodeName string) *Conf
 	}
 }
 
-// saveAppSettings writes the AppSettings to the config file
-func saveAppSettings(configPath string, appSettings *AppSettings) error {
+// NewMultiDocumentConfigBackupOptions creates options for a multi-document YAML file, where
+// each "---" separated document is tracked. Documents are identified by idNode when set,
+// otherwise by their position in the file.
+func NewMultiDocumentConfigBackupOptions(path string, idNode, friendlyNameNode *string) *ConfigBackupOptions {
+	return &ConfigBackupOptions{
+		Path:             path,
+		BackupType:       BackupTypeMultiDocumentName,
+		IdNode:           idNode,
+		FriendlyNameNode: friendlyNameNode,
+	}
+}
+
+// NewNodeREDConfigBackupOptions creates options for a Node-RED flows file, where each flow
+// tab is tracked together with its nodes.
+func NewNodeREDConfigBackupOptions(path string) *ConfigBackupOptions {
+	return &ConfigBackupOptions{
+		Path:       path,
+		BackupType: BackupTypeNodeREDName,
+	}
+}
+
+// NewBlueprintConfigBackupOptions creates options for a blueprints directory, where every
+// blueprint file in it and its subdirectories is tracked.
+func NewBlueprintConfigBackupOptions(path string) *ConfigBackupOptions {
+	return &ConfigBackupOptions{
+		Path:       path,
+		BackupType: BackupTypeBlueprintName,
+	}
+}
+
+// SaveAppSettings writes the AppSettings to the config file
+func SaveAppSettings(configPath string, appSettings *AppSettings) error {
 	data, err := json.MarshalIndent(appSettings, "", "  ")
 	if err != nil {
 		return err
@@ -152,6 +251,7 @@ func LoadAppSettings(appSettingsPath string) *AppSettings {
 		CronSchedule:            new(string),
 		DefaultMaxBackups:       nil,
 		DefaultMaxBackupAgeDays: nil,
+		SourceRoots:             defaultSourceRoots(),
 		ConfigGroups:            defaultConfigGroups,
 	}
 
@@ -189,19 +289,28 @@ const (
 	BackupTypeSingle
 	BackupTypeDirectory
 	BackupTypeKeyed
+	BackupTypeMultiDocument
+	BackupTypeNodeRED
+	BackupTypeBlueprint
 )
 
 // Backup type string constants
 const (
-	BackupTypeMultipleName  = "multiple"
-	BackupTypeSingleName    = "single"
-	BackupTypeDirectoryName = "directory"
-	BackupTypeKeyedName     = "keyed"
+	BackupTypeMultipleName      = "multiple"
+	BackupTypeSingleName        = "single"
+	BackupTypeDirectoryName     = "directory"
+	BackupTypeKeyedName         = "keyed"
+	BackupTypeMultiDocumentName = "multidocument"
+	BackupTypeNodeREDName       = "nodered"
+	BackupTypeBlueprintName     = "blueprint"
 )
 
 var stateName = map[BackupType]string{
-	BackupTypeMultiple:  BackupTypeMultipleName,
-	BackupTypeSingle:    BackupTypeSingleName,
-	BackupTypeDirectory: BackupTypeDirectoryName,
-	BackupTypeKeyed:     BackupTypeKeyedName,
+	BackupTypeMultiple:      BackupTypeMultipleName,
+	BackupTypeSingle:        BackupTypeSingleName,
+	BackupTypeDirectory:     BackupTypeDirectoryName,
+	BackupTypeKeyed:         BackupTypeKeyedName,
+	BackupTypeMultiDocument: BackupTypeMultiDocumentName,
+	BackupTypeNodeRED:       BackupTypeNodeREDName,
+	BackupTypeBlueprint:     BackupTypeBlueprintName,
 }
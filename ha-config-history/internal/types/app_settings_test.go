@@ -151,7 +151,7 @@ func TestSaveAppSettings(t *testing.T) {
 	}
 
 	// Test saving configuration
-	err := saveAppSettings(configPath, appSettings)
+	err := SaveAppSettings(configPath, appSettings)
 	if err != nil {
 		t.Fatalf("failed to save config: %v", err)
 	}
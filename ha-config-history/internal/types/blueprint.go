@@ -0,0 +1,83 @@
+package types
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BlueprintDomains are the blueprint folders that automations and scripts reference
+// blueprints from, as blueprints/<domain>/<path>.
+var BlueprintDomains = []string{"automation", "script"}
+
+// BlueprintId turns a blueprint's path relative to its tracked directory into a config
+// id. Separators are replaced so the id is a single path segment in URLs and backups.
+func BlueprintId(relativePath string) string {
+	return strings.ReplaceAll(filepath.ToSlash(relativePath), "/", "~")
+}
+
+// BlueprintRelativePath reverses BlueprintId.
+func BlueprintRelativePath(id string) string {
+	return filepath.FromSlash(strings.ReplaceAll(id, "~", "/"))
+}
+
+func NewBlueprintConfigBackup(filepath, relativePath string, blob []byte, config *ConfigBackupOptions, modifiedDate time.Time) (*ConfigBackup, error) {
+	if config.BackupType != stateName[BackupTypeBlueprint] {
+		return nil, fmt.Errorf("NewBlueprintConfigBackup called with non-blueprint backup type: %s", config.BackupType)
+	}
+
+	friendlyName := relativePath
+	var rootNode yaml.Node
+	if err := yaml.Unmarshal(blob, &rootNode); err == nil && len(rootNode.Content) > 0 {
+		if blueprintNode := getYamlMappingNode(rootNode.Content[0], "blueprint"); blueprintNode != nil {
+			if name, ok := GetYamlNodeValueOk(blueprintNode, "name"); ok && name != "" {
+				friendlyName = name
+			}
+		}
+	}
+
+	return &ConfigBackup{
+		ConfigBackupIdentifier: ConfigBackupIdentifier{
+			ID:   BlueprintId(relativePath),
+			Path: config.Path,
+		},
+		FriendlyName: friendlyName,
+		Hash:         HashContent(blob, config),
+		BackupType:   config.BackupType,
+		ModifiedDate: modifiedDate,
+		FilePath:     filepath,
+		Blob:         blob,
+	}, nil
+}
+
+// UsedBlueprintPath returns the use_blueprint path of an automation or script, relative
+// to its blueprint domain folder.
+func UsedBlueprintPath(blob []byte) (string, bool) {
+	var rootNode yaml.Node
+	if err := yaml.Unmarshal(blob, &rootNode); err != nil || len(rootNode.Content) == 0 {
+		return "", false
+	}
+
+	useBlueprintNode := getYamlMappingNode(rootNode.Content[0], "use_blueprint")
+	if useBlueprintNode == nil {
+		return "", false
+	}
+
+	path, ok := GetYamlNodeValueOk(useBlueprintNode, "path")
+	return path, ok && path != ""
+}
+
+func getYamlMappingNode(yamlNode *yaml.Node, key string) *yaml.Node {
+	if yamlNode.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(yamlNode.Content); i += 2 {
+		if yamlNode.Content[i].Value == key && yamlNode.Content[i+1].Kind == yaml.MappingNode {
+			return yamlNode.Content[i+1]
+		}
+	}
+	return nil
+}
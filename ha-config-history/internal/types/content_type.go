@@ -0,0 +1,64 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// textMimeTypes are the only types text files are served as other than plain text. Backups
+// are served from the Home Assistant origin, so a tracked .html or .js file must never be
+// served as a type the browser would run.
+var textMimeTypes = map[string]string{
+	".yaml": "application/x-yaml",
+	".yml":  "application/x-yaml",
+	".json": "application/json",
+}
+
+// IsBinary reports whether content is binary rather than text: it contains a NUL byte or
+// is not valid UTF-8.
+func IsBinary(content []byte) bool {
+	return bytes.IndexByte(content, 0) != -1 || !utf8.Valid(content)
+}
+
+// DetectContentType returns the MIME type of a file's content. Binary files are typed by
+// sniffing their content. Text files are YAML or JSON by their extension, extensionless JSON
+// such as .storage files is detected as JSON, and everything else is plain text.
+func DetectContentType(filename string, content []byte) string {
+	if IsBinary(content) {
+		// Sniffing only looks at the start of the content, which can look like text
+		if sniffed := http.DetectContentType(content); !strings.HasPrefix(sniffed, "text/") {
+			return sniffed
+		}
+		return "application/octet-stream"
+	}
+
+	extension := strings.ToLower(filepath.Ext(filename))
+	if mimeType, ok := textMimeTypes[extension]; ok {
+		return mimeType
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// BackupContentType returns the MIME type of a backup of a config entry. Blob backups are
+// detected from the file, other types are always serialised the same way.
+func BackupContentType(backupType, path, id string, content []byte) string {
+	switch backupType {
+	case BackupTypeSingleName:
+		return DetectContentType(path, content)
+	case BackupTypeDirectoryName:
+		return DetectContentType(id, content)
+	case BackupTypeNodeREDName:
+		return "application/json"
+	default:
+		return "application/x-yaml"
+	}
+}
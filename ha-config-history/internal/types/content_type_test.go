@@ -0,0 +1,36 @@
+package types
+
+import "testing"
+
+func TestDetectContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+		expected string
+		binary   bool
+	}{
+		{"yaml file", "configuration.yaml", []byte("homeassistant:\n"), "application/x-yaml", false},
+		{"json file", "flows.json", []byte("[]"), "application/json", false},
+		{"storage file without extension", "core.config", []byte(`{"version": 1}`), "application/json", false},
+		{"css file", "style.css", []byte("body {}"), "text/plain; charset=utf-8", false},
+		{"html file", "www/index.html", []byte("<script>alert(1)</script>"), "text/plain; charset=utf-8", false},
+		{"javascript file", "www/card.js", []byte("alert(1)"), "text/plain; charset=utf-8", false},
+		{"plain text", "notes", []byte("hello"), "text/plain; charset=utf-8", false},
+		{"png image", "logo.png", png, "image/png", true},
+		{"invalid utf-8", "home-assistant_v2.db", []byte{0xff, 0xfe, 0x41}, "application/octet-stream", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if binary := IsBinary(tt.content); binary != tt.binary {
+				t.Errorf("IsBinary() = %v, expected %v", binary, tt.binary)
+			}
+			if mimeType := DetectContentType(tt.filename, tt.content); mimeType != tt.expected {
+				t.Errorf("DetectContentType() = %q, expected %q", mimeType, tt.expected)
+			}
+		})
+	}
+}
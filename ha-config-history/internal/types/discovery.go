@@ -0,0 +1,30 @@
+package types
+
+// Discovered file classifications
+const (
+	DiscoveryKindMultiple  = "multiple"
+	DiscoveryKindKeyed     = "keyed"
+	DiscoveryKindSingle    = "single"
+	DiscoveryKindStorage   = "storage"
+	DiscoveryKindESPHome   = "esphome"
+	DiscoveryKindBlueprint = "blueprint"
+	DiscoveryKindNodeRED   = "nodered"
+)
+
+// DiscoveredConfig is a proposed config group found by scanning the Home Assistant
+// config directory, along with the files it would track.
+type DiscoveredConfig struct {
+	Kind           string                   `json:"kind"`
+	Group          *ConfigBackupOptionGroup `json:"group"`
+	Files          []string                 `json:"files"`
+	FileCount      int                      `json:"fileCount"`
+	TotalSize      int64                    `json:"totalSize"`
+	AlreadyTracked bool                     `json:"alreadyTracked"`
+}
+
+// AddFile records a file that the proposal would track.
+func (d *DiscoveredConfig) AddFile(path string, size int64) {
+	d.Files = append(d.Files, path)
+	d.FileCount++
+	d.TotalSize += size
+}
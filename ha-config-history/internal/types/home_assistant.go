@@ -0,0 +1,103 @@
+package types
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// HomeAssistantSettings connects the add-on to the Home Assistant API. When URL and Token
+// are empty the add-on uses the Supervisor's proxy with its SUPERVISOR_TOKEN.
+type HomeAssistantSettings struct {
+	URL   string `json:"url,omitempty"`   // e.g. "http://homeassistant.local:8123"
+	Token string `json:"token,omitempty"` // Long-lived access token
+	// Attribution looks up who made each change in the Home Assistant logbook
+	Attribution bool `json:"attribution,omitempty"`
+	// AttributionWindowSeconds is how far from a change Home Assistant activity is
+	// matched to it
+	AttributionWindowSeconds *int `json:"attributionWindowSeconds,omitempty"`
+}
+
+// DefaultAttributionWindow is how far from a change Home Assistant activity is matched to
+// it when AttributionWindowSeconds is not set.
+const DefaultAttributionWindow = 30 * time.Second
+
+// AttributesChanges reports whether saved versions are attributed to the user or
+// integration that made them.
+func (h *HomeAssistantSettings) AttributesChanges() bool {
+	return h != nil && h.Attribution
+}
+
+// AttributionWindow returns how far from a change Home Assistant activity is matched to it.
+func (h *HomeAssistantSettings) AttributionWindow() time.Duration {
+	if h == nil || h.AttributionWindowSeconds == nil {
+		return DefaultAttributionWindow
+	}
+	return time.Duration(*h.AttributionWindowSeconds) * time.Second
+}
+
+// Validate checks the API URL and attribution window.
+func (h *HomeAssistantSettings) Validate() error {
+	if h == nil {
+		return nil
+	}
+	if h.URL != "" {
+		parsed, err := url.Parse(h.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("url must be an http or https address, got '%s'", h.URL)
+		}
+	}
+	if h.AttributionWindowSeconds != nil && (*h.AttributionWindowSeconds < 1 || *h.AttributionWindowSeconds > 600) {
+		return fmt.Errorf("attribution window must be between 1 and 600 seconds")
+	}
+	return nil
+}
+
+// MaskedToken is returned in place of the access token, which the API never returns.
+const MaskedToken = "********"
+
+// Masked returns a copy of the settings with the access token replaced by MaskedToken.
+func (h *HomeAssistantSettings) Masked() *HomeAssistantSettings {
+	if h == nil {
+		return nil
+	}
+	masked := *h
+	if masked.Token != "" {
+		masked.Token = MaskedToken
+	}
+	return &masked
+}
+
+// KeepToken keeps the stored access token when these settings leave it blank or masked,
+// as settings read back from the API do.
+func (h *HomeAssistantSettings) KeepToken(stored *HomeAssistantSettings) {
+	if h == nil || (h.Token != "" && h.Token != MaskedToken) {
+		return
+	}
+	h.Token = ""
+	if stored != nil {
+		h.Token = stored.Token
+	}
+}
+
+// Change sources recorded in a VersionAttribution
+const (
+	// ChangeSourceUser is a change made by a Home Assistant user, such as in the UI editors
+	ChangeSourceUser = "user"
+	// ChangeSourceIntegration is a change made by Home Assistant itself, an automation or an
+	// integration
+	ChangeSourceIntegration = "integration"
+	// ChangeSourceExternal is a change with no matching Home Assistant activity, such as an
+	// edit in a text editor add-on or over SSH
+	ChangeSourceExternal = "external"
+)
+
+// VersionAttribution records who or what made the change a backup version captured.
+type VersionAttribution struct {
+	Source string `json:"source"`
+	User   string `json:"user,omitempty"`
+	UserID string `json:"userId,omitempty"`
+	// Detail describes the matched activity, such as the service that was called
+	Detail     string     `json:"detail,omitempty"`
+	ActivityAt *time.Time `json:"activityAt,omitempty"`
+}
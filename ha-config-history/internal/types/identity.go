@@ -0,0 +1,131 @@
+package types
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Strategies for identifying entries of a multiple config that have no id node
+const (
+	MissingIdStrategyFingerprint = "fingerprint"
+	MissingIdStrategySlug        = "slug"
+	MissingIdStrategyIndex       = "index"
+)
+
+var MissingIdStrategies = []string{
+	MissingIdStrategyFingerprint,
+	MissingIdStrategySlug,
+	MissingIdStrategyIndex,
+}
+
+// EntryIdentity is the resolved id of an entry within a multiple config file.
+type EntryIdentity struct {
+	ID        string
+	MissingId bool
+}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ResolveEntryIdentities returns the id of every entry in a multiple config file. Entries
+// with an id node use its value; the rest fall back to the configured missing id strategy,
+// with a numeric suffix added when two fallback ids would otherwise collide.
+func ResolveEntryIdentities(entries []*yaml.Node, config *ConfigBackupOptions) []EntryIdentity {
+	identities := make([]EntryIdentity, len(entries))
+	seen := map[string]int{}
+
+	for index, entry := range entries {
+		if config.IdNode != nil {
+			if id, ok := GetYamlNodeValueOk(entry, *config.IdNode); ok && id != "" {
+				identities[index] = EntryIdentity{ID: id}
+				continue
+			}
+		}
+
+		identities[index] = EntryIdentity{ID: uniqueId(seen, fallbackEntryId(entry, index, config)), MissingId: true}
+	}
+
+	return identities
+}
+
+// ResolveDocumentIds returns the id of every document in a multi-document file, with an
+// empty id for nil (empty) documents. Documents use the value of their id node, otherwise
+// their position in the file, with a numeric suffix added when two documents would share an
+// id so that they never share a backup folder.
+func ResolveDocumentIds(documents []*yaml.Node, config *ConfigBackupOptions) []string {
+	ids := make([]string, len(documents))
+	seen := map[string]int{}
+
+	for index, document := range documents {
+		if document == nil {
+			continue
+		}
+
+		id := strconv.Itoa(index)
+		if config.IdNode != nil && document.Kind == yaml.MappingNode {
+			if value, ok := GetYamlNodeValueOk(document, *config.IdNode); ok && value != "" {
+				id = value
+			}
+		}
+		ids[index] = uniqueId(seen, id)
+	}
+
+	return ids
+}
+
+// uniqueId adds a numeric suffix to id when it has already been seen.
+func uniqueId(seen map[string]int, id string) string {
+	seen[id]++
+	if seen[id] > 1 {
+		id = fmt.Sprintf("%s-%d", id, seen[id])
+	}
+	return id
+}
+
+func fallbackEntryId(entry *yaml.Node, index int, config *ConfigBackupOptions) string {
+	strategy := MissingIdStrategyFingerprint
+	if config.MissingIdStrategy != nil && *config.MissingIdStrategy != "" {
+		strategy = *config.MissingIdStrategy
+	}
+
+	switch strategy {
+	case MissingIdStrategyIndex:
+		return fmt.Sprintf("index-%d", index)
+	case MissingIdStrategySlug:
+		if config.FriendlyNameNode != nil {
+			if name, ok := GetYamlNodeValueOk(entry, *config.FriendlyNameNode); ok {
+				if slug := slugify(name); slug != "" {
+					return "slug-" + slug
+				}
+			}
+		}
+	}
+
+	return "fp-" + fingerprintYamlNode(entry)
+}
+
+// fingerprintYamlNode hashes the decoded content of a node, so that formatting, comments
+// and key order do not change the fingerprint.
+func fingerprintYamlNode(node *yaml.Node) string {
+	var content interface{}
+	canonical, err := yaml.Marshal(node)
+	if err == nil && node.Decode(&content) == nil {
+		if encoded, err := json.Marshal(content); err == nil {
+			canonical = encoded
+		}
+	}
+
+	hasher := sha1.New()
+	hasher.Write(canonical)
+	return hex.EncodeToString(hasher.Sum(nil))[:12]
+}
+
+func slugify(value string) string {
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(value), "_"), "_")
+}
@@ -0,0 +1,108 @@
+package types
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parseSequence(t *testing.T, content string) []*yaml.Node {
+	t.Helper()
+	var rootNode yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &rootNode); err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	return rootNode.Content[0].Content
+}
+
+func TestResolveEntryIdentities(t *testing.T) {
+	content := `
+- id: kept
+  alias: Has Id
+- alias: Morning Lights
+  trigger: sunrise
+- alias: Morning Lights
+  trigger: sunset
+`
+	idNode, friendlyNameNode := "id", "alias"
+	options := func(strategy string) *ConfigBackupOptions {
+		config := NewMultipleConfigBackupOptions("automations.yaml", idNode, friendlyNameNode)
+		if strategy != "" {
+			config.MissingIdStrategy = &strategy
+		}
+		return config
+	}
+
+	t.Run("uses the id node when present", func(t *testing.T) {
+		identities := ResolveEntryIdentities(parseSequence(t, content), options(""))
+		if identities[0] != (EntryIdentity{ID: "kept"}) {
+			t.Errorf("unexpected identity for entry with id: %+v", identities[0])
+		}
+		if !identities[1].MissingId || !identities[2].MissingId {
+			t.Error("expected entries without id to be flagged")
+		}
+	})
+
+	t.Run("fingerprints ignore formatting and key order", func(t *testing.T) {
+		reformatted := `
+- id: kept
+  alias: Has Id
+- trigger: "sunrise"   # moved
+  alias: 'Morning Lights'
+- {alias: Morning Lights, trigger: sunset}
+`
+		original := ResolveEntryIdentities(parseSequence(t, content), options(MissingIdStrategyFingerprint))
+		changed := ResolveEntryIdentities(parseSequence(t, reformatted), options(MissingIdStrategyFingerprint))
+		for i := range original {
+			if original[i] != changed[i] {
+				t.Errorf("entry %d: fingerprint changed from %s to %s", i, original[i].ID, changed[i].ID)
+			}
+		}
+		if original[1].ID == original[2].ID {
+			t.Error("expected different content to produce different fingerprints")
+		}
+	})
+
+	t.Run("slug ids are de-duplicated", func(t *testing.T) {
+		identities := ResolveEntryIdentities(parseSequence(t, content), options(MissingIdStrategySlug))
+		if identities[1].ID != "slug-morning_lights" || identities[2].ID != "slug-morning_lights-2" {
+			t.Errorf("unexpected slug ids: %s, %s", identities[1].ID, identities[2].ID)
+		}
+	})
+
+	t.Run("index ids use the position in the file", func(t *testing.T) {
+		identities := ResolveEntryIdentities(parseSequence(t, content), options(MissingIdStrategyIndex))
+		if identities[1].ID != "index-1" || identities[2].ID != "index-2" {
+			t.Errorf("unexpected index ids: %s, %s", identities[1].ID, identities[2].ID)
+		}
+	})
+}
+
+func TestResolveDocumentIds(t *testing.T) {
+	parseDocument := func(content string) *yaml.Node {
+		var document yaml.Node
+		if err := yaml.Unmarshal([]byte(content), &document); err != nil {
+			t.Fatalf("failed to parse YAML: %v", err)
+		}
+		return document.Content[0]
+	}
+
+	idNode := "name"
+	config := NewMultiDocumentConfigBackupOptions("documents.yaml", &idNode, nil)
+
+	documents := []*yaml.Node{
+		parseDocument("name: kitchen\n"),
+		parseDocument("name: kitchen\n"),
+		nil,
+		parseDocument("name: \"4\"\n"),
+		parseDocument("other: value\n"),
+	}
+
+	ids := ResolveDocumentIds(documents, config)
+	expected := []string{"kitchen", "kitchen-2", "", "4", "4-2"}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Errorf("document %d: expected id %q, got %q", i, expected[i], ids[i])
+		}
+	}
+}
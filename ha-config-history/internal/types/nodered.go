@@ -0,0 +1,124 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// NodeREDGlobalFlowId identifies the config nodes that do not belong to a flow tab.
+const NodeREDGlobalFlowId = "global"
+
+// NodeREDNode is a node from a Node-RED flows file. Only the fields used to group nodes
+// by flow are decoded, Raw keeps the node as it was written.
+type NodeREDNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Z     string `json:"z"`
+	Label string `json:"label"`
+	Name  string `json:"name"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+// FlowId returns the id of the flow a node belongs to: tabs and subflows are their own
+// flow, other nodes belong to the flow in their z field, or to the global flow without one.
+func (n NodeREDNode) FlowId() string {
+	if n.Type == "tab" || n.Type == "subflow" {
+		return n.ID
+	}
+	if n.Z != "" {
+		return n.Z
+	}
+	return NodeREDGlobalFlowId
+}
+
+// ParseNodeREDFlows decodes a flows file, which is a flat array of nodes.
+func ParseNodeREDFlows(data []byte) ([]NodeREDNode, error) {
+	var rawNodes []json.RawMessage
+	if err := json.Unmarshal(data, &rawNodes); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]NodeREDNode, len(rawNodes))
+	for index, raw := range rawNodes {
+		if err := json.Unmarshal(raw, &nodes[index]); err != nil {
+			return nil, fmt.Errorf("node %d: %w", index, err)
+		}
+		nodes[index].Raw = raw
+	}
+	return nodes, nil
+}
+
+// MarshalNodeREDNodes writes nodes back as a JSON array, with each node on its own
+// indented lines so backups diff cleanly. Characters such as & and < are written as they
+// are, like Node-RED does, rather than escaped, so function code reads as it was written.
+func MarshalNodeREDNodes(nodes []NodeREDNode, indent string) ([]byte, error) {
+	rawNodes := make([]json.RawMessage, len(nodes))
+	for index, node := range nodes {
+		rawNodes[index] = node.Raw
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(rawNodes); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// NodeREDFlowName returns the name to show for a flow given its tab or subflow node,
+// which is nil for the global flow or a flow whose tab is missing.
+func NodeREDFlowName(flowId string, flowNode *NodeREDNode) string {
+	if flowId == NodeREDGlobalFlowId {
+		return "Global config nodes"
+	}
+	if flowNode == nil {
+		return flowId
+	}
+	if flowNode.Type == "subflow" {
+		if flowNode.Name != "" {
+			return "Subflow: " + flowNode.Name
+		}
+		return "Subflow: " + flowId
+	}
+	if flowNode.Label != "" {
+		return flowNode.Label
+	}
+	return flowId
+}
+
+func NewNodeREDFlowConfigBackup(filepath, flowId string, nodes []NodeREDNode, config *ConfigBackupOptions, modifiedDate time.Time) (*ConfigBackup, error) {
+	if config.BackupType != stateName[BackupTypeNodeRED] {
+		return nil, fmt.Errorf("NewNodeREDFlowConfigBackup called with non-nodered backup type: %s", config.BackupType)
+	}
+
+	var flowNode *NodeREDNode
+	for index := range nodes {
+		if nodes[index].ID == flowId {
+			flowNode = &nodes[index]
+			break
+		}
+	}
+
+	blob, err := MarshalNodeREDNodes(nodes, "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize flow %s: %w", flowId, err)
+	}
+
+	return &ConfigBackup{
+		ConfigBackupIdentifier: ConfigBackupIdentifier{
+			ID:   flowId,
+			Path: config.Path,
+		},
+		FriendlyName: NodeREDFlowName(flowId, flowNode),
+		Hash:         HashContent(blob, config),
+		BackupType:   config.BackupType,
+		ModifiedDate: modifiedDate,
+		FilePath:     filepath,
+		Blob:         blob,
+	}, nil
+}
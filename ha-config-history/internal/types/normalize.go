@@ -0,0 +1,148 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NormalizeOptions are applied to a backup's content before it is hashed, so that changes
+// that are not meaningful do not create a new version. The stored content is unchanged.
+type NormalizeOptions struct {
+	// IgnorePaths are dot separated key paths removed before hashing, "*" matches any key
+	// or list index. For example "data.entries.*.modified_at".
+	IgnorePaths []string `json:"ignorePaths,omitempty"`
+	// SortPaths are dot separated key paths of lists whose order is ignored
+	SortPaths []string `json:"sortPaths,omitempty"`
+	// Canonicalize ignores formatting, comments and key order. Content is always
+	// canonicalized when IgnorePaths or SortPaths are set.
+	Canonicalize bool `json:"canonicalize,omitempty"`
+}
+
+func (o *NormalizeOptions) enabled() bool {
+	return o != nil && (o.Canonicalize || len(o.IgnorePaths) > 0 || len(o.SortPaths) > 0)
+}
+
+// HashContent returns the hash of a backup's content after applying the config's
+// normalization rules. Content that cannot be parsed as JSON or YAML is hashed as is.
+func HashContent(blob []byte, config *ConfigBackupOptions) string {
+	if config == nil || !config.Normalize.enabled() {
+		return HashByteSlice(blob)
+	}
+
+	normalized, err := NormalizeContent(blob, config.Normalize)
+	if err != nil {
+		return HashByteSlice(blob)
+	}
+	return HashByteSlice(normalized)
+}
+
+// NormalizeContent parses JSON or YAML content, applies the normalization rules and
+// returns it as canonical JSON.
+func NormalizeContent(blob []byte, options *NormalizeOptions) ([]byte, error) {
+	var content interface{}
+	if err := json.Unmarshal(blob, &content); err != nil {
+		if err := yaml.Unmarshal(blob, &content); err != nil {
+			return nil, fmt.Errorf("content is not JSON or YAML: %w", err)
+		}
+	}
+
+	for _, path := range options.IgnorePaths {
+		content = removePath(content, splitKeyPath(path))
+	}
+	for _, path := range options.SortPaths {
+		content = sortPath(content, splitKeyPath(path))
+	}
+
+	// encoding/json writes map keys in sorted order, which canonicalizes the content
+	return json.Marshal(content)
+}
+
+// ValidateKeyPath checks that a normalization key path has no empty segments.
+func ValidateKeyPath(path string) error {
+	if slices.Contains(splitKeyPath(path), "") {
+		return fmt.Errorf("key path '%s' has an empty segment", path)
+	}
+	return nil
+}
+
+func splitKeyPath(path string) []string {
+	return strings.Split(strings.TrimSpace(path), ".")
+}
+
+func removePath(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	last := len(path) == 1
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typed {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if last {
+				delete(typed, key)
+			} else {
+				typed[key] = removePath(child, path[1:])
+			}
+		}
+	case []interface{}:
+		kept := typed[:0]
+		for index, child := range typed {
+			if !matchesIndex(path[0], index) {
+				kept = append(kept, child)
+				continue
+			}
+			if !last {
+				kept = append(kept, removePath(child, path[1:]))
+			}
+		}
+		return kept
+	}
+
+	return value
+}
+
+func sortPath(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		if list, ok := value.([]interface{}); ok {
+			slices.SortStableFunc(list, func(a, b interface{}) int {
+				return bytes.Compare(canonicalJson(a), canonicalJson(b))
+			})
+		}
+		return value
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typed {
+			if path[0] == "*" || path[0] == key {
+				typed[key] = sortPath(child, path[1:])
+			}
+		}
+	case []interface{}:
+		for index, child := range typed {
+			if matchesIndex(path[0], index) {
+				typed[index] = sortPath(child, path[1:])
+			}
+		}
+	}
+
+	return value
+}
+
+func matchesIndex(segment string, index int) bool {
+	return segment == "*" || segment == strconv.Itoa(index)
+}
+
+func canonicalJson(value interface{}) []byte {
+	encoded, _ := json.Marshal(value)
+	return encoded
+}
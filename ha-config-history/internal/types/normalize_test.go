@@ -0,0 +1,86 @@
+package types
+
+import "testing"
+
+func TestHashContent(t *testing.T) {
+	storage := func(normalize *NormalizeOptions) *ConfigBackupOptions {
+		return &ConfigBackupOptions{Path: ".storage/core.entity_registry", BackupType: "single", Normalize: normalize}
+	}
+
+	tests := []struct {
+		name      string
+		normalize *NormalizeOptions
+		old       string
+		new       string
+		sameHash  bool
+	}{
+		{
+			name: "no rules hashes raw bytes",
+			old:  `{"a": 1, "b": 2}`,
+			new:  `{"b": 2, "a": 1}`,
+		},
+		{
+			name:      "canonicalize ignores key order and formatting",
+			normalize: &NormalizeOptions{Canonicalize: true},
+			old:       `{"a": 1, "b": 2}`,
+			new:       "{\n  \"b\": 2,\n  \"a\": 1\n}",
+			sameHash:  true,
+		},
+		{
+			name:      "canonicalize ignores yaml comments",
+			normalize: &NormalizeOptions{Canonicalize: true},
+			old:       "alias: Lights\nmode: single\n",
+			new:       "# Turns on the lights\nalias: Lights\nmode:   single\n",
+			sameHash:  true,
+		},
+		{
+			name:      "ignored path with wildcard",
+			normalize: &NormalizeOptions{IgnorePaths: []string{"minor_version", "data.entities.*.modified_at"}},
+			old:       `{"minor_version": 1, "data": {"entities": [{"id": "a", "modified_at": "2025-01-01"}]}}`,
+			new:       `{"minor_version": 2, "data": {"entities": [{"id": "a", "modified_at": "2025-02-01"}]}}`,
+			sameHash:  true,
+		},
+		{
+			name:      "changes outside ignored paths are kept",
+			normalize: &NormalizeOptions{IgnorePaths: []string{"data.entities.*.modified_at"}},
+			old:       `{"data": {"entities": [{"id": "a", "modified_at": "2025-01-01"}]}}`,
+			new:       `{"data": {"entities": [{"id": "b", "modified_at": "2025-01-01"}]}}`,
+		},
+		{
+			name:      "sorted list ignores order",
+			normalize: &NormalizeOptions{SortPaths: []string{"data.entities"}},
+			old:       `{"data": {"entities": [{"id": "a"}, {"id": "b"}]}}`,
+			new:       `{"data": {"entities": [{"id": "b"}, {"id": "a"}]}}`,
+			sameHash:  true,
+		},
+		{
+			name:      "unparseable content hashes raw bytes",
+			normalize: &NormalizeOptions{Canonicalize: true},
+			old:       "key: [unclosed",
+			new:       "key:  [unclosed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldHash := HashContent([]byte(tt.old), storage(tt.normalize))
+			newHash := HashContent([]byte(tt.new), storage(tt.normalize))
+			if (oldHash == newHash) != tt.sameHash {
+				t.Errorf("hashes equal = %v, expected %v", oldHash == newHash, tt.sameHash)
+			}
+		})
+	}
+}
+
+func TestValidateKeyPath(t *testing.T) {
+	for _, path := range []string{"minor_version", "data.entities.*.modified_at", "data.0.id"} {
+		if err := ValidateKeyPath(path); err != nil {
+			t.Errorf("ValidateKeyPath(%q) returned error: %v", path, err)
+		}
+	}
+	for _, path := range []string{"", "data.", ".data", "data..id"} {
+		if err := ValidateKeyPath(path); err == nil {
+			t.Errorf("ValidateKeyPath(%q) expected error", path)
+		}
+	}
+}
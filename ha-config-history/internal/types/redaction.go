@@ -0,0 +1,331 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RedactedPlaceholder replaces redacted values in backups and diffs
+const RedactedPlaceholder = "**REDACTED**"
+
+// ErrRedactionNotRecoverable is returned when a redacted backup is restored and its
+// original values cannot be found in the live file.
+var ErrRedactionNotRecoverable = errors.New("redacted values cannot be recovered from the live file")
+
+// RedactionSettings hides inline secrets such as passwords and API keys when backups and
+// diffs are displayed, and optionally in the stored backups too.
+type RedactionSettings struct {
+	// Keys are key names whose values are redacted, matched case insensitively with
+	// shell patterns such as "*password*"
+	Keys []string `json:"keys,omitempty"`
+	// Patterns are regular expressions matched within a line. The first capture group is
+	// redacted if the pattern has one, otherwise the whole match.
+	Patterns []string `json:"patterns,omitempty"`
+	// RedactStoredBackups redacts values before backups are written to disk
+	RedactStoredBackups bool `json:"redactStoredBackups,omitempty"`
+}
+
+var (
+	redactionKeyLine = regexp.MustCompile(`^(\s*(?:-\s+)?)(["']?)([\w.-]+)(["']?\s*:[ \t]+)(\S.*)$`)
+	leadingSpace     = regexp.MustCompile(`^\s*`)
+)
+
+// Enabled reports whether any redaction rules are configured.
+func (r *RedactionSettings) Enabled() bool {
+	return r != nil && (len(r.Keys) > 0 || len(r.Patterns) > 0)
+}
+
+// StoresRedacted reports whether backups are redacted before they are written to disk.
+func (r *RedactionSettings) StoresRedacted() bool {
+	return r.Enabled() && r.RedactStoredBackups
+}
+
+// Redact replaces the values matched by the redaction rules with RedactedPlaceholder.
+// Binary content, and content when no rules are configured, is returned unchanged.
+func (r *RedactionSettings) Redact(content []byte) []byte {
+	if !r.Enabled() || IsBinary(content) {
+		return content
+	}
+	return []byte(strings.Join(r.redactLines(string(content)), "\n"))
+}
+
+// Validate checks that the redaction key names and patterns can be used.
+func (r *RedactionSettings) Validate() error {
+	if r == nil {
+		return nil
+	}
+	for _, key := range r.Keys {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("redaction key cannot be empty")
+		}
+		if _, err := path.Match(strings.ToLower(key), ""); err != nil {
+			return fmt.Errorf("invalid redaction key '%s': %w", key, err)
+		}
+	}
+	for _, pattern := range r.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid redaction pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// IsRedacted reports whether content contains redacted values.
+func IsRedacted(content []byte) bool {
+	return strings.Contains(string(content), RedactedPlaceholder)
+}
+
+// RecoverRedacted puts the original values back into redacted content using the live
+// file. Each redacted line is replaced by the first unused line of the live file that
+// redacts to the same line, so recovered values are the ones currently in the file.
+func (r *RedactionSettings) RecoverRedacted(redacted, live []byte) ([]byte, error) {
+	lines := strings.Split(string(redacted), "\n")
+	liveLines := strings.Split(string(live), "\n")
+	redactedLiveLines := liveLines
+	if r.Enabled() {
+		redactedLiveLines = r.redactLines(string(live))
+	}
+
+	used := make([]bool, len(liveLines))
+	for i, line := range lines {
+		if !strings.Contains(line, RedactedPlaceholder) {
+			continue
+		}
+
+		recovered := false
+		for j, redactedLive := range redactedLiveLines {
+			if !used[j] && redactedLive == line && liveLines[j] != line {
+				lines[i] = liveLines[j]
+				used[j] = true
+				recovered = true
+				break
+			}
+		}
+		if !recovered {
+			return nil, fmt.Errorf("%w: line %d", ErrRedactionNotRecoverable, i+1)
+		}
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// redactLines redacts each line of content, keeping the number of lines so that redacted
+// content can be matched against the original line by line.
+func (r *RedactionSettings) redactLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	if len(r.Keys) > 0 && !r.redactKeysInTree(content, lines) {
+		r.redactKeyLines(lines)
+	}
+
+	for _, pattern := range r.Patterns {
+		// Invalid patterns are rejected when settings are saved
+		if compiled, err := regexp.Compile(pattern); err == nil {
+			for i, line := range lines {
+				lines[i] = redactPattern(compiled, line)
+			}
+		}
+	}
+	return lines
+}
+
+// redactKeysInTree redacts the values of matching keys found by walking the parsed YAML or
+// JSON, which also finds keys in compact JSON and flow mappings. Values are replaced where
+// they appear in lines so the rest of the content keeps its formatting. It reports false
+// when content cannot be parsed.
+func (r *RedactionSettings) redactKeysInTree(content string, lines []string) bool {
+	values := [][2]*yaml.Node{}
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return false
+		}
+		values = r.matchingValues(&document, values)
+	}
+
+	// Values are replaced from the end so that earlier columns on a line stay correct
+	sort.Slice(values, func(i, j int) bool {
+		if values[i][1].Line != values[j][1].Line {
+			return values[i][1].Line > values[j][1].Line
+		}
+		return values[i][1].Column > values[j][1].Column
+	})
+	for _, pair := range values {
+		redactScalar(pair[0], pair[1], lines)
+	}
+	return true
+}
+
+// matchingValues appends the key and value of every scalar under a matching key.
+func (r *RedactionSettings) matchingValues(node *yaml.Node, values [][2]*yaml.Node) [][2]*yaml.Node {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode && r.matchesKey(key.Value) {
+				values = append(values, [2]*yaml.Node{key, value})
+			}
+		}
+	}
+	for _, child := range node.Content {
+		values = r.matchingValues(child, values)
+	}
+	return values
+}
+
+// redactScalar replaces a scalar value in lines. References to secrets are left as they are.
+func redactScalar(key, value *yaml.Node, lines []string) {
+	if value.Value == "" || value.Tag == "!secret" || value.Tag == "!env_var" || value.Line < 1 || value.Line > len(lines) {
+		return
+	}
+
+	// Columns count characters rather than bytes
+	line := []rune(lines[value.Line-1])
+	start := value.Column - 1
+	if start < 0 || start >= len(line) {
+		return
+	}
+
+	if value.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		// Lines indented past the key are part of the value
+		for i := value.Line; i < len(lines); i++ {
+			indent := len(leadingSpace.FindString(lines[i]))
+			if strings.TrimSpace(lines[i]) == "" {
+				continue
+			}
+			if indent < key.Column {
+				break
+			}
+			lines[i] = lines[i][:indent] + RedactedPlaceholder
+		}
+		return
+	}
+
+	end := len(line)
+	switch quote := line[start]; {
+	case quote == '"' || quote == '\'':
+		end = -1
+		for i := start + 1; i < len(line); i++ {
+			if quote == '"' && line[i] == '\\' {
+				i++
+				continue
+			}
+			if line[i] == quote {
+				end = i + 1
+				break
+			}
+		}
+		if end == -1 {
+			// Quoted values continued on the next line are left as they are
+			return
+		}
+		lines[value.Line-1] = string(line[:start+1]) + RedactedPlaceholder + string(line[end-1:])
+		return
+	case strings.HasPrefix(string(line[start:]), value.Value):
+		end = start + len([]rune(value.Value))
+	default:
+		// Plain values folded over several lines are redacted up to any comment
+		if comment := strings.Index(string(line[start:]), " #"); comment != -1 {
+			end = start + len([]rune(string(line[start:])[:comment]))
+		}
+	}
+	lines[value.Line-1] = string(line[:start]) + RedactedPlaceholder + string(line[end:])
+}
+
+// redactKeyLines redacts the values of matching keys line by line, for content that cannot
+// be parsed.
+func (r *RedactionSettings) redactKeyLines(lines []string) {
+	blockIndent := -1
+	for i, line := range lines {
+		indent := len(leadingSpace.FindString(line))
+
+		// Lines of a block scalar such as "password: |" are part of the value
+		if blockIndent >= 0 {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if indent > blockIndent {
+				lines[i] = line[:indent] + RedactedPlaceholder
+				continue
+			}
+			blockIndent = -1
+		}
+
+		if match := redactionKeyLine.FindStringSubmatch(line); match != nil && r.matchesKey(match[3]) {
+			value := match[5]
+			if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+				blockIndent = indent
+			} else {
+				lines[i] = match[1] + match[2] + match[3] + match[4] + redactValue(value)
+			}
+		}
+	}
+}
+
+func (r *RedactionSettings) matchesKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range r.Keys {
+		if matched, _ := path.Match(strings.ToLower(pattern), key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue replaces a scalar value on a line, keeping its quotes and anything that follows it
+// such as a trailing comma or comment. References to secrets are left as they are.
+func redactValue(value string) string {
+	if strings.HasPrefix(value, "!secret") || strings.HasPrefix(value, "!env_var") ||
+		strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[") {
+		return value
+	}
+
+	if quote := value[0]; quote == '"' || quote == '\'' {
+		for i := 1; i < len(value); i++ {
+			if quote == '"' && value[i] == '\\' {
+				i++
+				continue
+			}
+			if value[i] == quote {
+				return string(quote) + RedactedPlaceholder + value[i:]
+			}
+		}
+		return value
+	}
+
+	if comment := strings.Index(value, " #"); comment != -1 {
+		return RedactedPlaceholder + value[comment:]
+	}
+	return RedactedPlaceholder
+}
+
+func redactPattern(pattern *regexp.Regexp, line string) string {
+	matches := pattern.FindAllStringSubmatchIndex(line, -1)
+	if matches == nil {
+		return line
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if len(match) > 3 && match[2] >= 0 {
+			start, end = match[2], match[3]
+		}
+		builder.WriteString(line[last:start])
+		builder.WriteString(RedactedPlaceholder)
+		last = end
+	}
+	builder.WriteString(line[last:])
+	return builder.String()
+}
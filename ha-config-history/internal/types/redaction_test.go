@@ -0,0 +1,121 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	redaction := &RedactionSettings{
+		Keys:     []string{"*password*", "api_key", "psk"},
+		Patterns: []string{`(?i)bearer\s+(\S+)`},
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "yaml keys",
+			content:  "wifi:\n  ssid: Home\n  password: hunter2 # main network\n",
+			expected: "wifi:\n  ssid: Home\n  password: **REDACTED** # main network\n",
+		},
+		{
+			name:     "key names are matched case insensitively",
+			content:  "  API_KEY: \"abc123\"\n",
+			expected: "  API_KEY: \"**REDACTED**\"\n",
+		},
+		{
+			name:     "json keys keep the document valid",
+			content:  "{\n  \"psk\": \"abc\\\"def\",\n  \"name\": \"x\"\n}",
+			expected: "{\n  \"psk\": \"**REDACTED**\",\n  \"name\": \"x\"\n}",
+		},
+		{
+			name:     "compact json",
+			content:  `[{"id":"1","type":"mqtt-broker","password":"hunter2","psk":1234,"name":"x"}]`,
+			expected: `[{"id":"1","type":"mqtt-broker","password":"**REDACTED**","psk":**REDACTED**,"name":"x"}]`,
+		},
+		{
+			name:     "yaml flow mappings",
+			content:  "wifi: {ssid: Home, password: hunter2}\n",
+			expected: "wifi: {ssid: Home, password: **REDACTED**}\n",
+		},
+		{
+			name:     "every document of a multi-document file",
+			content:  "name: one\npassword: a\n---\nname: two\npassword: b\n",
+			expected: "name: one\npassword: **REDACTED**\n---\nname: two\npassword: **REDACTED**\n",
+		},
+		{
+			name:     "content that cannot be parsed is redacted line by line",
+			content:  "key: [unclosed\npassword: hunter2\n",
+			expected: "key: [unclosed\npassword: **REDACTED**\n",
+		},
+		{
+			name:     "secret references are kept",
+			content:  "mqtt_password: !secret mqtt_password\n",
+			expected: "mqtt_password: !secret mqtt_password\n",
+		},
+		{
+			name:     "block scalar values",
+			content:  "ota:\n  password: |\n    line one\n    line two\n  port: 3232\n",
+			expected: "ota:\n  password: |\n    **REDACTED**\n    **REDACTED**\n  port: 3232\n",
+		},
+		{
+			name:     "patterns redact their capture group",
+			content:  "authorization: Bearer abc.def\n",
+			expected: "authorization: Bearer **REDACTED**\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if redacted := string(redaction.Redact([]byte(tt.content))); redacted != tt.expected {
+				t.Errorf("Redact() = %q, expected %q", redacted, tt.expected)
+			}
+		})
+	}
+
+	t.Run("no rules leaves content unchanged", func(t *testing.T) {
+		var none *RedactionSettings
+		if redacted := string(none.Redact([]byte("password: hunter2"))); redacted != "password: hunter2" {
+			t.Errorf("Redact() = %q", redacted)
+		}
+	})
+}
+
+func TestRecoverRedacted(t *testing.T) {
+	redaction := &RedactionSettings{Keys: []string{"password"}}
+	backup := redaction.Redact([]byte("wifi:\n  ssid: Old\n  password: old\napi:\n  password: api\n"))
+
+	t.Run("values come from the live file", func(t *testing.T) {
+		live := []byte("wifi:\n  ssid: New\n  password: current\napi:\n  password: api\n")
+		recovered, err := redaction.RecoverRedacted(backup, live)
+		if err != nil {
+			t.Fatalf("RecoverRedacted() error: %v", err)
+		}
+		expected := "wifi:\n  ssid: Old\n  password: current\napi:\n  password: api\n"
+		if string(recovered) != expected {
+			t.Errorf("RecoverRedacted() = %q, expected %q", recovered, expected)
+		}
+	})
+
+	t.Run("values in compact json come from the live file", func(t *testing.T) {
+		original := []byte(`{"broker":{"password":"old"}}`)
+		live := []byte(`{"broker":{"password":"current"}}`)
+		recovered, err := redaction.RecoverRedacted(redaction.Redact(original), live)
+		if err != nil {
+			t.Fatalf("RecoverRedacted() error: %v", err)
+		}
+		if string(recovered) != string(live) {
+			t.Errorf("RecoverRedacted() = %q, expected %q", recovered, live)
+		}
+	})
+
+	t.Run("missing values are refused", func(t *testing.T) {
+		live := []byte("wifi:\n  ssid: New\n  password: current\n")
+		if _, err := redaction.RecoverRedacted(backup, live); !errors.Is(err, ErrRedactionNotRecoverable) {
+			t.Errorf("Expected ErrRedactionNotRecoverable, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"maps"
+	"slices"
+)
+
+// Group returns the group with the given slug.
+func (appSettings *AppSettings) Group(slug GroupSlug) (*ConfigBackupOptionGroup, bool) {
+	for _, group := range appSettings.ConfigGroups {
+		if group != nil && group.Slug == slug {
+			return group, true
+		}
+	}
+	return nil, false
+}
+
+// Schedule returns the cron schedule a config is scanned on: its own, else its group's,
+// else the app's. It is empty when the config is only scanned on startup and on demand.
+func (appSettings *AppSettings) Schedule(groupSlug GroupSlug, options *ConfigBackupOptions) string {
+	if options.CronSchedule != nil && *options.CronSchedule != "" {
+		return *options.CronSchedule
+	}
+	if group, exists := appSettings.Group(groupSlug); exists && group.CronSchedule != nil && *group.CronSchedule != "" {
+		return *group.CronSchedule
+	}
+	if appSettings.CronSchedule != nil {
+		return *appSettings.CronSchedule
+	}
+	return ""
+}
+
+// Schedules returns every schedule that a config is scanned on, sorted.
+func (appSettings *AppSettings) Schedules() []string {
+	schedules := map[string]bool{}
+	for _, group := range appSettings.ConfigGroups {
+		for _, options := range group.Configs {
+			if schedule := appSettings.Schedule(group.Slug, options); schedule != "" {
+				schedules[schedule] = true
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(schedules))
+}
+
+// Watches reports whether a config's files are watched so changes are backed up as they
+// happen.
+func (appSettings *AppSettings) Watches(groupSlug GroupSlug, options *ConfigBackupOptions) bool {
+	if options.DisableWatch != nil {
+		return !*options.DisableWatch
+	}
+	group, exists := appSettings.Group(groupSlug)
+	return !exists || !group.DisableWatch
+}
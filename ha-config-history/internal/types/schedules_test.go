@@ -0,0 +1,55 @@
+package types
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSchedule(t *testing.T) {
+	daily := "0 3 * * *"
+	hourly := "0 * * * *"
+	weekly := "0 4 * * 0"
+	disabled := true
+	enabled := false
+
+	automations := NewSingleConfigBackupOptions("automations.yaml")
+	registry := NewSingleConfigBackupOptions(".storage/core.entity_registry")
+	devices := &ConfigBackupOptions{Path: ".storage/core.device_registry", BackupType: "single", CronSchedule: &weekly, DisableWatch: &enabled}
+	storage := NewConfigBackupOptionGroup("Storage", []*ConfigBackupOptions{registry, devices})
+	storage.CronSchedule = &daily
+	storage.DisableWatch = true
+	scripts := &ConfigBackupOptions{Path: "scripts.yaml", BackupType: "single", DisableWatch: &disabled}
+
+	appSettings := &AppSettings{
+		CronSchedule: &hourly,
+		ConfigGroups: []*ConfigBackupOptionGroup{
+			NewConfigBackupOptionGroup("Main", []*ConfigBackupOptions{automations, scripts}),
+			storage,
+		},
+	}
+
+	tests := []struct {
+		group    GroupSlug
+		options  *ConfigBackupOptions
+		schedule string
+		watches  bool
+	}{
+		{"main", automations, hourly, true},
+		{"main", scripts, hourly, false},
+		{"storage", registry, daily, false},
+		{"storage", devices, weekly, true},
+	}
+
+	for _, tt := range tests {
+		if schedule := appSettings.Schedule(tt.group, tt.options); schedule != tt.schedule {
+			t.Errorf("Schedule(%s) = %q, expected %q", tt.options.Path, schedule, tt.schedule)
+		}
+		if watches := appSettings.Watches(tt.group, tt.options); watches != tt.watches {
+			t.Errorf("Watches(%s) = %v, expected %v", tt.options.Path, watches, tt.watches)
+		}
+	}
+
+	if schedules := appSettings.Schedules(); !slices.Equal(schedules, []string{hourly, daily, weekly}) {
+		t.Errorf("Schedules() = %v", schedules)
+	}
+}
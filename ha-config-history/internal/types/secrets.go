@@ -0,0 +1,102 @@
+package types
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecretsFilename is the file Home Assistant and ESPHome resolve !secret references from
+const SecretsFilename = "secrets.yaml"
+
+const secretTag = "!secret"
+
+// SecretReferences returns the names referenced with !secret in YAML content, in the
+// order they first appear.
+func SecretReferences(content []byte) []string {
+	names := []string{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			break
+		}
+		collectSecretReferences(&document, &names)
+	}
+
+	return names
+}
+
+func collectSecretReferences(node *yaml.Node, names *[]string) {
+	if node.Kind == yaml.ScalarNode && node.Tag == secretTag && !slices.Contains(*names, node.Value) {
+		*names = append(*names, node.Value)
+	}
+	for _, child := range node.Content {
+		collectSecretReferences(child, names)
+	}
+}
+
+// ParseSecrets reads the values of a secrets file. Values that are not scalars are
+// returned as YAML.
+func ParseSecrets(content []byte) (map[string]string, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets: %w", err)
+	}
+
+	secrets := map[string]string{}
+	if len(document.Content) == 0 {
+		return secrets, nil
+	}
+
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, errors.New("secrets must be a mapping of names to values")
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		value := mapping.Content[i+1]
+		if value.Kind == yaml.ScalarNode {
+			secrets[mapping.Content[i].Value] = value.Value
+			continue
+		}
+
+		encoded, err := yaml.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", mapping.Content[i].Value, err)
+		}
+		secrets[mapping.Content[i].Value] = string(encoded)
+	}
+
+	return secrets, nil
+}
+
+// SecretFingerprinter identifies secret values without revealing them. Fingerprints are
+// keyed, so they can only be compared with others from the same fingerprinter.
+type SecretFingerprinter struct {
+	key []byte
+}
+
+// NewSecretFingerprinter creates a fingerprinter with a random key read from random.
+func NewSecretFingerprinter(random io.Reader) (*SecretFingerprinter, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(random, key); err != nil {
+		return nil, fmt.Errorf("failed to create secret fingerprint key: %w", err)
+	}
+	return &SecretFingerprinter{key: key}, nil
+}
+
+// Fingerprint returns a short keyed hash of a secret value.
+func (f *SecretFingerprinter) Fingerprint(value string) string {
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
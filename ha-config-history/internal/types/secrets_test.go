@@ -0,0 +1,45 @@
+package types
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestSecretReferences(t *testing.T) {
+	content := []byte("mqtt:\n  password: !secret mqtt_password\n  username: !secret mqtt_user\n---\napi:\n  key: !secret mqtt_password\nname: secret\n")
+
+	references := SecretReferences(content)
+	if !slices.Equal(references, []string{"mqtt_password", "mqtt_user"}) {
+		t.Errorf("SecretReferences() = %v", references)
+	}
+}
+
+func TestParseSecrets(t *testing.T) {
+	secrets, err := ParseSecrets([]byte("password: hunter2\nport: 1883\nnested:\n  key: value\n"))
+	if err != nil {
+		t.Fatalf("ParseSecrets() error: %v", err)
+	}
+	if secrets["password"] != "hunter2" || secrets["port"] != "1883" || secrets["nested"] != "key: value\n" {
+		t.Errorf("Unexpected secrets %v", secrets)
+	}
+
+	if _, err := ParseSecrets([]byte("- not a mapping\n")); err == nil {
+		t.Error("Expected error for a list")
+	}
+}
+
+func TestSecretFingerprinter(t *testing.T) {
+	first, _ := NewSecretFingerprinter(bytes.NewReader(bytes.Repeat([]byte{1}, 32)))
+	second, _ := NewSecretFingerprinter(bytes.NewReader(bytes.Repeat([]byte{2}, 32)))
+
+	if first.Fingerprint("hunter2") != first.Fingerprint("hunter2") {
+		t.Error("Expected fingerprints of the same value to match")
+	}
+	if first.Fingerprint("hunter2") == first.Fingerprint("hunter3") {
+		t.Error("Expected fingerprints of different values to differ")
+	}
+	if first.Fingerprint("hunter2") == second.Fingerprint("hunter2") {
+		t.Error("Expected fingerprints to depend on the key")
+	}
+}
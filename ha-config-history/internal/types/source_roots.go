@@ -0,0 +1,107 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HomeAssistantSourceRoot is the root configs use when they do not select one. It always
+// points at AppSettings.HomeAssistantConfigDir.
+const HomeAssistantSourceRoot = "homeassistant"
+
+// SourceRoot is a named directory that configs can be tracked from, such as the add-on
+// config or share folders mapped into the add-on.
+type SourceRoot struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Poll bool   `json:"poll,omitempty"` // Check for changes on an interval instead of waiting for file system events, for network shares
+}
+
+func defaultSourceRoots() []*SourceRoot {
+	return []*SourceRoot{
+		{Name: "addon_configs", Path: "/addon_configs"},
+		{Name: "share", Path: "/share"},
+		{Name: "ssl", Path: "/ssl"},
+		{Name: "media", Path: "/media"},
+	}
+}
+
+// RootName returns the name of the source root the config is read from.
+func (options *ConfigBackupOptions) RootName() string {
+	if options.Root == "" {
+		return HomeAssistantSourceRoot
+	}
+	return options.Root
+}
+
+// SourceRoot returns the source root with the given name.
+func (appSettings *AppSettings) SourceRoot(name string) (*SourceRoot, bool) {
+	if name == "" || name == HomeAssistantSourceRoot {
+		return &SourceRoot{Name: HomeAssistantSourceRoot, Path: appSettings.HomeAssistantConfigDir, Poll: appSettings.PollHomeAssistantConfigDir}, true
+	}
+	for _, root := range appSettings.SourceRoots {
+		if root != nil && root.Name == name {
+			return root, true
+		}
+	}
+	return nil, false
+}
+
+// RootDir returns the directory a config's path is relative to.
+func (appSettings *AppSettings) RootDir(options *ConfigBackupOptions) (string, error) {
+	root, ok := appSettings.SourceRoot(options.Root)
+	if !ok {
+		return "", fmt.Errorf("unknown source root %q for config %s", options.Root, options.Path)
+	}
+	return root.Path, nil
+}
+
+// PollsPath reports whether a directory is in a source root that is polled for changes.
+func (appSettings *AppSettings) PollsPath(path string) bool {
+	roots := append([]*SourceRoot{{Path: appSettings.HomeAssistantConfigDir, Poll: appSettings.PollHomeAssistantConfigDir}}, appSettings.SourceRoots...)
+	for _, root := range roots {
+		if root != nil && root.Poll && root.Path != "" && isWithin(root.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// SandboxedPath joins elements onto rootDir and returns an error if the result, or the
+// file it links to, is outside rootDir.
+func SandboxedPath(rootDir string, elements ...string) (string, error) {
+	fullPath := filepath.Join(append([]string{rootDir}, elements...)...)
+	if !isWithin(rootDir, fullPath) {
+		return "", fmt.Errorf("path %s is outside of %s", fullPath, rootDir)
+	}
+
+	// Symlinks are resolved for the part of the path that exists
+	resolvedRoot, err := filepath.EvalSymlinks(rootDir)
+	if err != nil {
+		return fullPath, nil
+	}
+	existing := fullPath
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return fullPath, nil
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err == nil && !isWithin(resolvedRoot, resolved) {
+		return "", fmt.Errorf("path %s links outside of %s", fullPath, rootDir)
+	}
+
+	return fullPath, nil
+}
+
+func isWithin(rootDir, path string) bool {
+	relativePath, err := filepath.Rel(filepath.Clean(rootDir), filepath.Clean(path))
+	return err == nil && relativePath != ".." && !strings.HasPrefix(relativePath, ".."+string(filepath.Separator))
+}
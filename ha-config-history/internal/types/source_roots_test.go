@@ -0,0 +1,97 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRootDir(t *testing.T) {
+	appSettings := &AppSettings{
+		HomeAssistantConfigDir: "/homeassistant",
+		SourceRoots:            []*SourceRoot{{Name: "share", Path: "/share"}},
+	}
+
+	tests := []struct {
+		root      string
+		expected  string
+		expectErr bool
+	}{
+		{"", "/homeassistant", false},
+		{HomeAssistantSourceRoot, "/homeassistant", false},
+		{"share", "/share", false},
+		{"missing", "", true},
+	}
+
+	for _, tt := range tests {
+		rootDir, err := appSettings.RootDir(&ConfigBackupOptions{Path: "file.yaml", Root: tt.root})
+		if (err != nil) != tt.expectErr {
+			t.Errorf("RootDir(%q) error = %v, expectErr %v", tt.root, err, tt.expectErr)
+		}
+		if rootDir != tt.expected {
+			t.Errorf("RootDir(%q) = %q, expected %q", tt.root, rootDir, tt.expected)
+		}
+	}
+}
+
+func TestSandboxedPath(t *testing.T) {
+	rootDir := t.TempDir()
+	outsideDir := t.TempDir()
+
+	if err := os.Symlink(outsideDir, filepath.Join(rootDir, "escape")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		elements  []string
+		expectErr bool
+	}{
+		{"file in root", []string{"zigbee2mqtt", "configuration.yaml"}, false},
+		{"file that does not exist yet", []string{"new", "file.yaml"}, false},
+		{"parent traversal", []string{"..", "other", "file.yaml"}, true},
+		{"traversal within an element", []string{"dir", "../../file.yaml"}, true},
+		{"symlink out of root", []string{"escape", "file.yaml"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SandboxedPath(rootDir, tt.elements...)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("SandboxedPath(%v) error = %v, expectErr %v", tt.elements, err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestPollsPath(t *testing.T) {
+	appSettings := &AppSettings{
+		HomeAssistantConfigDir: "/homeassistant",
+		SourceRoots: []*SourceRoot{
+			{Name: "share", Path: "/share", Poll: true},
+			{Name: "ssl", Path: "/ssl"},
+		},
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/share", true},
+		{"/share/nodered", true},
+		{"/shared", false},
+		{"/ssl", false},
+		{"/homeassistant/esphome", false},
+	}
+
+	for _, tt := range tests {
+		if polled := appSettings.PollsPath(tt.path); polled != tt.expected {
+			t.Errorf("PollsPath(%q) = %v, expected %v", tt.path, polled, tt.expected)
+		}
+	}
+
+	appSettings.PollHomeAssistantConfigDir = true
+	if !appSettings.PollsPath("/homeassistant/esphome") {
+		t.Error("Expected the Home Assistant config directory to be polled")
+	}
+}
@@ -23,6 +23,17 @@ type BackupConfigSummary struct {
 	BackupCount  int    `json:"backupCount"`
 	BackupsSize  int64  `json:"backupsSize"`
 	BackupType   string `json:"backupType"`
+	MissingId    bool   `json:"missingId,omitempty"`
+	MimeType     string `json:"mimeType,omitempty"`
+	Binary       bool   `json:"binary,omitempty"` // Latest backup is binary, so it is compared by size and hash only
+
+	// PreviousIds lists the ids this config was renamed from, most recent first
+	PreviousIds []string `json:"previousIds,omitempty"`
+	// RenamedTo is set when this config's id changed and its history continues under a new id
+	RenamedTo string `json:"renamedTo,omitempty"`
+	// Deleted is set when the config was removed from its file, its backups are kept
+	Deleted   bool       `json:"deleted,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 
 	// TODO: V2 Remove
 	Group string `json:"group,omitempty"` // For backward compatibility
@@ -39,6 +50,10 @@ func NewConfigBackupSummary(configBackup *ConfigBackup, backupCount int, backups
 		BackupCount:  backupCount,
 		BackupsSize:  backupsSize,
 		BackupType:   backupType,
+		MissingId:    configBackup.MissingId,
+		PreviousIds:  configBackup.PreviousIds,
+		MimeType:     BackupContentType(backupType, configBackup.Path, configBackup.ID, configBackup.Blob),
+		Binary:       IsBinary(configBackup.Blob),
 	}
 }
 
@@ -47,9 +62,11 @@ type ConfigBackup struct {
 	FriendlyName string `json:"friendlyName,omitempty"`
 	Hash         string `json:"hash,omitempty"`
 	ModifiedDate time.Time
-	BackupType   string `json:"backupType"` // "multiple", "single", "directory", "keyed"
-	FilePath     string `json:"-"`
-	Blob         []byte `json:"-"`
+	BackupType   string   `json:"backupType"` // "multiple", "single", "directory", "keyed", "multidocument", "nodered", "blueprint"
+	FilePath     string   `json:"-"`
+	Blob         []byte   `json:"-"`
+	MissingId    bool     `json:"missingId,omitempty"` // Entry had no id node, ID comes from the missing id strategy
+	PreviousIds  []string `json:"-"`
 }
 
 func NewBlobConfigBackup(filename, filepath string, blob []byte, config *ConfigBackupOptions, modifiedDate time.Time) (*ConfigBackup, error) {
@@ -60,7 +77,7 @@ func NewBlobConfigBackup(filename, filepath string, blob []byte, config *ConfigB
 				Path: config.Path,
 			},
 			FriendlyName: config.Path,
-			Hash:         hashByteSlice(blob),
+			Hash:         HashContent(blob, config),
 			ModifiedDate: modifiedDate,
 			BackupType:   config.BackupType,
 			FilePath:     filepath,
@@ -79,7 +96,7 @@ func NewBlobConfigBackup(filename, filepath string, blob []byte, config *ConfigB
 				Path: config.Path,
 			},
 			FriendlyName: filename,
-			Hash:         hashByteSlice(blob),
+			Hash:         HashContent(blob, config),
 			BackupType:   config.BackupType,
 			ModifiedDate: modifiedDate,
 			FilePath:     filepath,
@@ -90,7 +107,7 @@ func NewBlobConfigBackup(filename, filepath string, blob []byte, config *ConfigB
 	return nil, fmt.Errorf("unknown backup type: %s", config.BackupType)
 }
 
-func NewYamlConfigBackup(filename, filepath string, yamlNode *yaml.Node, config *ConfigBackupOptions, modifiedDate time.Time) (*ConfigBackup, error) {
+func NewYamlConfigBackup(filename, filepath string, yamlNode *yaml.Node, identity EntryIdentity, config *ConfigBackupOptions, modifiedDate time.Time) (*ConfigBackup, error) {
 	blob, _ := yaml.Marshal(yamlNode)
 
 	if config.BackupType == stateName[BackupTypeSingle] {
@@ -100,15 +117,16 @@ func NewYamlConfigBackup(filename, filepath string, yamlNode *yaml.Node, config
 	if config.BackupType == stateName[BackupTypeMultiple] {
 		return &ConfigBackup{
 			ConfigBackupIdentifier: ConfigBackupIdentifier{
-				ID:   GetYamlNodeValue(yamlNode, *config.IdNode),
+				ID:   identity.ID,
 				Path: config.Path,
 			},
 			FriendlyName: GetYamlNodeValue(yamlNode, *config.FriendlyNameNode),
-			Hash:         hashByteSlice(blob),
+			Hash:         HashContent(blob, config),
 			BackupType:   config.BackupType,
 			ModifiedDate: modifiedDate,
 			FilePath:     filepath,
 			Blob:         blob,
+			MissingId:    identity.MissingId,
 		}, nil
 	}
 
@@ -141,7 +159,37 @@ func NewKeyedConfigBackup(filepath string, keyNode, valueNode *yaml.Node, config
 			Path: config.Path,
 		},
 		FriendlyName: friendlyName,
-		Hash:         hashByteSlice(blob),
+		Hash:         HashContent(blob, config),
+		BackupType:   config.BackupType,
+		ModifiedDate: modifiedDate,
+		FilePath:     filepath,
+		Blob:         blob,
+	}, nil
+}
+
+// NewDocumentConfigBackup builds a ConfigBackup for a single document of a multi-document file,
+// identified by its id from ResolveDocumentIds.
+func NewDocumentConfigBackup(filepath string, index int, id string, documentNode *yaml.Node, config *ConfigBackupOptions, modifiedDate time.Time) (*ConfigBackup, error) {
+	if config.BackupType != stateName[BackupTypeMultiDocument] {
+		return nil, fmt.Errorf("NewDocumentConfigBackup called with non-multidocument backup type: %s", config.BackupType)
+	}
+
+	blob, _ := yaml.Marshal(documentNode)
+
+	friendlyName := fmt.Sprintf("Document %d", index+1)
+	if config.FriendlyNameNode != nil && documentNode.Kind == yaml.MappingNode {
+		if name, ok := GetYamlNodeValueOk(documentNode, *config.FriendlyNameNode); ok && name != "" {
+			friendlyName = name
+		}
+	}
+
+	return &ConfigBackup{
+		ConfigBackupIdentifier: ConfigBackupIdentifier{
+			ID:   id,
+			Path: config.Path,
+		},
+		FriendlyName: friendlyName,
+		Hash:         HashContent(blob, config),
 		BackupType:   config.BackupType,
 		ModifiedDate: modifiedDate,
 		FilePath:     filepath,
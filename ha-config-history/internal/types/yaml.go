@@ -7,7 +7,7 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-func hashByteSlice(bytes []byte) string {
+func HashByteSlice(bytes []byte) string {
 	hasher := sha1.New()
 	hasher.Write(bytes)
 	sha := base64.URLEncoding.EncodeToString(hasher.Sum(nil))
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"ha-config-history/internal/api"
 	"ha-config-history/internal/core"
 	"ha-config-history/internal/types"
 
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -23,6 +26,10 @@ var (
 	startTime time.Time
 )
 
+// shutdownTimeout is how long pending backups get to finish on shutdown, within the 10
+// seconds the Supervisor waits before killing the add-on.
+const shutdownTimeout = 8 * time.Second
+
 //go:embed dist
 var distFS embed.FS
 
@@ -49,8 +56,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	server := core.NewServer(appSettings, appSettingsPath)
-	server.Start()
+	server.Start(ctx)
 
 	r := gin.New()
 
@@ -83,11 +93,22 @@ func main() {
 	r.GET("/configs/:group/:path/:id/backups/:filename", api.GetConfigBackupHandler(server))
 	r.GET("/configs/:group/:path/:id/compare/:left/diff/:right", api.GetBackupDiffHandler(server))
 	r.POST("/configs/:group/:path/:id/backups/:filename/restore", api.RestoreBackupHandler(server))
+	r.POST("/configs/:group/:path/:id/restore", api.RestoreDeletedConfigHandler(server))
+	r.GET("/configs/:group/:path/:id/backups/:filename/blueprint-usages", api.GetBlueprintUsagesHandler(server))
+	r.GET("/configs/:group/:path/:id/blueprint-versions", api.GetConfigBlueprintVersionsHandler(server))
+	r.GET("/configs/:group/:path/:id/secret-versions", api.RequireAuthenticatedUser(os.Getenv("SECRETS_VIEW_TOKEN")), api.GetConfigSecretVersionsHandler(server))
 	r.DELETE("/configs/:group/:path/:id/backups/:filename", api.DeleteConfigBackupHandler(server))
 	r.DELETE("/configs/:group/:path/:id", api.DeleteAllConfigBackupsHandler(server))
 	r.POST("/backup", api.ProcessConfigsHandler(server))
+	r.GET("/events", api.StreamEventsHandler(server))
+	r.GET("/jobs", api.ListJobsHandler(server))
+	r.GET("/jobs/:id", api.GetJobHandler(server))
+	r.GET("/queue", api.GetQueueMetricsHandler(server))
+	r.GET("/schedules", api.ListSchedulesHandler(server))
 	r.GET("/settings", api.GetSettingsHandler(server))
 	r.PUT("/settings", api.UpdateSettingsHandler(server))
+	r.GET("/discover", api.DiscoverConfigsHandler(server))
+	r.POST("/discover/accept", api.AcceptDiscoveredConfigsHandler(server))
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -105,18 +126,29 @@ func main() {
 		})
 	})
 
-	// Set up graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	httpServer := &http.Server{Addr: appSettings.Port, Handler: r}
+	// Event streams never go idle, so they are ended for the HTTP server to shut down
+	httpServer.RegisterOnShutdown(server.Events().Close)
+
 	go func() {
-		<-quit
-		server.Shutdown()
-		os.Exit(0)
+		slog.Info("Starting API server", "port", appSettings.Port)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Failed to start server", "error", err)
+			os.Exit(1)
+		}
 	}()
 
-	slog.Info("Starting API server", "port", appSettings.Port)
-	if err := r.Run(appSettings.Port); err != nil {
-		slog.Error("Failed to start server", "error", err)
+	<-ctx.Done()
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down API server", "error", err)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down server", "error", err)
 		os.Exit(1)
 	}
 }